@@ -1,12 +1,11 @@
 package api
 
 import (
-	"database/sql"
-	"fmt"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/lib/pq"
 	db "github.com/niloy104/simplebank/db/sqlc"
 	"github.com/niloy104/simplebank/token"
 )
@@ -19,20 +18,28 @@ type createAccountRequest struct {
 func (server *Server) createAccount(ctx *gin.Context) {
 	var req createAccountRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		writeBindError(ctx, err)
 		return
 	}
 
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !server.authorize(ctx, permissionCreateAccount, authPayload.Username) {
+		return
+	}
+
+	idempotency, ok := server.checkIdempotencyKey(ctx, authPayload.Username, req)
+	if !ok {
+		return
+	}
 
 	// Verify that the user exists before creating account
 	_, err := server.store.GetUser(ctx, authPayload.Username)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": "user does not exist"})
+		if errors.Is(err, db.ErrRecordNotFound) {
+			writeError(ctx, http.StatusNotFound, errorCodeNotFound, errors.New("user does not exist"))
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		writeInternalError(ctx, err)
 		return
 	}
 
@@ -42,19 +49,24 @@ func (server *Server) createAccount(ctx *gin.Context) {
 		Balance:  0,
 	}
 
-	account, err := server.store.CreateAccount(ctx, arg)
+	var account db.Account
+	var replayed bool
+	if idempotency.Key != "" {
+		account, replayed, err = server.store.IdempotentCreateAccountTx(ctx, db.IdempotentCreateAccountTxParams{
+			CreateAccountParams: arg,
+			Idempotency:         idempotency,
+		})
+	} else {
+		account, err = server.store.CreateAccount(ctx, arg)
+	}
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			switch pqErr.Code.Name() {
-			case "foreign_key_violation", "unique_violation":
-				ctx.JSON(http.StatusForbidden, errorResponse(err))
-				return
-			}
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		writeStoreError(ctx, err)
 		return
 	}
 
+	if replayed {
+		ctx.Header(idempotentReplayedHeader, "true")
+	}
 	ctx.JSON(http.StatusCreated, account)
 }
 
@@ -67,25 +79,17 @@ type getAccountRequest struct {
 func (server *Server) getAccount(ctx *gin.Context) {
 	var req getAccountRequest
 	if err := ctx.ShouldBindUri(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		writeBindError(ctx, err)
 		return
 	}
 
 	account, err := server.store.GetAccount(ctx, req.ID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		writeStoreError(ctx, err)
 		return
 	}
 
-	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
-	if account.Owner != authPayload.Username {
-		err := fmt.Errorf("account doesn't belong to the authenticated user")
-		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+	if !server.authorize(ctx, permissionReadAccount, account.Owner) {
 		return
 	}
 
@@ -94,27 +98,108 @@ func (server *Server) getAccount(ctx *gin.Context) {
 
 // List of account
 type listAccountRequest struct {
-	PageID   int32 `form:"page_id" binding:"required,min=1"`
-	PageSize int32 `form:"page_size" binding:"required,min=5,max=10"`
+	Owner string `form:"owner" binding:"omitempty,alphanum"`
+	pageRequest
+}
+
+type listAccountResponse struct {
+	Accounts   []db.Account `json:"accounts"`
+	NextCursor string       `json:"next_cursor,omitempty"`
 }
 
 func (server *Server) listAccount(ctx *gin.Context) {
 	var req listAccountRequest
 	if err := ctx.ShouldBindQuery(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		writeBindError(ctx, err)
 		return
 	}
 
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	owner := req.Owner
+	if owner == "" {
+		owner = authPayload.Username
+	}
+	if !server.authorize(ctx, permissionListAccounts, owner) {
+		return
+	}
+
+	scope := "accounts:" + owner
+	page, err := req.query(server.cursors, scope)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, errorCodeInvalidRequest, err)
+		return
+	}
+
 	arg := db.ListAccountsParams{
-		Owner:  authPayload.Username,
-		Limit:  req.PageSize,
-		Offset: (req.PageID - 1) * req.PageSize,
+		Owner:          owner,
+		AfterCreatedAt: page.AfterCreatedAt,
+		AfterID:        page.AfterID,
+		Limit:          page.Limit,
+		Offset:         page.Offset,
 	}
 	accounts, err := server.store.ListAccounts(ctx, arg)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, errorResponse(err))
+		writeStoreError(ctx, err)
+		return
+	}
+
+	if req.isLegacy() {
+		ctx.JSON(http.StatusOK, accounts)
+		return
+	}
+
+	accounts, nextCursor, err := nextPage(server.cursors, req.pageRequest, scope, accounts, func(account db.Account) (time.Time, int64) {
+		return account.CreatedAt.Time, account.ID
+	})
+	if err != nil {
+		writeInternalError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, listAccountResponse{
+		Accounts:   accounts,
+		NextCursor: nextCursor,
+	})
+}
+
+// Freeze or unfreeze an account
+type freezeAccountRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+func (server *Server) freezeAccount(ctx *gin.Context) {
+	server.setAccountFrozen(ctx, true)
+}
+
+func (server *Server) unfreezeAccount(ctx *gin.Context) {
+	server.setAccountFrozen(ctx, false)
+}
+
+func (server *Server) setAccountFrozen(ctx *gin.Context, frozen bool) {
+	var req freezeAccountRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		writeBindError(ctx, err)
+		return
+	}
+
+	account, err := server.store.GetAccount(ctx, req.ID)
+	if err != nil {
+		writeStoreError(ctx, err)
+		return
+	}
+
+	if !server.authorize(ctx, permissionFreezeAccount, account.Owner) {
+		return
+	}
+
+	account, err = server.store.SetAccountFrozen(ctx, db.SetAccountFrozenParams{
+		ID:       account.ID,
+		IsFrozen: frozen,
+	})
+	if err != nil {
+		writeInternalError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, accounts)
+
+	ctx.JSON(http.StatusOK, account)
 }
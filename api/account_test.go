@@ -8,10 +8,12 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
 	mockdb "github.com/niloy104/simplebank/db/mock"
 	db "github.com/niloy104/simplebank/db/sqlc"
 	"github.com/niloy104/simplebank/token"
@@ -35,7 +37,68 @@ func TestGetAccountAPI(t *testing.T) {
 			name:      "OK",
 			accountID: account.ID,
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResopnse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchAccount(t, recorder.Body, account)
+			},
+		},
+		{
+			name:      "WithHolds",
+			accountID: account.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				held := account
+				held.HeldAmount = held.Balance / 2
+				held.AvailableBalance = held.Balance - held.HeldAmount
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(held, nil)
+			},
+			checkResopnse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				// both the ledger and the available balance are returned
+				var body map[string]any
+				err := json.Unmarshal(recorder.Body.Bytes(), &body)
+				require.NoError(t, err)
+				require.Equal(t, float64(account.Balance), body["balance"])
+				require.Equal(t, float64(account.Balance/2), body["held_amount"])
+				require.Equal(t, float64(account.Balance-account.Balance/2), body["available_balance"])
+			},
+		},
+		{
+			name:      "UnauthorizedUser",
+			accountID: account.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, "unauthorized_user", util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResopnse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				requireErrorCode(t, recorder, errorCodePermissionDenied)
+			},
+		},
+		{
+			name:      "BankerReadsOtherAccount",
+			accountID: account.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, "banker", util.BankerRole, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
@@ -52,23 +115,24 @@ func TestGetAccountAPI(t *testing.T) {
 			name:      "Not Found",
 			accountID: account.ID,
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
 					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
 					Times(1).
-					Return(db.Account{}, sql.ErrNoRows)
+					Return(db.Account{}, db.ErrRecordNotFound)
 			},
 			checkResopnse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusNotFound, recorder.Code)
+				requireErrorCode(t, recorder, errorCodeNotFound)
 			},
 		},
 		{
 			name:      "InternalError",
 			accountID: account.ID,
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
@@ -78,13 +142,14 @@ func TestGetAccountAPI(t *testing.T) {
 			},
 			checkResopnse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+				requireErrorCode(t, recorder, errorCodeInternal)
 			},
 		},
 		{
 			name:      "InvalidId",
 			accountID: 0,
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
@@ -93,6 +158,7 @@ func TestGetAccountAPI(t *testing.T) {
 			},
 			checkResopnse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				requireErrorCode(t, recorder, errorCodeValidationFailed)
 			},
 		},
 		
@@ -126,6 +192,7 @@ func TestGetAccountAPI(t *testing.T) {
 func randomUser(t *testing.T) (db.User, error) {
 	return db.User{
 		Username: util.RandomOwner(),
+		Role:     util.DepositorRole,
 	}, nil
 }
 
@@ -167,7 +234,7 @@ func TestCreateAccountAPI(t *testing.T) {
 				"currency": account.Currency,
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				arg := db.CreateAccountParams{
@@ -241,7 +308,7 @@ func TestListAccountsAPI(t *testing.T) {
 				"page_size": n,
 			},
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, time.Minute)
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				arg := db.ListAccountsParams{
@@ -259,6 +326,51 @@ func TestListAccountsAPI(t *testing.T) {
 				requireBodyMatchAccounts(t, recorder.Body, accounts)
 			},
 		},
+		{
+			name: "BankerListsOtherOwner",
+			query: gin.H{
+				"owner":     user.Username,
+				"page_id":   1,
+				"page_size": n,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, "banker", util.BankerRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				arg := db.ListAccountsParams{
+					Owner:  user.Username,
+					Limit:  int32(n),
+					Offset: 0,
+				}
+				store.EXPECT().
+					ListAccounts(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(accounts, nil)
+			},
+			checkResopnse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchAccounts(t, recorder.Body, accounts)
+			},
+		},
+		{
+			name: "DepositorListsOtherOwner",
+			query: gin.H{
+				"owner":     "otheruser",
+				"page_id":   1,
+				"page_size": n,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAccounts(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResopnse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
 	}
 
 	for i := range testCases {
@@ -301,3 +413,338 @@ func requireBodyMatchAccounts(t *testing.T, body *bytes.Buffer, accounts []db.Ac
 	require.NoError(t, err)
 	require.Equal(t, accounts, gotAccounts)
 }
+
+// TestFreezeAccountAPI tests the freeze and unfreeze account APIs
+func TestFreezeAccountAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+
+	frozenAccount := account
+	frozenAccount.IsFrozen = true
+
+	testCases := []struct {
+		name          string
+		action        string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResopnse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "BankerFreezes",
+			action: "freeze",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, "banker", util.BankerRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					SetAccountFrozen(gomock.Any(), gomock.Eq(db.SetAccountFrozenParams{ID: account.ID, IsFrozen: true})).
+					Times(1).
+					Return(frozenAccount, nil)
+			},
+			checkResopnse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchAccount(t, recorder.Body, frozenAccount)
+			},
+		},
+		{
+			name:   "BankerUnfreezes",
+			action: "unfreeze",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, "banker", util.BankerRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(frozenAccount, nil)
+				store.EXPECT().
+					SetAccountFrozen(gomock.Any(), gomock.Eq(db.SetAccountFrozenParams{ID: account.ID, IsFrozen: false})).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResopnse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchAccount(t, recorder.Body, account)
+			},
+		},
+		{
+			name:   "OwnerCannotFreeze",
+			action: "freeze",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					SetAccountFrozen(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResopnse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:   "NotFound",
+			action: "freeze",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, "banker", util.BankerRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(db.Account{}, db.ErrRecordNotFound)
+				store.EXPECT().
+					SetAccountFrozen(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResopnse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+				requireErrorCode(t, recorder, errorCodeNotFound)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/accounts/%d/%s", account.ID, tc.action)
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResopnse(t, recorder)
+		})
+	}
+}
+
+func TestCreateAccountIdempotencyAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+	account.Balance = 0
+	idempotencyKey := util.RandomString(16)
+
+	req := createAccountRequest{Currency: account.Currency}
+	requestHash := testRequestHash(t, http.MethodPost, "/accounts", req)
+
+	responseBody, err := json.Marshal(account)
+	require.NoError(t, err)
+
+	keyArg := db.GetIdempotencyKeyParams{
+		Username: user.Username,
+		Key:      idempotencyKey,
+	}
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "FirstRequest",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetIdempotencyKey(gomock.Any(), gomock.Eq(keyArg)).
+					Times(1).
+					Return(db.IdempotencyKey{}, db.ErrRecordNotFound)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+
+				arg := db.IdempotentCreateAccountTxParams{
+					CreateAccountParams: db.CreateAccountParams{
+						Owner:    user.Username,
+						Currency: account.Currency,
+						Balance:  0,
+					},
+					Idempotency: db.IdempotencyParams{
+						Username:    user.Username,
+						Key:         idempotencyKey,
+						RequestHash: requestHash,
+					},
+				}
+				store.EXPECT().
+					IdempotentCreateAccountTx(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(account, false, nil)
+				store.EXPECT().
+					CreateAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+				require.Empty(t, recorder.Header().Get(idempotentReplayedHeader))
+				requireBodyMatchAccount(t, recorder.Body, account)
+			},
+		},
+		{
+			name: "Replayed",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetIdempotencyKey(gomock.Any(), gomock.Eq(keyArg)).
+					Times(1).
+					Return(db.IdempotencyKey{
+						Username:     user.Username,
+						Key:          idempotencyKey,
+						RequestHash:  requestHash,
+						ResponseBody: responseBody,
+					}, nil)
+				store.EXPECT().
+					IdempotentCreateAccountTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+				require.Equal(t, "true", recorder.Header().Get(idempotentReplayedHeader))
+				requireBodyMatchAccount(t, recorder.Body, account)
+			},
+		},
+		{
+			name: "KeyReusedForDifferentRequest",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetIdempotencyKey(gomock.Any(), gomock.Eq(keyArg)).
+					Times(1).
+					Return(db.IdempotencyKey{
+						Username:     user.Username,
+						Key:          idempotencyKey,
+						RequestHash:  "other",
+						ResponseBody: responseBody,
+					}, nil)
+				store.EXPECT().
+					IdempotentCreateAccountTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+				requireErrorCode(t, recorder, errorCodeIdempotencyKeyReused)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(req)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/accounts", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			request.Header.Set(idempotencyKeyHeader, idempotencyKey)
+			addAuhorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListAccountsCursorAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	n := 5
+
+	createdAt := time.Now().UTC().Truncate(time.Microsecond)
+	accounts := make([]db.Account, n+3)
+	for i := range accounts {
+		accounts[i] = randomAccount(user.Username)
+		accounts[i].ID = int64(i + 1)
+		accounts[i].CreatedAt = pgtype.Timestamptz{Time: createdAt.Add(time.Duration(i) * time.Second), Valid: true}
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+
+	listAccounts := func(query url.Values) *httptest.ResponseRecorder {
+		request, err := http.NewRequest(http.MethodGet, "/accounts?"+query.Encode(), nil)
+		require.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+		addAuhorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	// the first page fetches one extra row to find out there is a next page
+	store.EXPECT().
+		ListAccounts(gomock.Any(), gomock.Eq(db.ListAccountsParams{
+			Owner: user.Username,
+			Limit: int32(n + 1),
+		})).
+		Times(1).
+		Return(accounts[:n+1], nil)
+
+	recorder := listAccounts(url.Values{"page_size": {fmt.Sprint(n)}})
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var rsp listAccountResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.Equal(t, accounts[:n], rsp.Accounts)
+	require.NotEmpty(t, rsp.NextCursor)
+
+	// the next page starts after the last account of the first one
+	store.EXPECT().
+		ListAccounts(gomock.Any(), gomock.Eq(db.ListAccountsParams{
+			Owner:          user.Username,
+			AfterCreatedAt: accounts[n-1].CreatedAt,
+			AfterID:        pgtype.Int8{Int64: accounts[n-1].ID, Valid: true},
+			Limit:          int32(n + 1),
+		})).
+		Times(1).
+		Return(accounts[n:], nil)
+
+	recorder = listAccounts(url.Values{"page_size": {fmt.Sprint(n)}, "cursor": {rsp.NextCursor}})
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var lastPage listAccountResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &lastPage))
+	require.Equal(t, accounts[n:], lastPage.Accounts)
+	require.Empty(t, lastPage.NextCursor)
+
+	// invalid cursors are rejected before querying
+	store.EXPECT().
+		ListAccounts(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	recorder = listAccounts(url.Values{"page_size": {fmt.Sprint(n)}, "cursor": {rsp.NextCursor + "x"}})
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = listAccounts(url.Values{"page_size": {fmt.Sprint(n)}, "page_id": {"1"}, "cursor": {rsp.NextCursor}})
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	otherCursor, err := server.cursors.encode(pageCursor{Scope: "accounts:other", CreatedAt: createdAt, ID: 1})
+	require.NoError(t, err)
+	recorder = listAccounts(url.Values{"page_size": {fmt.Sprint(n)}, "cursor": {otherCursor}})
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+}
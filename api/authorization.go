@@ -0,0 +1,91 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/niloy104/simplebank/token"
+	"github.com/niloy104/simplebank/util"
+)
+
+// permission is an operation a user may perform on a resource
+type permission string
+
+const (
+	permissionCreateAccount   permission = "account:create"
+	permissionReadAccount     permission = "account:read"
+	permissionListAccounts    permission = "account:list"
+	permissionTransferFrom    permission = "account:transfer"
+	permissionCreateFXQuote   permission = "fx_quote:create"
+	permissionFreezeAccount   permission = "account:freeze"
+	permissionDeposit         permission = "account:deposit"
+	permissionWithdraw        permission = "account:withdraw"
+	permissionReverseTransfer permission = "transfer:reverse"
+	permissionBlockSession    permission = "session:block"
+
+	permissionReadScheduledTransfer   permission = "scheduled_transfer:read"
+	permissionManageScheduledTransfer permission = "scheduled_transfer:manage"
+)
+
+// ownerPermissions lists what each role may do on resources it owns
+var ownerPermissions = map[string][]permission{
+	util.DepositorRole: {
+		permissionCreateAccount,
+		permissionReadAccount,
+		permissionListAccounts,
+		permissionTransferFrom,
+		permissionCreateFXQuote,
+		permissionBlockSession,
+		permissionReadScheduledTransfer,
+		permissionManageScheduledTransfer,
+	},
+	util.BankerRole: {
+		permissionCreateAccount,
+		permissionReadAccount,
+		permissionListAccounts,
+		permissionTransferFrom,
+		permissionCreateFXQuote,
+		permissionFreezeAccount,
+		permissionReverseTransfer,
+		permissionBlockSession,
+		permissionReadScheduledTransfer,
+		permissionManageScheduledTransfer,
+	},
+}
+
+// anyOwnerPermissions lists what each role may do on resources owned by anyone
+var anyOwnerPermissions = map[string][]permission{
+	util.BankerRole: {
+		permissionReadAccount,
+		permissionListAccounts,
+		permissionFreezeAccount,
+		permissionDeposit,
+		permissionWithdraw,
+		permissionReverseTransfer,
+		permissionReadScheduledTransfer,
+	},
+}
+
+// isAuthorized checks if the token payload grants the permission on a resource of the owner
+func isAuthorized(payload *token.Payload, perm permission, owner string) bool {
+	if slices.Contains(anyOwnerPermissions[payload.Role], perm) {
+		return true
+	}
+
+	return owner == payload.Username && slices.Contains(ownerPermissions[payload.Role], perm)
+}
+
+// authorize checks the authenticated user has the permission on a resource of the owner.
+// It writes a forbidden response and returns false if not.
+func (server *Server) authorize(ctx *gin.Context, perm permission, owner string) bool {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !isAuthorized(authPayload, perm, owner) {
+		err := fmt.Errorf("user %s is not allowed to %s", authPayload.Username, perm)
+		writeError(ctx, http.StatusForbidden, errorCodePermissionDenied, err)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/niloy104/simplebank/token"
+	"github.com/niloy104/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsAuthorized(t *testing.T) {
+	owner := util.RandomOwner()
+	other := util.RandomOwner()
+
+	testCases := []struct {
+		name       string
+		username   string
+		role       string
+		permission permission
+		authorized bool
+	}{
+		{"DepositorReadsOwnAccount", owner, util.DepositorRole, permissionReadAccount, true},
+		{"DepositorReadsOtherAccount", other, util.DepositorRole, permissionReadAccount, false},
+		{"DepositorTransfersFromOwnAccount", owner, util.DepositorRole, permissionTransferFrom, true},
+		{"DepositorFreezesOwnAccount", owner, util.DepositorRole, permissionFreezeAccount, false},
+		{"DepositorReversesTransfer", owner, util.DepositorRole, permissionReverseTransfer, false},
+		{"BankerReadsOtherAccount", other, util.BankerRole, permissionReadAccount, true},
+		{"BankerFreezesOtherAccount", other, util.BankerRole, permissionFreezeAccount, true},
+		{"BankerReversesOtherTransfer", other, util.BankerRole, permissionReverseTransfer, true},
+		{"DepositorDepositsToOwnAccount", owner, util.DepositorRole, permissionDeposit, false},
+		{"DepositorWithdrawsFromOwnAccount", owner, util.DepositorRole, permissionWithdraw, false},
+		{"BankerDepositsToOtherAccount", other, util.BankerRole, permissionDeposit, true},
+		{"BankerWithdrawsFromOtherAccount", other, util.BankerRole, permissionWithdraw, true},
+		{"BankerTransfersFromOtherAccount", other, util.BankerRole, permissionTransferFrom, false},
+		{"DepositorManagesOwnScheduledTransfer", owner, util.DepositorRole, permissionManageScheduledTransfer, true},
+		{"DepositorReadsOtherScheduledTransfer", other, util.DepositorRole, permissionReadScheduledTransfer, false},
+		{"BankerReadsOtherScheduledTransfer", other, util.BankerRole, permissionReadScheduledTransfer, true},
+		{"BankerManagesOtherScheduledTransfer", other, util.BankerRole, permissionManageScheduledTransfer, false},
+		{"UnknownRole", owner, "unknown", permissionReadAccount, false},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			payload, err := token.NewPayload(tc.username, tc.role, uuid.New(), time.Minute, token.TokenTypeAccessToken)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.authorized, isAuthorized(payload, tc.permission, owner))
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	server := newTestServer(t, nil)
+	server.router.GET("/owned/:owner", authMiddleware(server.tokenMaker, server.denylist), func(ctx *gin.Context) {
+		if !server.authorize(ctx, permissionReadAccount, ctx.Param("owner")) {
+			return
+		}
+		ctx.Status(http.StatusOK)
+	})
+
+	owner := util.RandomOwner()
+	testCases := []struct {
+		name  string
+		path  string
+		check func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Owner",
+			path: "/owned/" + owner,
+			check: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			// the user is authenticated, so being denied is forbidden rather than unauthorized
+			name: "OtherOwner",
+			path: "/owned/" + util.RandomOwner(),
+			check: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				requireErrorCode(t, recorder, errorCodePermissionDenied)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			require.NoError(t, err)
+			addAuhorization(t, request, server.tokenMaker, authorizationTypeBearer, owner, util.DepositorRole, time.Minute)
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.check(t, recorder)
+		})
+	}
+}
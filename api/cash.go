@@ -0,0 +1,97 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/niloy104/simplebank/db/sqlc"
+)
+
+// Deposit to or withdraw from an account
+type cashAccountRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type cashRequest struct {
+	Amount   int64  `json:"amount" binding:"required,gt=0"`
+	Currency string `json:"currency" binding:"required,currency"`
+}
+
+type cashResponse struct {
+	Account  db.Account  `json:"account"`
+	Entry    db.Entry    `json:"entry"`
+	Transfer db.Transfer `json:"transfer"`
+}
+
+func newCashResponse(result db.CashTxResult) cashResponse {
+	return cashResponse{
+		Account:  result.Account,
+		Entry:    result.Entry,
+		Transfer: result.Transfer,
+	}
+}
+
+func (server *Server) createDeposit(ctx *gin.Context) {
+	accountID, req, ok := server.bindCashRequest(ctx, permissionDeposit)
+	if !ok {
+		return
+	}
+
+	result, err := server.store.DepositTx(ctx, db.DepositTxParams{
+		AccountID: accountID,
+		Amount:    req.Amount,
+	})
+	if err != nil {
+		writeStoreError(ctx, err)
+		return
+	}
+
+	server.metrics.moneyMoved(movementDeposit, result.Account.Currency, result.Transfer.Amount)
+	ctx.JSON(http.StatusCreated, newCashResponse(result))
+}
+
+func (server *Server) createWithdrawal(ctx *gin.Context) {
+	accountID, req, ok := server.bindCashRequest(ctx, permissionWithdraw)
+	if !ok {
+		return
+	}
+
+	result, err := server.store.WithdrawTx(ctx, db.WithdrawTxParams{
+		AccountID: accountID,
+		Amount:    req.Amount,
+	})
+	if err != nil {
+		writeStoreError(ctx, err)
+		return
+	}
+
+	server.metrics.moneyMoved(movementWithdrawal, result.Account.Currency, result.Transfer.Amount)
+	ctx.JSON(http.StatusCreated, newCashResponse(result))
+}
+
+// bindCashRequest validates a deposit or withdrawal request and checks the user has the permission on the account.
+// It writes an error response and returns false if the request cannot go ahead.
+func (server *Server) bindCashRequest(ctx *gin.Context, perm permission) (int64, cashRequest, bool) {
+	var uri cashAccountRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		writeBindError(ctx, err)
+		return 0, cashRequest{}, false
+	}
+
+	var req cashRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		writeBindError(ctx, err)
+		return 0, req, false
+	}
+
+	account, valid := server.validAccount(ctx, uri.ID, req.Currency)
+	if !valid {
+		return 0, req, false
+	}
+
+	if !server.authorize(ctx, perm, account.Owner) {
+		return 0, req, false
+	}
+
+	return account.ID, req, true
+}
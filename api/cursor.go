@@ -0,0 +1,151 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const minCursorKeySize = 32
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// pageCursor is the (created_at, id) keyset position of the last row of a page.
+// Scope ties the cursor to the list it was issued for.
+type pageCursor struct {
+	Scope     string    `json:"s"`
+	CreatedAt time.Time `json:"t"`
+	ID        int64     `json:"i"`
+}
+
+// cursorSigner encodes page cursors into opaque tokens signed with HMAC-SHA256,
+// so clients cannot forge a position in someone else's list.
+type cursorSigner struct {
+	key []byte
+}
+
+func newCursorSigner(key string) (*cursorSigner, error) {
+	if len(key) < minCursorKeySize {
+		return nil, fmt.Errorf("invalid key size: must be at least %d characters", minCursorKeySize)
+	}
+
+	return &cursorSigner{key: []byte(key)}, nil
+}
+
+// encode returns the opaque token of the cursor
+func (signer *cursorSigner) encode(cursor pageCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	signature := base64.RawURLEncoding.EncodeToString(signer.sign(payload))
+	return payload + "." + signature, nil
+}
+
+// decode verifies the token and returns its cursor, if it was issued for the scope
+func (signer *cursorSigner) decode(token string, scope string) (pageCursor, error) {
+	var cursor pageCursor
+
+	payload, signature, found := strings.Cut(token, ".")
+	if !found {
+		return cursor, errInvalidCursor
+	}
+
+	gotSignature, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(gotSignature, signer.sign(payload)) {
+		return cursor, errInvalidCursor
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return cursor, errInvalidCursor
+	}
+
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Scope != scope {
+		return pageCursor{}, errInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+func (signer *cursorSigner) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, signer.key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// pageRequest holds the pagination parameters of list endpoints.
+// Sending page_id keeps the legacy offset pagination; otherwise pages are walked with cursor.
+type pageRequest struct {
+	PageID   int32  `form:"page_id" binding:"omitempty,min=1"`
+	PageSize int32  `form:"page_size" binding:"required,min=5,max=10"`
+	Cursor   string `form:"cursor"`
+}
+
+// pageQuery is the position and size of a page, as passed to the list queries
+type pageQuery struct {
+	AfterCreatedAt pgtype.Timestamptz
+	AfterID        pgtype.Int8
+	Limit          int32
+	Offset         int32
+}
+
+func (req pageRequest) isLegacy() bool {
+	return req.PageID > 0
+}
+
+// query returns where the page of the scoped list starts.
+// In cursor mode one extra row is fetched to tell whether a next page exists.
+func (req pageRequest) query(signer *cursorSigner, scope string) (pageQuery, error) {
+	if req.isLegacy() {
+		if req.Cursor != "" {
+			return pageQuery{}, errors.New("page_id and cursor cannot be used together")
+		}
+
+		return pageQuery{
+			Limit:  req.PageSize,
+			Offset: (req.PageID - 1) * req.PageSize,
+		}, nil
+	}
+
+	query := pageQuery{Limit: req.PageSize + 1}
+	if req.Cursor == "" {
+		return query, nil
+	}
+
+	cursor, err := signer.decode(req.Cursor, scope)
+	if err != nil {
+		return pageQuery{}, err
+	}
+
+	query.AfterCreatedAt = pgtype.Timestamptz{Time: cursor.CreatedAt, Valid: true}
+	query.AfterID = pgtype.Int8{Int64: cursor.ID, Valid: true}
+	return query, nil
+}
+
+// nextPage trims the extra row fetched in cursor mode and returns the cursor of the next page,
+// or an empty cursor on the last page. position returns the keyset position of a row.
+func nextPage[T any](signer *cursorSigner, req pageRequest, scope string, rows []T, position func(T) (time.Time, int64)) ([]T, string, error) {
+	if len(rows) <= int(req.PageSize) {
+		return rows, "", nil
+	}
+
+	rows = rows[:req.PageSize]
+	createdAt, id := position(rows[len(rows)-1])
+
+	nextCursor, err := signer.encode(pageCursor{
+		Scope:     scope,
+		CreatedAt: createdAt,
+		ID:        id,
+	})
+	return rows, nextCursor, err
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/niloy104/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCursorSigner(t *testing.T) *cursorSigner {
+	signer, err := newCursorSigner(util.RandomString(minCursorKeySize))
+	require.NoError(t, err)
+	return signer
+}
+
+func TestCursorSigner(t *testing.T) {
+	signer := newTestCursorSigner(t)
+
+	cursor := pageCursor{
+		Scope:     "accounts:" + util.RandomOwner(),
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+		ID:        util.RandomInt(1, 1000),
+	}
+
+	token, err := signer.encode(cursor)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+
+	gotCursor, err := signer.decode(token, cursor.Scope)
+	require.NoError(t, err)
+	require.Equal(t, cursor.Scope, gotCursor.Scope)
+	require.Equal(t, cursor.ID, gotCursor.ID)
+	require.True(t, cursor.CreatedAt.Equal(gotCursor.CreatedAt))
+
+	// a cursor cannot be used on another list
+	_, err = signer.decode(token, "accounts:"+util.RandomOwner())
+	require.ErrorIs(t, err, errInvalidCursor)
+
+	// a cursor signed with another key is rejected
+	_, err = newTestCursorSigner(t).decode(token, cursor.Scope)
+	require.ErrorIs(t, err, errInvalidCursor)
+}
+
+func TestCursorSignerTampered(t *testing.T) {
+	signer := newTestCursorSigner(t)
+	scope := "entries:1"
+
+	token, err := signer.encode(pageCursor{Scope: scope, CreatedAt: time.Now(), ID: 1})
+	require.NoError(t, err)
+
+	forged, err := newTestCursorSigner(t).encode(pageCursor{Scope: scope, CreatedAt: time.Now(), ID: 1000})
+	require.NoError(t, err)
+
+	payload := token[:len(token)/2]
+	for _, invalid := range []string{
+		"",
+		"invalid",
+		payload,
+		"." + token,
+		forged[:len(forged)-1] + token[len(token)-1:],
+		forged[:len(forged)-43] + token[len(token)-43:],
+	} {
+		_, err := signer.decode(invalid, scope)
+		require.ErrorIs(t, err, errInvalidCursor, invalid)
+	}
+}
+
+func TestNewCursorSignerInvalidKey(t *testing.T) {
+	_, err := newCursorSigner(util.RandomString(minCursorKeySize - 1))
+	require.Error(t, err)
+}
+
+func TestPageRequestQuery(t *testing.T) {
+	signer := newTestCursorSigner(t)
+	scope := "transfers:1"
+
+	// legacy offset pagination
+	page, err := pageRequest{PageID: 3, PageSize: 5}.query(signer, scope)
+	require.NoError(t, err)
+	require.Equal(t, int32(5), page.Limit)
+	require.Equal(t, int32(10), page.Offset)
+	require.False(t, page.AfterCreatedAt.Valid)
+
+	// the first cursor page fetches one extra row
+	page, err = pageRequest{PageSize: 5}.query(signer, scope)
+	require.NoError(t, err)
+	require.Equal(t, int32(6), page.Limit)
+	require.Zero(t, page.Offset)
+	require.False(t, page.AfterCreatedAt.Valid)
+	require.False(t, page.AfterID.Valid)
+
+	createdAt := time.Now().UTC()
+	token, err := signer.encode(pageCursor{Scope: scope, CreatedAt: createdAt, ID: 42})
+	require.NoError(t, err)
+
+	page, err = pageRequest{PageSize: 5, Cursor: token}.query(signer, scope)
+	require.NoError(t, err)
+	require.Equal(t, int32(6), page.Limit)
+	require.True(t, page.AfterCreatedAt.Valid)
+	require.True(t, createdAt.Equal(page.AfterCreatedAt.Time))
+	require.Equal(t, int64(42), page.AfterID.Int64)
+
+	_, err = pageRequest{PageID: 1, PageSize: 5, Cursor: token}.query(signer, scope)
+	require.Error(t, err)
+
+	_, err = pageRequest{PageSize: 5, Cursor: "invalid"}.query(signer, scope)
+	require.ErrorIs(t, err, errInvalidCursor)
+}
+
+func TestNextPage(t *testing.T) {
+	signer := newTestCursorSigner(t)
+	scope := "entries:1"
+	req := pageRequest{PageSize: 5}
+
+	createdAt := time.Now().UTC()
+	position := func(id int64) (time.Time, int64) {
+		return createdAt, id
+	}
+
+	// last page
+	rows, nextCursor, err := nextPage(signer, req, scope, []int64{1, 2, 3}, position)
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 2, 3}, rows)
+	require.Empty(t, nextCursor)
+
+	// the extra row is trimmed and the cursor points at the last returned row
+	rows, nextCursor, err = nextPage(signer, req, scope, []int64{1, 2, 3, 4, 5, 6}, position)
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 2, 3, 4, 5}, rows)
+	require.NotEmpty(t, nextCursor)
+
+	cursor, err := signer.decode(nextCursor, scope)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), cursor.ID)
+	require.True(t, createdAt.Equal(cursor.CreatedAt))
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// SyncDenylist periodically loads revoked tokens from the database into the
+// in-memory denylist, so revocations made by other replicas are enforced.
+// Revocations of tokens that have expired are deleted, so the table does not grow forever.
+// It blocks until the context is cancelled.
+func (server *Server) SyncDenylist(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := server.refreshDenylist(ctx); err != nil {
+			slog.ErrorContext(ctx, "cannot refresh token denylist", slog.Any("error", err))
+		}
+		if err := server.store.DeleteExpiredRevokedTokens(ctx); err != nil {
+			slog.ErrorContext(ctx, "cannot delete expired revoked tokens", slog.Any("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (server *Server) refreshDenylist(ctx context.Context) error {
+	now := time.Now()
+	maxTokenDuration := max(server.config.AccessTokenDuration, server.config.RefreshTokenDuration)
+
+	tokens, err := server.store.ListRevokedTokens(ctx)
+	if err != nil {
+		return err
+	}
+
+	since := pgtype.Timestamptz{Time: now.Add(-maxTokenDuration), Valid: true}
+	users, err := server.store.ListUserTokenRevocations(ctx, since)
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		server.denylist.Revoke(token.ID, token.ExpiresAt.Time)
+	}
+	for _, user := range users {
+		server.denylist.RevokeUser(user.Username, user.TokensRevokedAt.Time)
+	}
+
+	server.denylist.Prune(now, maxTokenDuration)
+	return nil
+}
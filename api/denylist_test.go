@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/niloy104/simplebank/db/mock"
+	db "github.com/niloy104/simplebank/db/sqlc"
+	"github.com/niloy104/simplebank/token"
+	"github.com/niloy104/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRefreshDenylist(t *testing.T) {
+	revokedPayload, err := token.NewPayload(util.RandomOwner(), util.DepositorRole, uuid.New(), time.Minute, token.TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	loggedOutPayload, err := token.NewPayload(util.RandomOwner(), util.DepositorRole, uuid.New(), time.Minute, token.TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	validPayload, err := token.NewPayload(util.RandomOwner(), util.DepositorRole, uuid.New(), time.Minute, token.TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		ListRevokedTokens(gomock.Any()).
+		Times(1).
+		Return([]db.RevokedToken{
+			{
+				ID:        revokedPayload.ID,
+				Username:  revokedPayload.Username,
+				ExpiresAt: pgtype.Timestamptz{Time: revokedPayload.ExpiredAt, Valid: true},
+			},
+		}, nil)
+	store.EXPECT().
+		ListUserTokenRevocations(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return([]db.ListUserTokenRevocationsRow{
+			{
+				Username:        loggedOutPayload.Username,
+				TokensRevokedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+			},
+		}, nil)
+
+	server := newTestServer(t, store)
+	err = server.refreshDenylist(context.Background())
+	require.NoError(t, err)
+
+	require.True(t, server.denylist.IsRevoked(revokedPayload))
+	require.True(t, server.denylist.IsRevoked(loggedOutPayload))
+	require.False(t, server.denylist.IsRevoked(validPayload))
+}
+
+func TestRefreshDenylistError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		ListRevokedTokens(gomock.Any()).
+		Times(1).
+		Return(nil, sql.ErrConnDone)
+	store.EXPECT().
+		ListUserTokenRevocations(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	server := newTestServer(t, store)
+	err := server.refreshDenylist(context.Background())
+	require.ErrorIs(t, err, sql.ErrConnDone)
+}
+
+func TestSyncDenylistDeletesExpiredTokens(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		ListRevokedTokens(gomock.Any()).
+		Times(1).
+		Return(nil, nil)
+	store.EXPECT().
+		ListUserTokenRevocations(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(nil, nil)
+	store.EXPECT().
+		DeleteExpiredRevokedTokens(gomock.Any()).
+		Times(1).
+		DoAndReturn(func(context.Context) error {
+			// stop the worker after its first run
+			cancel()
+			return nil
+		})
+
+	server := newTestServer(t, store)
+	server.SyncDenylist(ctx, time.Hour)
+}
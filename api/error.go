@@ -0,0 +1,221 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	db "github.com/niloy104/simplebank/db/sqlc"
+	"github.com/niloy104/simplebank/util"
+	"google.golang.org/grpc/codes"
+)
+
+// apiError is the body of every error response.
+// Code is stable for clients to branch on, while Message is meant for humans and may change.
+type apiError struct {
+	Code        string         `json:"code"`
+	Message     string         `json:"message"`
+	Details     map[string]any `json:"details,omitempty"`
+	RequestID   string         `json:"request_id,omitempty"`
+	FieldErrors []fieldError   `json:"field_errors,omitempty"`
+}
+
+// fieldError reports a request field that failed a validation rule
+type fieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// error codes let clients tell failures apart without parsing the message
+const (
+	errorCodeInvalidRequest   = "invalid_request"
+	errorCodeValidationFailed = "validation_failed"
+	errorCodeUnauthenticated  = "unauthenticated"
+	errorCodePermissionDenied = "permission_denied"
+	errorCodeNotFound         = "not_found"
+	errorCodeAlreadyExists    = "already_exists"
+	errorCodeInvalidReference = "invalid_reference"
+	errorCodeInternal         = "internal"
+	errorCodeRateLimited      = "rate_limited"
+	errorCodeAccountLocked    = "account_locked"
+
+	errorCodeCurrencyMismatch     = "currency_mismatch"
+	errorCodeAccountFrozen        = "account_frozen"
+	errorCodeSystemAccount        = "system_account"
+	errorCodeIdempotencyKeyReused = "idempotency_key_reused"
+
+	errorCodeInsufficientFunds = "insufficient_funds"
+	errorCodeFXRateUnavailable = "fx_rate_unavailable"
+	errorCodeInvalidFXQuote    = "invalid_fx_quote"
+	errorCodeFXAmountTooSmall  = "fx_amount_too_small"
+
+	errorCodeTransferAlreadyReversed = "transfer_already_reversed"
+	errorCodeReversalTooLarge        = "reversal_too_large"
+	errorCodeTransferNotReversible   = "transfer_not_reversible"
+	errorCodeInvalidReversalAmount   = "invalid_reversal_amount"
+)
+
+var errInternal = errors.New("internal server error")
+
+// storeErrors maps the errors of the store to the status and code of their response,
+// and to the status code of their gRPC response
+var storeErrors = []struct {
+	err      error
+	status   int
+	code     string
+	grpcCode codes.Code
+}{
+	{db.ErrRecordNotFound, http.StatusNotFound, errorCodeNotFound, codes.NotFound},
+	{db.ErrUniqueViolation, http.StatusConflict, errorCodeAlreadyExists, codes.AlreadyExists},
+	{db.ErrForeignKeyViolation, http.StatusUnprocessableEntity, errorCodeInvalidReference, codes.FailedPrecondition},
+	{db.ErrInsufficientFunds, http.StatusUnprocessableEntity, errorCodeInsufficientFunds, codes.FailedPrecondition},
+	{db.ErrAccountFrozen, http.StatusForbidden, errorCodeAccountFrozen, codes.FailedPrecondition},
+	{db.ErrSystemAccount, http.StatusForbidden, errorCodeSystemAccount, codes.FailedPrecondition},
+	{db.ErrIdempotencyKeyReused, http.StatusConflict, errorCodeIdempotencyKeyReused, codes.FailedPrecondition},
+	{db.ErrInvalidFXQuote, http.StatusUnprocessableEntity, errorCodeInvalidFXQuote, codes.FailedPrecondition},
+	{db.ErrFXAmountTooSmall, http.StatusUnprocessableEntity, errorCodeFXAmountTooSmall, codes.InvalidArgument},
+	{db.ErrTransferAlreadyReversed, http.StatusConflict, errorCodeTransferAlreadyReversed, codes.FailedPrecondition},
+	{db.ErrReversalTooLarge, http.StatusUnprocessableEntity, errorCodeReversalTooLarge, codes.FailedPrecondition},
+	{db.ErrTransferNotReversible, http.StatusUnprocessableEntity, errorCodeTransferNotReversible, codes.FailedPrecondition},
+	{db.ErrInvalidReversalAmount, http.StatusBadRequest, errorCodeInvalidReversalAmount, codes.InvalidArgument},
+}
+
+// writeError aborts the request with an error response
+func writeError(ctx *gin.Context, status int, code string, err error) {
+	writeAPIError(ctx, status, apiError{Code: code, Message: err.Error()})
+}
+
+func writeAPIError(ctx *gin.Context, status int, apiErr apiError) {
+	apiErr.RequestID = ctx.GetString(requestIDKey)
+	ctx.AbortWithStatusJSON(status, apiErr)
+}
+
+// writeInternalError logs the error and aborts the request with a generic response,
+// so database and other internal messages never reach the client
+func writeInternalError(ctx *gin.Context, err error) {
+	slog.ErrorContext(ctx, "internal error",
+		slog.String("method", ctx.Request.Method),
+		slog.String("route", ctx.FullPath()),
+		slog.Any("error", err),
+	)
+	writeError(ctx, http.StatusInternalServerError, errorCodeInternal, errInternal)
+}
+
+// writeBindError aborts the request with the error of binding it.
+// Validation errors are reported field by field.
+func writeBindError(ctx *gin.Context, err error) {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		writeError(ctx, http.StatusBadRequest, errorCodeInvalidRequest, err)
+		return
+	}
+
+	apiErr := apiError{
+		Code:    errorCodeValidationFailed,
+		Message: "request validation failed",
+	}
+	for _, fe := range validationErrors {
+		apiErr.FieldErrors = append(apiErr.FieldErrors, fieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Message: fieldErrorMessage(fe),
+		})
+	}
+	writeAPIError(ctx, http.StatusBadRequest, apiErr)
+}
+
+// writeStoreError aborts the request with the response mapped to an error of the store.
+// Errors without a mapping are internal.
+func writeStoreError(ctx *gin.Context, err error) {
+	for _, known := range storeErrors {
+		if errors.Is(err, known.err) {
+			writeError(ctx, known.status, known.code, known.err)
+			return
+		}
+	}
+
+	writeInternalError(ctx, err)
+}
+
+// fieldErrorMessage describes the rule a field failed
+func fieldErrorMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_without_all":
+		return "is required when none of " + strings.Join(fieldParamNames(fe.Param()), ", ") + " is set"
+	case "min", "gte":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters long", fe.Param())
+		}
+		return "must be at least " + fe.Param()
+	case "max", "lte":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters long", fe.Param())
+		}
+		return "must be at most " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "alphanum":
+		return "must contain only letters and digits"
+	case "email":
+		return "must be a valid email address"
+	case "uuid":
+		return "must be a valid UUID"
+	case "currency":
+		return "must be one of " + strings.Join(util.SupportedCurrencies, ", ")
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "cron":
+		return "must be a standard 5-field cron expression"
+	case "nefield":
+		return "must differ from " + strings.Join(fieldParamNames(fe.Param()), ", ")
+	case "excluded_with":
+		return "cannot be set together with " + strings.Join(fieldParamNames(fe.Param()), ", ")
+	}
+
+	return "must satisfy " + fe.Tag()
+}
+
+// fieldTagName names the fields of validation errors as clients send them,
+// from their json, form or uri tag
+func fieldTagName(field reflect.StructField) string {
+	for _, key := range []string{"json", "form", "uri"} {
+		name, _, _ := strings.Cut(field.Tag.Get(key), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// fieldParamNames converts the Go field names in the parameter of a rule
+// to the snake_case names clients send, e.g. FromAccountID to from_account_id
+func fieldParamNames(param string) []string {
+	var names []string
+	for _, field := range strings.Fields(param) {
+		var name strings.Builder
+		for i, r := range field {
+			if i > 0 && unicode.IsUpper(r) {
+				prev := rune(field[i-1])
+				nextIsLower := i+1 < len(field) && unicode.IsLower(rune(field[i+1]))
+				if unicode.IsLower(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+					name.WriteByte('_')
+				}
+			}
+			name.WriteRune(unicode.ToLower(r))
+		}
+		names = append(names, name.String())
+	}
+	return names
+}
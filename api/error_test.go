@@ -0,0 +1,169 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	mockdb "github.com/niloy104/simplebank/db/mock"
+	db "github.com/niloy104/simplebank/db/sqlc"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func requireErrorCode(t *testing.T, recorder *httptest.ResponseRecorder, code string) {
+	var body gin.H
+	err := json.Unmarshal(recorder.Body.Bytes(), &body)
+	require.NoError(t, err)
+	require.Equal(t, code, body["code"])
+}
+
+func requireAPIError(t *testing.T, recorder *httptest.ResponseRecorder) apiError {
+	var apiErr apiError
+	err := json.Unmarshal(recorder.Body.Bytes(), &apiErr)
+	require.NoError(t, err)
+	return apiErr
+}
+
+func TestWriteStoreError(t *testing.T) {
+	testCases := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"RecordNotFound", db.ErrRecordNotFound, http.StatusNotFound, errorCodeNotFound},
+		{"WrappedRecordNotFound", fmt.Errorf("get account: %w", db.ErrRecordNotFound), http.StatusNotFound, errorCodeNotFound},
+		{"UniqueViolation", db.ErrUniqueViolation, http.StatusConflict, errorCodeAlreadyExists},
+		{"ForeignKeyViolation", db.ErrForeignKeyViolation, http.StatusUnprocessableEntity, errorCodeInvalidReference},
+		{"InsufficientFunds", fmt.Errorf("transfer tx: %w", db.ErrInsufficientFunds), http.StatusUnprocessableEntity, errorCodeInsufficientFunds},
+		{"IdempotencyKeyReused", db.ErrIdempotencyKeyReused, http.StatusConflict, errorCodeIdempotencyKeyReused},
+		{"OtherPostgresError", &pgconn.PgError{Code: "42P01", Message: "relation \"accounts\" does not exist"}, http.StatusInternalServerError, errorCodeInternal},
+		{"Unknown", errors.New("connection reset by peer"), http.StatusInternalServerError, errorCodeInternal},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(recorder)
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+			writeStoreError(ctx, tc.err)
+			require.Equal(t, tc.status, recorder.Code)
+
+			apiErr := requireAPIError(t, recorder)
+			require.Equal(t, tc.code, apiErr.Code)
+			require.NotContains(t, apiErr.Message, "relation")
+			require.NotContains(t, apiErr.Message, "connection reset")
+		})
+	}
+}
+
+func TestValidationFieldErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		CreateUser(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	data, err := json.Marshal(gin.H{
+		"username":  "invalid-user#1",
+		"password":  "123",
+		"full_name": "",
+		"email":     "invalid-email",
+	})
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPost, "/users", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	apiErr := requireAPIError(t, recorder)
+	require.Equal(t, errorCodeValidationFailed, apiErr.Code)
+
+	rules := map[string]string{}
+	for _, fieldErr := range apiErr.FieldErrors {
+		require.NotEmpty(t, fieldErr.Message)
+		rules[fieldErr.Field] = fieldErr.Rule
+	}
+	require.Equal(t, map[string]string{
+		"username":  "alphanum",
+		"password":  "min",
+		"full_name": "required",
+		"email":     "email",
+	}, rules)
+}
+
+func TestRequestIDInErrors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		requestID     string
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:      "Generated",
+			requestID: "",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requestID := recorder.Header().Get(requestIDHeader)
+				require.NotEmpty(t, requestID)
+				require.Equal(t, requestID, requireAPIError(t, recorder).RequestID)
+			},
+		},
+		{
+			name:      "FromClient",
+			requestID: "client-request-1",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, "client-request-1", recorder.Header().Get(requestIDHeader))
+				require.Equal(t, "client-request-1", requireAPIError(t, recorder).RequestID)
+			},
+		},
+		{
+			name:      "TooLong",
+			requestID: string(bytes.Repeat([]byte("a"), maxRequestIDLength+1)),
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requestID := recorder.Header().Get(requestIDHeader)
+				require.NotEmpty(t, requestID)
+				require.LessOrEqual(t, len(requestID), maxRequestIDLength)
+				require.Equal(t, requestID, requireAPIError(t, recorder).RequestID)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			server := newTestServer(t, mockdb.NewMockStore(ctrl))
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/accounts/1", nil)
+			require.NoError(t, err)
+			if tc.requestID != "" {
+				request.Header.Set(requestIDHeader, tc.requestID)
+			}
+
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			requireErrorCode(t, recorder, errorCodeUnauthenticated)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/niloy104/simplebank/db/sqlc"
+	"github.com/niloy104/simplebank/token"
+)
+
+// Quote an exchange rate for a cross-currency transfer
+type createFXQuoteRequest struct {
+	FromCurrency string `json:"from_currency" binding:"required,currency"`
+	ToCurrency   string `json:"to_currency" binding:"required,currency,nefield=FromCurrency"`
+}
+
+type fxQuoteResponse struct {
+	ID           uuid.UUID      `json:"id"`
+	FromCurrency string         `json:"from_currency"`
+	ToCurrency   string         `json:"to_currency"`
+	Rate         pgtype.Numeric `json:"rate"`
+	ExpiresAt    time.Time      `json:"expires_at"`
+}
+
+func newFXQuoteResponse(quote db.FxQuote) fxQuoteResponse {
+	return fxQuoteResponse{
+		ID:           quote.ID,
+		FromCurrency: quote.FromCurrency,
+		ToCurrency:   quote.ToCurrency,
+		Rate:         quote.Rate,
+		ExpiresAt:    quote.ExpiresAt.Time,
+	}
+}
+
+// createFXQuote locks the current rate of a currency pair for a short time.
+// The quote ID is then sent with a transfer between accounts in these currencies.
+func (server *Server) createFXQuote(ctx *gin.Context) {
+	var req createFXQuoteRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		writeBindError(ctx, err)
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !server.authorize(ctx, permissionCreateFXQuote, authPayload.Username) {
+		return
+	}
+
+	rate, err := server.fxRates.GetRate(ctx, req.FromCurrency, req.ToCurrency)
+	if err != nil {
+		if errors.Is(err, ErrFXRateNotFound) {
+			writeError(ctx, http.StatusUnprocessableEntity, errorCodeFXRateUnavailable, err)
+			return
+		}
+		writeInternalError(ctx, err)
+		return
+	}
+
+	quoteID, err := uuid.NewRandom()
+	if err != nil {
+		writeInternalError(ctx, err)
+		return
+	}
+
+	quote, err := server.store.CreateFXQuote(ctx, db.CreateFXQuoteParams{
+		ID:           quoteID,
+		Username:     authPayload.Username,
+		FromCurrency: req.FromCurrency,
+		ToCurrency:   req.ToCurrency,
+		Rate:         rate,
+		ExpiresAt:    pgtype.Timestamptz{Time: time.Now().Add(server.config.FXQuoteDuration), Valid: true},
+	})
+	if err != nil {
+		writeInternalError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, newFXQuoteResponse(quote))
+}
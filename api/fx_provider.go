@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/niloy104/simplebank/db/sqlc"
+	"github.com/niloy104/simplebank/util"
+)
+
+// ErrFXRateNotFound is returned when no exchange rate is known for a currency pair
+var ErrFXRateNotFound = errors.New("fx rate not found")
+
+// FXRateProvider is an interface for looking up exchange rates
+type FXRateProvider interface {
+	// GetRate returns how many units of toCurrency one unit of fromCurrency buys
+	GetRate(ctx context.Context, fromCurrency string, toCurrency string) (pgtype.Numeric, error)
+}
+
+// StaticFXRateProvider serves exchange rates loaded from a JSON file, for local runs and tests
+type StaticFXRateProvider struct {
+	rates map[string]map[string]pgtype.Numeric
+}
+
+// NewStaticFXRateProvider loads rates from a JSON file mapping source to destination currencies,
+// like {"USD": {"EUR": "0.92", "BDT": "117.5"}}
+func NewStaticFXRateProvider(path string) (*StaticFXRateProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read fx rates file: %w", err)
+	}
+
+	return ParseStaticFXRates(data)
+}
+
+// ParseStaticFXRates parses rates in the format of the static rates file
+func ParseStaticFXRates(data []byte) (*StaticFXRateProvider, error) {
+	var file map[string]map[string]string
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("cannot parse fx rates: %w", err)
+	}
+
+	provider := &StaticFXRateProvider{rates: make(map[string]map[string]pgtype.Numeric)}
+	for fromCurrency, rates := range file {
+		if !util.IsSupportedCurrency(fromCurrency) {
+			return nil, fmt.Errorf("unsupported currency %s", fromCurrency)
+		}
+
+		provider.rates[fromCurrency] = make(map[string]pgtype.Numeric)
+		for toCurrency, value := range rates {
+			if !util.IsSupportedCurrency(toCurrency) {
+				return nil, fmt.Errorf("unsupported currency %s", toCurrency)
+			}
+
+			rate, err := parseFXRate(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s/%s rate: %w", fromCurrency, toCurrency, err)
+			}
+			provider.rates[fromCurrency][toCurrency] = rate
+		}
+	}
+
+	return provider, nil
+}
+
+// GetRate returns the rate of the currency pair from the file
+func (provider *StaticFXRateProvider) GetRate(ctx context.Context, fromCurrency string, toCurrency string) (pgtype.Numeric, error) {
+	rate, ok := provider.rates[fromCurrency][toCurrency]
+	if !ok {
+		return pgtype.Numeric{}, ErrFXRateNotFound
+	}
+
+	return rate, nil
+}
+
+// DBFXRateProvider serves exchange rates stored in the fx_rates table
+type DBFXRateProvider struct {
+	store db.Querier
+}
+
+// NewDBFXRateProvider creates a rate provider reading from the database
+func NewDBFXRateProvider(store db.Querier) *DBFXRateProvider {
+	return &DBFXRateProvider{store: store}
+}
+
+// GetRate returns the latest rate of the currency pair from the database
+func (provider *DBFXRateProvider) GetRate(ctx context.Context, fromCurrency string, toCurrency string) (pgtype.Numeric, error) {
+	rate, err := provider.store.GetFXRate(ctx, db.GetFXRateParams{
+		FromCurrency: fromCurrency,
+		ToCurrency:   toCurrency,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return pgtype.Numeric{}, ErrFXRateNotFound
+		}
+		return pgtype.Numeric{}, err
+	}
+
+	return rate.Rate, nil
+}
+
+// parseFXRate parses a positive decimal rate
+func parseFXRate(value string) (pgtype.Numeric, error) {
+	var rate pgtype.Numeric
+	if err := rate.Scan(value); err != nil {
+		return rate, err
+	}
+
+	if !rate.Valid || rate.NaN || rate.InfinityModifier != pgtype.Finite || rate.Int.Sign() <= 0 {
+		return pgtype.Numeric{}, errors.New("rate must be a positive number")
+	}
+
+	return rate, nil
+}
+
+// newFXRateProvider reads rates from the configured file, or from the database when there is none
+func newFXRateProvider(config util.Config, store db.Store) (FXRateProvider, error) {
+	if config.FXRatesFile != "" {
+		return NewStaticFXRateProvider(config.FXRatesFile)
+	}
+
+	return NewDBFXRateProvider(store), nil
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/niloy104/simplebank/db/mock"
+	db "github.com/niloy104/simplebank/db/sqlc"
+	"github.com/niloy104/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestStaticFXRateProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fx_rates.json")
+	err := os.WriteFile(path, []byte(`{"USD": {"EUR": "0.92", "BDT": "117.5"}, "EUR": {"USD": "1.087"}}`), 0o600)
+	require.NoError(t, err)
+
+	provider, err := NewStaticFXRateProvider(path)
+	require.NoError(t, err)
+
+	rate, err := provider.GetRate(context.Background(), util.USD, util.BDT)
+	require.NoError(t, err)
+	require.Equal(t, "117.5", numericString(t, rate))
+
+	rate, err = provider.GetRate(context.Background(), util.EUR, util.USD)
+	require.NoError(t, err)
+	require.Equal(t, "1.087", numericString(t, rate))
+
+	_, err = provider.GetRate(context.Background(), util.BDT, util.USD)
+	require.ErrorIs(t, err, ErrFXRateNotFound)
+}
+
+func TestParseStaticFXRatesInvalid(t *testing.T) {
+	for _, data := range []string{
+		`not json`,
+		`{"XYZ": {"EUR": "0.92"}}`,
+		`{"USD": {"XYZ": "0.92"}}`,
+		`{"USD": {"EUR": "abc"}}`,
+		`{"USD": {"EUR": "0"}}`,
+		`{"USD": {"EUR": "-1.5"}}`,
+		`{"USD": {"EUR": "NaN"}}`,
+	} {
+		_, err := ParseStaticFXRates([]byte(data))
+		require.Error(t, err, data)
+	}
+}
+
+func TestNewStaticFXRateProviderMissingFile(t *testing.T) {
+	_, err := NewStaticFXRateProvider(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+}
+
+func TestDBFXRateProvider(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	provider := NewDBFXRateProvider(store)
+
+	rate := randomFXRate(t)
+	store.EXPECT().
+		GetFXRate(gomock.Any(), gomock.Eq(db.GetFXRateParams{FromCurrency: util.USD, ToCurrency: util.EUR})).
+		Times(1).
+		Return(db.FxRate{FromCurrency: util.USD, ToCurrency: util.EUR, Rate: rate}, nil)
+
+	gotRate, err := provider.GetRate(context.Background(), util.USD, util.EUR)
+	require.NoError(t, err)
+	require.Equal(t, rate, gotRate)
+
+	store.EXPECT().
+		GetFXRate(gomock.Any(), gomock.Eq(db.GetFXRateParams{FromCurrency: util.EUR, ToCurrency: util.BDT})).
+		Times(1).
+		Return(db.FxRate{}, db.ErrRecordNotFound)
+
+	_, err = provider.GetRate(context.Background(), util.EUR, util.BDT)
+	require.ErrorIs(t, err, ErrFXRateNotFound)
+
+	store.EXPECT().
+		GetFXRate(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.FxRate{}, sql.ErrConnDone)
+
+	_, err = provider.GetRate(context.Background(), util.BDT, util.USD)
+	require.ErrorIs(t, err, sql.ErrConnDone)
+}
+
+func randomFXRate(t *testing.T) pgtype.Numeric {
+	rate, err := parseFXRate(fmt.Sprintf("%d.%02d", util.RandomInt(1, 200), util.RandomInt(0, 99)))
+	require.NoError(t, err)
+	return rate
+}
+
+func numericString(t *testing.T, n pgtype.Numeric) string {
+	value, err := n.Value()
+	require.NoError(t, err)
+	return value.(string)
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/niloy104/simplebank/pb"
+	"github.com/niloy104/simplebank/token"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// rpcServer serves gRPC requests for our banking service.
+// It shares the store, token maker and denylist of the HTTP server,
+// so both transports apply the same authentication and permissions.
+type rpcServer struct {
+	pb.UnimplementedSimpleBankServer
+	server *Server
+}
+
+// StartGRPC runs the gRPC server on specific address
+func (server *Server) StartGRPC(address string) error {
+	grpcServer := grpc.NewServer()
+	pb.RegisterSimpleBankServer(grpcServer, &rpcServer{server: server})
+	reflection.Register(grpcServer)
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("cannot create listener: %w", err)
+	}
+
+	server.mu.Lock()
+	if server.shuttingDown {
+		server.mu.Unlock()
+		listener.Close()
+		return grpc.ErrServerStopped
+	}
+	server.grpcServers = append(server.grpcServers, grpcServer)
+	server.mu.Unlock()
+
+	return grpcServer.Serve(listener)
+}
+
+// StartGateway runs the grpc-gateway HTTP server on specific address.
+// It translates JSON requests under /v1 into calls to the gRPC handlers.
+func (server *Server) StartGateway(ctx context.Context, address string) error {
+	mux, err := server.newGatewayMux(ctx)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("cannot create listener: %w", err)
+	}
+
+	return server.serveHTTP(listener, mux)
+}
+
+func (server *Server) newGatewayMux(ctx context.Context) (http.Handler, error) {
+	// keep the snake_case field names and zero values of the HTTP API
+	jsonOption := runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+		MarshalOptions: protojson.MarshalOptions{
+			UseProtoNames:   true,
+			EmitUnpopulated: true,
+		},
+		UnmarshalOptions: protojson.UnmarshalOptions{
+			DiscardUnknown: true,
+		},
+	})
+
+	mux := runtime.NewServeMux(jsonOption)
+	err := pb.RegisterSimpleBankHandlerServer(ctx, mux, &rpcServer{server: server})
+	if err != nil {
+		return nil, fmt.Errorf("cannot register gateway handler: %w", err)
+	}
+
+	return server.withGatewayClient(mux)
+}
+
+// authenticate reads the bearer token from the authorization metadata,
+// which the gateway forwards from the authorization header.
+func (rpc *rpcServer) authenticate(ctx context.Context) (*token.Payload, error) {
+	var authorizationHeader string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(authorizationHeaderKey); len(values) > 0 {
+			authorizationHeader = values[0]
+		}
+	}
+
+	payload, err := authenticate(rpc.server.tokenMaker, rpc.server.denylist, authorizationHeader)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return payload, nil
+}
+
+// authorize checks the token payload grants the permission on a resource of the owner
+func (rpc *rpcServer) authorize(payload *token.Payload, perm permission, owner string) error {
+	if !isAuthorized(payload, perm, owner) {
+		return status.Errorf(codes.PermissionDenied, "user %s is not allowed to %s", payload.Username, perm)
+	}
+
+	return nil
+}
+
+// rpcInternalError logs the error and returns a generic Internal status,
+// so database and other internal messages never reach the client
+func rpcInternalError(ctx context.Context, err error) error {
+	method, _ := grpc.Method(ctx)
+	slog.ErrorContext(ctx, "internal error",
+		slog.String("method", method),
+		slog.Any("error", err),
+	)
+	return status.Error(codes.Internal, errInternal.Error())
+}
+
+// rpcStoreError returns the status mapped to an error of the store.
+// Errors without a mapping are internal.
+func rpcStoreError(ctx context.Context, err error) error {
+	for _, known := range storeErrors {
+		if errors.Is(err, known.err) {
+			return status.Error(known.grpcCode, known.err.Error())
+		}
+	}
+
+	return rpcInternalError(ctx, err)
+}
+
+// validateRequest checks the request against the binding rules of the HTTP API
+func validateRequest(req any) error {
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return nil
+}
+
+const userAgentHeader = "user-agent"
+
+// gatewayClientKey is the context key of the HTTP client of a request translated by the gateway
+type gatewayClientKey struct{}
+
+// gatewayClient is the user agent and IP address of the HTTP client of a gateway request
+type gatewayClient struct {
+	userAgent string
+	clientIP  string
+}
+
+// withGatewayClient records the HTTP client of each request before the gateway translates it.
+// The gateway runs in process, so the context reaches the gRPC handlers,
+// while forwarded metadata could be sent by any gRPC client.
+// The client IP is resolved as by the HTTP API, from the trusted proxies only.
+func (server *Server) withGatewayClient(next http.Handler) (http.Handler, error) {
+	router := gin.New()
+	if err := router.SetTrustedProxies(trustedProxies(server.config)); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
+	router.Any("/*path", func(ctx *gin.Context) {
+		client := gatewayClient{
+			userAgent: ctx.Request.UserAgent(),
+			clientIP:  ctx.ClientIP(),
+		}
+		request := ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), gatewayClientKey{}, client))
+		next.ServeHTTP(ctx.Writer, request)
+	})
+
+	return router, nil
+}
+
+// clientMetadata returns the user agent and IP address of the client.
+// Requests through the gateway carry the ones of the original HTTP client,
+// and direct gRPC requests the address of the connection.
+func clientMetadata(ctx context.Context) (userAgent string, clientIP string) {
+	if client, ok := ctx.Value(gatewayClientKey{}).(gatewayClient); ok {
+		return client.userAgent, client.clientIP
+	}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(userAgentHeader); len(values) > 0 {
+			userAgent = values[0]
+		}
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		clientIP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(clientIP); err == nil {
+			clientIP = host
+		}
+	}
+
+	return userAgent, clientIP
+}
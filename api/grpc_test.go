@@ -0,0 +1,297 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	mockdb "github.com/niloy104/simplebank/db/mock"
+	db "github.com/niloy104/simplebank/db/sqlc"
+	"github.com/niloy104/simplebank/pb"
+	"github.com/niloy104/simplebank/token"
+	"github.com/niloy104/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func newContextWithBearerToken(
+	t *testing.T,
+	tokenMaker token.Maker,
+	authorizationType string,
+	username string,
+	role string,
+	duration time.Duration,
+) (context.Context, *token.Payload) {
+	accessToken, payload, err := tokenMaker.CreateToken(username, role, uuid.New(), duration, token.TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	md := metadata.MD{
+		authorizationHeaderKey: []string{authorizationType + " " + accessToken},
+	}
+	return metadata.NewIncomingContext(context.Background(), md), payload
+}
+
+func requireStatusCode(t *testing.T, err error, code codes.Code) {
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, code, st.Code())
+}
+
+func TestRPCAuthenticate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		buildCtx  func(t *testing.T, server *Server) context.Context
+		checkAuth func(t *testing.T, payload *token.Payload, err error)
+	}{
+		{
+			name: "OK",
+			buildCtx: func(t *testing.T, server *Server) context.Context {
+				ctx, _ := newContextWithBearerToken(t, server.tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, time.Minute)
+				return ctx
+			},
+			checkAuth: func(t *testing.T, payload *token.Payload, err error) {
+				require.NoError(t, err)
+				require.Equal(t, "user", payload.Username)
+				require.Equal(t, util.DepositorRole, payload.Role)
+			},
+		},
+		{
+			name: "NoAuthorization",
+			buildCtx: func(t *testing.T, server *Server) context.Context {
+				return context.Background()
+			},
+			checkAuth: func(t *testing.T, payload *token.Payload, err error) {
+				requireStatusCode(t, err, codes.Unauthenticated)
+			},
+		},
+		{
+			name: "UnsupportedAuthorization",
+			buildCtx: func(t *testing.T, server *Server) context.Context {
+				ctx, _ := newContextWithBearerToken(t, server.tokenMaker, "unsupported", "user", util.DepositorRole, time.Minute)
+				return ctx
+			},
+			checkAuth: func(t *testing.T, payload *token.Payload, err error) {
+				requireStatusCode(t, err, codes.Unauthenticated)
+			},
+		},
+		{
+			name: "InvalidAuthorizationFormat",
+			buildCtx: func(t *testing.T, server *Server) context.Context {
+				ctx, _ := newContextWithBearerToken(t, server.tokenMaker, "", "user", util.DepositorRole, time.Minute)
+				return ctx
+			},
+			checkAuth: func(t *testing.T, payload *token.Payload, err error) {
+				requireStatusCode(t, err, codes.Unauthenticated)
+			},
+		},
+		{
+			name: "ExpiredToken",
+			buildCtx: func(t *testing.T, server *Server) context.Context {
+				ctx, _ := newContextWithBearerToken(t, server.tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, -time.Minute)
+				return ctx
+			},
+			checkAuth: func(t *testing.T, payload *token.Payload, err error) {
+				requireStatusCode(t, err, codes.Unauthenticated)
+			},
+		},
+		{
+			name: "RevokedToken",
+			buildCtx: func(t *testing.T, server *Server) context.Context {
+				ctx, payload := newContextWithBearerToken(t, server.tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, time.Minute)
+				server.denylist.Revoke(payload.ID, payload.ExpiredAt)
+				return ctx
+			},
+			checkAuth: func(t *testing.T, payload *token.Payload, err error) {
+				requireStatusCode(t, err, codes.Unauthenticated)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t, nil)
+			rpc := &rpcServer{server: server}
+
+			payload, err := rpc.authenticate(tc.buildCtx(t, server))
+			tc.checkAuth(t, payload, err)
+		})
+	}
+}
+
+func TestGatewayGetAccount(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+
+	testCases := []struct {
+		name          string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), fmt.Sprintf(`"owner":"%s"`, account.Owner))
+				require.Contains(t, recorder.Body.String(), `"available_balance"`)
+			},
+		},
+		{
+			name: "NoAuthorization",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "UnauthorizedUser",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, "unauthorized_user", util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "NotFound",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(db.Account{}, db.ErrRecordNotFound)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			mux, err := server.newGatewayMux(context.Background())
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			url := fmt.Sprintf("/v1/accounts/%d", account.ID)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			mux.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestRPCInternalError(t *testing.T) {
+	logs := captureLogs(t)
+	user, password := randomUserWithPassword(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// the cause stays in the server log and the client only gets a generic message
+	dbErr := errors.New("failed to connect to `host=db.internal user=root database=simple_bank`")
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		CreateUser(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.User{}, dbErr)
+
+	rpc := &rpcServer{server: newTestServer(t, store)}
+	_, err := rpc.CreateUser(context.Background(), &pb.CreateUserRequest{
+		Username: user.Username,
+		Password: password,
+		FullName: user.FullName,
+		Email:    user.Email,
+	})
+	requireStatusCode(t, err, codes.Internal)
+	require.Equal(t, errInternal.Error(), status.Convert(err).Message())
+
+	record := findLogRecord(t, logs, "internal error")
+	require.Equal(t, dbErr.Error(), record["error"])
+}
+
+func TestRPCStoreError(t *testing.T) {
+	for _, known := range storeErrors {
+		// the wrapped cause is not sent, only the error of the store
+		err := rpcStoreError(context.Background(), fmt.Errorf("account [1]: %w", known.err))
+		requireStatusCode(t, err, known.grpcCode)
+		require.Equal(t, known.err.Error(), status.Convert(err).Message())
+	}
+}
+
+func TestClientMetadata(t *testing.T) {
+	// forwarded metadata is ignored, since any gRPC client can send it
+	md := metadata.MD{
+		userAgentHeader:   []string{"grpc-client"},
+		"x-forwarded-for": []string{"10.0.0.1"},
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 50000}})
+
+	userAgent, clientIP := clientMetadata(ctx)
+	require.Equal(t, "grpc-client", userAgent)
+	require.Equal(t, "192.0.2.1", clientIP)
+
+	// requests through the gateway carry the HTTP client, and no proxy is trusted to forward its address
+	server := newTestServer(t, nil)
+	handler, err := server.withGatewayClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent, clientIP = clientMetadata(metadata.NewIncomingContext(r.Context(), md))
+	}))
+	require.NoError(t, err)
+
+	request := httptest.NewRequest(http.MethodPost, "/v1/login_user", nil)
+	request.RemoteAddr = "198.51.100.7:41000"
+	request.Header.Set("User-Agent", "http-client")
+	request.Header.Set("X-Forwarded-For", "10.0.0.1")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	require.Equal(t, "http-client", userAgent)
+	require.Equal(t, "198.51.100.7", clientIP)
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/niloy104/simplebank/db/migration"
+	"github.com/niloy104/simplebank/token"
+	"github.com/niloy104/simplebank/util"
+)
+
+const (
+	healthStatusOK           = "ok"
+	healthStatusFailing      = "failing"
+	healthStatusShuttingDown = "shutting_down"
+
+	// healthCheckTimeout bounds every check, so a hanging dependency cannot hang the probe
+	healthCheckTimeout = 2 * time.Second
+)
+
+// HealthChecker is an interface for checking a dependency the server needs to serve requests
+type HealthChecker interface {
+	// CheckHealth returns an error when the dependency cannot be used
+	CheckHealth(ctx context.Context) error
+}
+
+// HealthCheckerFunc lets an ordinary function be used as a HealthChecker
+type HealthCheckerFunc func(ctx context.Context) error
+
+// CheckHealth calls the function
+func (check HealthCheckerFunc) CheckHealth(ctx context.Context) error {
+	return check(ctx)
+}
+
+// healthRegistry holds the named checks that decide whether the server is ready
+type healthRegistry struct {
+	mu       sync.RWMutex
+	checkers map[string]HealthChecker
+}
+
+func newHealthRegistry() *healthRegistry {
+	return &healthRegistry{checkers: make(map[string]HealthChecker)}
+}
+
+// register adds a check, replacing the one registered before with the same name
+func (registry *healthRegistry) register(name string, checker HealthChecker) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	registry.checkers[name] = checker
+}
+
+// healthCheckResult is the public result of a check.
+// /readyz is unauthenticated, so why a check failed is only logged.
+type healthCheckResult struct {
+	Status string `json:"status"`
+}
+
+type healthResponse struct {
+	Status string                       `json:"status"`
+	Checks map[string]healthCheckResult `json:"checks,omitempty"`
+}
+
+// check runs all checks at once and reports each of them.
+// The server is healthy only when every check passes.
+func (registry *healthRegistry) check(ctx context.Context, timeout time.Duration) healthResponse {
+	registry.mu.RLock()
+	names := make([]string, 0, len(registry.checkers))
+	for name := range registry.checkers {
+		names = append(names, name)
+	}
+	checkers := make([]HealthChecker, len(names))
+	sort.Strings(names)
+	for i, name := range names {
+		checkers[i] = registry.checkers[name]
+	}
+	registry.mu.RUnlock()
+
+	results := make([]healthCheckResult, len(checkers))
+	var wg sync.WaitGroup
+	for i, checker := range checkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runHealthCheck(ctx, names[i], checker, timeout)
+		}()
+	}
+	wg.Wait()
+
+	rsp := healthResponse{
+		Status: healthStatusOK,
+		Checks: make(map[string]healthCheckResult, len(names)),
+	}
+	for i, name := range names {
+		rsp.Checks[name] = results[i]
+		if results[i].Status != healthStatusOK {
+			rsp.Status = healthStatusFailing
+		}
+	}
+	return rsp
+}
+
+// runHealthCheck runs a check and logs why it failed
+func runHealthCheck(ctx context.Context, name string, checker HealthChecker, timeout time.Duration) healthCheckResult {
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := checker.CheckHealth(checkCtx); err != nil {
+		slog.WarnContext(ctx, "health check failed",
+			slog.String("check", name),
+			slog.Duration("duration", time.Since(start)),
+			slog.Any("error", err),
+		)
+		return healthCheckResult{Status: healthStatusFailing}
+	}
+	return healthCheckResult{Status: healthStatusOK}
+}
+
+// RegisterHealthChecker adds a check that must pass for /readyz to report the server ready.
+// Checks registered later with the same name replace earlier ones.
+func (server *Server) RegisterHealthChecker(name string, checker HealthChecker) {
+	server.health.register(name, checker)
+}
+
+// registerDefaultHealthCheckers checks the dependencies every request needs
+func (server *Server) registerDefaultHealthCheckers() {
+	server.RegisterHealthChecker("postgres", HealthCheckerFunc(server.checkPostgres))
+	server.RegisterHealthChecker("migrations", HealthCheckerFunc(server.checkMigrations))
+	server.RegisterHealthChecker("token_maker", HealthCheckerFunc(server.checkTokenMaker))
+}
+
+// checkPostgres checks that the database accepts queries
+func (server *Server) checkPostgres(ctx context.Context) error {
+	return server.store.Ping(ctx)
+}
+
+// checkMigrations fails while the schema is older than the migrations the server was built with,
+// or when the last migration failed halfway.
+// A newer schema passes, so the previous release keeps serving during a rolling deploy.
+func (server *Server) checkMigrations(ctx context.Context) error {
+	expected, err := migration.LatestVersion()
+	if err != nil {
+		return err
+	}
+
+	version, dirty, err := server.store.MigrationVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot get migration version: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("migration %d is dirty", version)
+	}
+	if version < expected {
+		return fmt.Errorf("migration version is %d, expected %d", version, expected)
+	}
+	return nil
+}
+
+// checkTokenMaker creates and verifies a token, so a maker with unusable keys is caught
+func (server *Server) checkTokenMaker(ctx context.Context) error {
+	if server.tokenMaker == nil {
+		return errors.New("token maker is not configured")
+	}
+
+	accessToken, _, err := server.tokenMaker.CreateToken("healthcheck", util.DepositorRole, uuid.Nil, time.Minute, token.TokenTypeAccessToken)
+	if err != nil {
+		return fmt.Errorf("cannot create token: %w", err)
+	}
+	if _, err := server.tokenMaker.VerifyToken(accessToken, token.TokenTypeAccessToken); err != nil {
+		return fmt.Errorf("cannot verify token: %w", err)
+	}
+	return nil
+}
+
+// isShuttingDown reports whether Shutdown was called
+func (server *Server) isShuttingDown() bool {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return server.shuttingDown
+}
+
+// getHealth reports that the process is alive and serving requests, without checking its dependencies
+func (server *Server) getHealth(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, healthResponse{Status: healthStatusOK})
+}
+
+// getReadiness reports whether the server can serve requests, with the result of every check.
+// It fails as soon as the server is shutting down, so load balancers stop sending requests.
+func (server *Server) getReadiness(ctx *gin.Context) {
+	if server.isShuttingDown() {
+		ctx.JSON(http.StatusServiceUnavailable, healthResponse{Status: healthStatusShuttingDown})
+		return
+	}
+
+	rsp := server.health.check(ctx, healthCheckTimeout)
+	if rsp.Status != healthStatusOK {
+		ctx.JSON(http.StatusServiceUnavailable, rsp)
+		return
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
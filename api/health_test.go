@@ -0,0 +1,239 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/niloy104/simplebank/db/migration"
+	mockdb "github.com/niloy104/simplebank/db/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func requireHealthResponse(t *testing.T, recorder *httptest.ResponseRecorder) healthResponse {
+	var rsp healthResponse
+	err := json.Unmarshal(recorder.Body.Bytes(), &rsp)
+	require.NoError(t, err)
+	return rsp
+}
+
+// findHealthCheckLog returns the log record of the failed check with the name
+func findHealthCheckLog(t *testing.T, logs *bytes.Buffer, name string) map[string]any {
+	decoder := json.NewDecoder(bytes.NewReader(logs.Bytes()))
+	for decoder.More() {
+		var record map[string]any
+		require.NoError(t, decoder.Decode(&record))
+		if record["msg"] == "health check failed" && record["check"] == name {
+			return record
+		}
+	}
+
+	t.Fatalf("no failed health check %q in %s", name, logs)
+	return nil
+}
+
+func TestGetHealth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// liveness does not depend on the database
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().Ping(gomock.Any()).Times(0)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/healthz", nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, healthStatusOK, requireHealthResponse(t, recorder).Status)
+}
+
+func TestGetReadiness(t *testing.T) {
+	latest, err := migration.LatestVersion()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkers      map[string]HealthChecker
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, logs *bytes.Buffer)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().MigrationVersion(gomock.Any()).Times(1).Return(latest, false, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, logs *bytes.Buffer) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				rsp := requireHealthResponse(t, recorder)
+				require.Equal(t, healthStatusOK, rsp.Status)
+				require.Len(t, rsp.Checks, 3)
+				for name, check := range rsp.Checks {
+					require.Equalf(t, healthStatusOK, check.Status, "check %s", name)
+				}
+			},
+		},
+		{
+			name: "PostgresDown",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).Return(errors.New("dial tcp 10.0.0.5:5432: connection refused"))
+				store.EXPECT().MigrationVersion(gomock.Any()).Times(1).Return(int64(0), false, errors.New("dial tcp 10.0.0.5:5432: connection refused"))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, logs *bytes.Buffer) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+				rsp := requireHealthResponse(t, recorder)
+				require.Equal(t, healthStatusFailing, rsp.Status)
+				require.Equal(t, healthStatusFailing, rsp.Checks["postgres"].Status)
+				require.Equal(t, healthStatusFailing, rsp.Checks["migrations"].Status)
+				require.Equal(t, healthStatusOK, rsp.Checks["token_maker"].Status)
+
+				// the address of the database is only logged
+				require.NotContains(t, recorder.Body.String(), "10.0.0.5")
+				require.Equal(t, "dial tcp 10.0.0.5:5432: connection refused", findHealthCheckLog(t, logs, "postgres")["error"])
+			},
+		},
+		{
+			name: "MigrationsBehind",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().MigrationVersion(gomock.Any()).Times(1).Return(latest-1, false, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, logs *bytes.Buffer) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+				rsp := requireHealthResponse(t, recorder)
+				require.Equal(t, healthStatusFailing, rsp.Checks["migrations"].Status)
+				require.Equal(t, healthStatusOK, rsp.Checks["postgres"].Status)
+			},
+		},
+		{
+			name: "MigrationsDirty",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().MigrationVersion(gomock.Any()).Times(1).Return(latest, true, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, logs *bytes.Buffer) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				require.Equal(t, healthStatusFailing, requireHealthResponse(t, recorder).Checks["migrations"].Status)
+			},
+		},
+		{
+			name: "MigrationsAhead",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().MigrationVersion(gomock.Any()).Times(1).Return(latest+1, false, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, logs *bytes.Buffer) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "RegisteredCheckerFails",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().MigrationVersion(gomock.Any()).Times(1).Return(latest, false, nil)
+			},
+			checkers: map[string]HealthChecker{
+				"fx_rates": HealthCheckerFunc(func(ctx context.Context) error {
+					return errors.New("stale rates")
+				}),
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, logs *bytes.Buffer) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+				rsp := requireHealthResponse(t, recorder)
+				require.Len(t, rsp.Checks, 4)
+				require.Equal(t, healthStatusFailing, rsp.Checks["fx_rates"].Status)
+				require.NotContains(t, recorder.Body.String(), "stale rates")
+				require.Equal(t, "stale rates", findHealthCheckLog(t, logs, "fx_rates")["error"])
+			},
+		},
+		{
+			name: "RegisteredCheckerTimesOut",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().Ping(gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().MigrationVersion(gomock.Any()).Times(1).Return(latest, false, nil)
+			},
+			checkers: map[string]HealthChecker{
+				"hanging": HealthCheckerFunc(func(ctx context.Context) error {
+					<-ctx.Done()
+					return ctx.Err()
+				}),
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, logs *bytes.Buffer) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				require.Equal(t, healthStatusFailing, requireHealthResponse(t, recorder).Checks["hanging"].Status)
+				require.Equal(t, context.DeadlineExceeded.Error(), findHealthCheckLog(t, logs, "hanging")["error"])
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			for name, checker := range tc.checkers {
+				server.RegisterHealthChecker(name, checker)
+			}
+
+			logs := captureLogs(t)
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, "/readyz", nil)
+			require.NoError(t, err)
+
+			start := time.Now()
+			server.router.ServeHTTP(recorder, request)
+			require.Less(t, time.Since(start), 2*healthCheckTimeout)
+			tc.checkResponse(t, recorder, logs)
+		})
+	}
+}
+
+func TestReadinessFailsDuringShutdown(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().Ping(gomock.Any()).Times(0)
+
+	server := newTestServer(t, store)
+	server.config.ShutdownDrainDelay = 300 * time.Millisecond
+	baseURL, serveErr := serveTestServer(t, server)
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- server.Shutdown(context.Background())
+	}()
+
+	// the server keeps serving during the drain delay, but is no longer ready
+	require.Eventually(t, server.isShuttingDown, time.Second, time.Millisecond)
+	rsp, err := http.Get(baseURL + "/readyz")
+	require.NoError(t, err)
+	defer rsp.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, rsp.StatusCode)
+
+	var health healthResponse
+	require.NoError(t, json.NewDecoder(rsp.Body).Decode(&health))
+	require.Equal(t, healthStatusShuttingDown, health.Status)
+
+	require.NoError(t, <-shutdownErr)
+	require.ErrorIs(t, <-serveErr, http.ErrServerClosed)
+}
@@ -0,0 +1,280 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/niloy104/simplebank/db/sqlc"
+)
+
+// directions of money movements, seen from the account
+const (
+	directionCredit = "credit"
+	directionDebit  = "debit"
+)
+
+// Account transaction history
+type accountHistoryURI struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type accountHistoryRequest struct {
+	pageRequest
+	StartTime time.Time `form:"start_time" time_format:"2006-01-02T15:04:05Z07:00"`
+	EndTime   time.Time `form:"end_time" time_format:"2006-01-02T15:04:05Z07:00"`
+	MinAmount *int64    `form:"min_amount" binding:"omitempty,gt=0"`
+	MaxAmount *int64    `form:"max_amount" binding:"omitempty,gt=0"`
+	Direction string    `form:"direction" binding:"omitempty,oneof=credit debit"`
+}
+
+// accountHistoryFilter holds the validated filters shared by the history queries
+type accountHistoryFilter struct {
+	AccountID int64
+	StartTime pgtype.Timestamptz
+	EndTime   pgtype.Timestamptz
+	MinAmount pgtype.Int8
+	MaxAmount pgtype.Int8
+	Direction pgtype.Text
+	Page      pageRequest
+	Scope     string
+	pageQuery
+}
+
+type listEntriesResponse struct {
+	Entries    []entryResponse `json:"entries"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+type listTransfersResponse struct {
+	Transfers  []transferResponse `json:"transfers"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+type entryResponse struct {
+	ID             int64     `json:"id"`
+	AccountID      int64     `json:"account_id"`
+	Amount         int64     `json:"amount"`
+	Direction      string    `json:"direction"`
+	RunningBalance int64     `json:"running_balance"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func newEntryResponse(entry db.ListAccountEntriesRow) entryResponse {
+	direction := directionCredit
+	if entry.Amount < 0 {
+		direction = directionDebit
+	}
+
+	return entryResponse{
+		ID:             entry.ID,
+		AccountID:      entry.AccountID,
+		Amount:         entry.Amount,
+		Direction:      direction,
+		RunningBalance: entry.RunningBalance,
+		CreatedAt:      entry.CreatedAt.Time,
+	}
+}
+
+type transferResponse struct {
+	ID             int64           `json:"id"`
+	FromAccountID  int64           `json:"from_account_id"`
+	ToAccountID    int64           `json:"to_account_id"`
+	Amount         int64           `json:"amount"`
+	ToAmount       int64           `json:"to_amount,omitempty"`
+	FXRate         *pgtype.Numeric `json:"fx_rate,omitempty"`
+	ReversalOf     int64           `json:"reversal_of,omitempty"`
+	AccountAmount  int64           `json:"account_amount"`
+	Direction      string          `json:"direction"`
+	RunningBalance int64           `json:"running_balance"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+func newTransferResponse(accountID int64, transfer db.ListAccountTransfersRow) transferResponse {
+	direction := directionCredit
+	if transfer.FromAccountID.Int64 == accountID {
+		direction = directionDebit
+	}
+
+	rsp := transferResponse{
+		ID:             transfer.ID,
+		FromAccountID:  transfer.FromAccountID.Int64,
+		ToAccountID:    transfer.ToAccountID.Int64,
+		Amount:         transfer.Amount,
+		ToAmount:       transfer.ToAmount.Int64,
+		ReversalOf:     transfer.ReversalOf.Int64,
+		AccountAmount:  transfer.AccountAmount,
+		Direction:      direction,
+		RunningBalance: transfer.RunningBalance,
+		CreatedAt:      transfer.CreatedAt.Time,
+	}
+	if transfer.FxRate.Valid {
+		rsp.FXRate = &transfer.FxRate
+	}
+
+	return rsp
+}
+
+func (server *Server) listAccountEntries(ctx *gin.Context) {
+	filter, ok := server.bindAccountHistory(ctx, "entries")
+	if !ok {
+		return
+	}
+
+	entries, err := server.store.ListAccountEntries(ctx, db.ListAccountEntriesParams{
+		AccountID:      filter.AccountID,
+		AfterCreatedAt: filter.AfterCreatedAt,
+		AfterID:        filter.AfterID,
+		StartTime:      filter.StartTime,
+		EndTime:        filter.EndTime,
+		MinAmount:      filter.MinAmount,
+		MaxAmount:      filter.MaxAmount,
+		Direction:      filter.Direction,
+		Limit:          filter.Limit,
+		Offset:         filter.Offset,
+	})
+	if err != nil {
+		writeInternalError(ctx, err)
+		return
+	}
+
+	var nextCursor string
+	if !filter.Page.isLegacy() {
+		entries, nextCursor, err = nextPage(server.cursors, filter.Page, filter.Scope, entries, func(entry db.ListAccountEntriesRow) (time.Time, int64) {
+			return entry.CreatedAt.Time, entry.ID
+		})
+		if err != nil {
+			writeInternalError(ctx, err)
+			return
+		}
+	}
+
+	rsp := make([]entryResponse, len(entries))
+	for i, entry := range entries {
+		rsp[i] = newEntryResponse(entry)
+	}
+
+	if filter.Page.isLegacy() {
+		ctx.JSON(http.StatusOK, rsp)
+		return
+	}
+	ctx.JSON(http.StatusOK, listEntriesResponse{
+		Entries:    rsp,
+		NextCursor: nextCursor,
+	})
+}
+
+func (server *Server) listAccountTransfers(ctx *gin.Context) {
+	filter, ok := server.bindAccountHistory(ctx, "transfers")
+	if !ok {
+		return
+	}
+
+	transfers, err := server.store.ListAccountTransfers(ctx, db.ListAccountTransfersParams{
+		AccountID:      filter.AccountID,
+		AfterCreatedAt: filter.AfterCreatedAt,
+		AfterID:        filter.AfterID,
+		StartTime:      filter.StartTime,
+		EndTime:        filter.EndTime,
+		MinAmount:      filter.MinAmount,
+		MaxAmount:      filter.MaxAmount,
+		Direction:      filter.Direction,
+		Limit:          filter.Limit,
+		Offset:         filter.Offset,
+	})
+	if err != nil {
+		writeInternalError(ctx, err)
+		return
+	}
+
+	var nextCursor string
+	if !filter.Page.isLegacy() {
+		transfers, nextCursor, err = nextPage(server.cursors, filter.Page, filter.Scope, transfers, func(transfer db.ListAccountTransfersRow) (time.Time, int64) {
+			return transfer.CreatedAt.Time, transfer.ID
+		})
+		if err != nil {
+			writeInternalError(ctx, err)
+			return
+		}
+	}
+
+	rsp := make([]transferResponse, len(transfers))
+	for i, transfer := range transfers {
+		rsp[i] = newTransferResponse(filter.AccountID, transfer)
+	}
+
+	if filter.Page.isLegacy() {
+		ctx.JSON(http.StatusOK, rsp)
+		return
+	}
+	ctx.JSON(http.StatusOK, listTransfersResponse{
+		Transfers:  rsp,
+		NextCursor: nextCursor,
+	})
+}
+
+// bindAccountHistory validates a request for the kind of history and checks the user may read the account.
+// It writes an error response and returns false if the request cannot go ahead.
+func (server *Server) bindAccountHistory(ctx *gin.Context, kind string) (accountHistoryFilter, bool) {
+	var uri accountHistoryURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		writeBindError(ctx, err)
+		return accountHistoryFilter{}, false
+	}
+
+	var req accountHistoryRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		writeBindError(ctx, err)
+		return accountHistoryFilter{}, false
+	}
+
+	if !req.StartTime.IsZero() && !req.EndTime.IsZero() && !req.EndTime.After(req.StartTime) {
+		err := errors.New("end_time must be after start_time")
+		writeError(ctx, http.StatusBadRequest, errorCodeInvalidRequest, err)
+		return accountHistoryFilter{}, false
+	}
+
+	if req.MinAmount != nil && req.MaxAmount != nil && *req.MaxAmount < *req.MinAmount {
+		err := errors.New("max_amount must not be less than min_amount")
+		writeError(ctx, http.StatusBadRequest, errorCodeInvalidRequest, err)
+		return accountHistoryFilter{}, false
+	}
+
+	account, err := server.store.GetAccount(ctx, uri.ID)
+	if err != nil {
+		writeStoreError(ctx, err)
+		return accountHistoryFilter{}, false
+	}
+
+	if !server.authorize(ctx, permissionReadAccount, account.Owner) {
+		return accountHistoryFilter{}, false
+	}
+
+	scope := fmt.Sprintf("%s:%d", kind, account.ID)
+	page, err := req.query(server.cursors, scope)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, errorCodeInvalidRequest, err)
+		return accountHistoryFilter{}, false
+	}
+
+	filter := accountHistoryFilter{
+		AccountID: account.ID,
+		StartTime: pgtype.Timestamptz{Time: req.StartTime, Valid: !req.StartTime.IsZero()},
+		EndTime:   pgtype.Timestamptz{Time: req.EndTime, Valid: !req.EndTime.IsZero()},
+		Direction: pgtype.Text{String: req.Direction, Valid: req.Direction != ""},
+		Page:      req.pageRequest,
+		Scope:     scope,
+		pageQuery: page,
+	}
+	if req.MinAmount != nil {
+		filter.MinAmount = pgtype.Int8{Int64: *req.MinAmount, Valid: true}
+	}
+	if req.MaxAmount != nil {
+		filter.MaxAmount = pgtype.Int8{Int64: *req.MaxAmount, Valid: true}
+	}
+
+	return filter, true
+}
@@ -0,0 +1,667 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/niloy104/simplebank/db/mock"
+	db "github.com/niloy104/simplebank/db/sqlc"
+	"github.com/niloy104/simplebank/token"
+	"github.com/niloy104/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestListAccountEntriesAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+
+	n := 5
+	entries := make([]db.ListAccountEntriesRow, n)
+	balance := account.Balance
+	for i := range entries {
+		amount := util.RandomInt(-100, 100)
+		balance += amount
+		entries[i] = db.ListAccountEntriesRow{
+			ID:             util.RandomInt(1, 1000),
+			AccountID:      account.ID,
+			Amount:         amount,
+			RunningBalance: balance,
+			CreatedAt:      pgtype.Timestamptz{Time: time.Now().UTC().Truncate(time.Second), Valid: true},
+		}
+	}
+
+	startTime := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	endTime := startTime.Add(2 * time.Hour)
+
+	type Query struct {
+		pageID    int
+		pageSize  int
+		startTime string
+		endTime   string
+		minAmount string
+		maxAmount string
+		direction string
+	}
+
+	testCases := []struct {
+		name          string
+		accountID     int64
+		query         Query
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:      "OK",
+			accountID: account.ID,
+			query: Query{
+				pageID:   1,
+				pageSize: n,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+
+				arg := db.ListAccountEntriesParams{
+					AccountID: account.ID,
+					Limit:     int32(n),
+					Offset:    0,
+				}
+				store.EXPECT().
+					ListAccountEntries(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(entries, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchEntries(t, recorder.Body, entries)
+			},
+		},
+		{
+			name:      "WithFilters",
+			accountID: account.ID,
+			query: Query{
+				pageID:    2,
+				pageSize:  n,
+				startTime: startTime.Format(time.RFC3339),
+				endTime:   endTime.Format(time.RFC3339),
+				minAmount: "10",
+				maxAmount: "100",
+				direction: directionDebit,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+
+				store.EXPECT().
+					ListAccountEntries(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.ListAccountEntriesParams) ([]db.ListAccountEntriesRow, error) {
+						require.Equal(t, account.ID, arg.AccountID)
+						require.True(t, arg.StartTime.Time.Equal(startTime))
+						require.True(t, arg.EndTime.Time.Equal(endTime))
+						require.Equal(t, pgtype.Int8{Int64: 10, Valid: true}, arg.MinAmount)
+						require.Equal(t, pgtype.Int8{Int64: 100, Valid: true}, arg.MaxAmount)
+						require.Equal(t, pgtype.Text{String: directionDebit, Valid: true}, arg.Direction)
+						require.Equal(t, int32(n), arg.Limit)
+						require.Equal(t, int32(n), arg.Offset)
+						return []db.ListAccountEntriesRow{}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:      "BankerReadsOtherAccount",
+			accountID: account.ID,
+			query: Query{
+				pageID:   1,
+				pageSize: n,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, "banker", util.BankerRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					ListAccountEntries(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(entries, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchEntries(t, recorder.Body, entries)
+			},
+		},
+		{
+			name:      "UnauthorizedUser",
+			accountID: account.ID,
+			query: Query{
+				pageID:   1,
+				pageSize: n,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, "unauthorized_user", util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					ListAccountEntries(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:      "NoAuthorization",
+			accountID: account.ID,
+			query: Query{
+				pageID:   1,
+				pageSize: n,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:      "NotFound",
+			accountID: account.ID,
+			query: Query{
+				pageID:   1,
+				pageSize: n,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(db.Account{}, db.ErrRecordNotFound)
+				store.EXPECT().
+					ListAccountEntries(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:      "InvalidDirection",
+			accountID: account.ID,
+			query: Query{
+				pageID:    1,
+				pageSize:  n,
+				direction: "sideways",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:      "InvalidTimeRange",
+			accountID: account.ID,
+			query: Query{
+				pageID:    1,
+				pageSize:  n,
+				startTime: endTime.Format(time.RFC3339),
+				endTime:   startTime.Format(time.RFC3339),
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:      "InvalidTimeFormat",
+			accountID: account.ID,
+			query: Query{
+				pageID:    1,
+				pageSize:  n,
+				startTime: "yesterday",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:      "InvalidAmountRange",
+			accountID: account.ID,
+			query: Query{
+				pageID:    1,
+				pageSize:  n,
+				minAmount: "100",
+				maxAmount: "10",
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:      "InvalidPageSize",
+			accountID: account.ID,
+			query: Query{
+				pageID:   1,
+				pageSize: 100,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:      "InternalError",
+			accountID: account.ID,
+			query: Query{
+				pageID:   1,
+				pageSize: n,
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					ListAccountEntries(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.ListAccountEntriesRow{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			query := url.Values{}
+			query.Add("page_id", fmt.Sprintf("%d", tc.query.pageID))
+			query.Add("page_size", fmt.Sprintf("%d", tc.query.pageSize))
+			addQueryParam(query, "start_time", tc.query.startTime)
+			addQueryParam(query, "end_time", tc.query.endTime)
+			addQueryParam(query, "min_amount", tc.query.minAmount)
+			addQueryParam(query, "max_amount", tc.query.maxAmount)
+			addQueryParam(query, "direction", tc.query.direction)
+
+			url := fmt.Sprintf("/accounts/%d/entries?%s", tc.accountID, query.Encode())
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestListAccountTransfersAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+	otherAccount := randomAccount(util.RandomOwner())
+
+	n := 5
+	transfers := make([]db.ListAccountTransfersRow, n)
+	balance := account.Balance
+	for i := range transfers {
+		amount := util.RandomInt(1, 100)
+		from, to := account.ID, otherAccount.ID
+		if i%2 == 1 {
+			from, to = to, from
+			balance += amount
+		} else {
+			balance -= amount
+		}
+
+		transfers[i] = db.ListAccountTransfersRow{
+			ID:             util.RandomInt(1, 1000),
+			FromAccountID:  pgtype.Int8{Int64: from, Valid: true},
+			ToAccountID:    pgtype.Int8{Int64: to, Valid: true},
+			Amount:         amount,
+			RunningBalance: balance,
+			CreatedAt:      pgtype.Timestamptz{Time: time.Now().UTC().Truncate(time.Second), Valid: true},
+		}
+	}
+
+	testCases := []struct {
+		name          string
+		direction     string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+
+				arg := db.ListAccountTransfersParams{
+					AccountID: account.ID,
+					Limit:     int32(n),
+					Offset:    0,
+				}
+				store.EXPECT().
+					ListAccountTransfers(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(transfers, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchTransfers(t, recorder.Body, account.ID, transfers)
+			},
+		},
+		{
+			name:      "CreditsOnly",
+			direction: directionCredit,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+
+				arg := db.ListAccountTransfersParams{
+					AccountID: account.ID,
+					Direction: pgtype.Text{String: directionCredit, Valid: true},
+					Limit:     int32(n),
+					Offset:    0,
+				}
+				store.EXPECT().
+					ListAccountTransfers(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return([]db.ListAccountTransfersRow{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "UnauthorizedUser",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, "unauthorized_user", util.DepositorRole, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					ListAccountTransfers(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					ListAccountTransfers(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.ListAccountTransfersRow{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			query := url.Values{}
+			query.Add("page_id", "1")
+			query.Add("page_size", fmt.Sprintf("%d", n))
+			addQueryParam(query, "direction", tc.direction)
+
+			url := fmt.Sprintf("/accounts/%d/transfers?%s", account.ID, query.Encode())
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func addQueryParam(query url.Values, key, value string) {
+	if value != "" {
+		query.Add(key, value)
+	}
+}
+
+func requireBodyMatchEntries(t *testing.T, body *bytes.Buffer, entries []db.ListAccountEntriesRow) {
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+
+	var gotEntries []entryResponse
+	err = json.Unmarshal(data, &gotEntries)
+	require.NoError(t, err)
+	require.Len(t, gotEntries, len(entries))
+
+	for i, entry := range entries {
+		require.Equal(t, entry.ID, gotEntries[i].ID)
+		require.Equal(t, entry.Amount, gotEntries[i].Amount)
+		require.Equal(t, entry.RunningBalance, gotEntries[i].RunningBalance)
+		if entry.Amount < 0 {
+			require.Equal(t, directionDebit, gotEntries[i].Direction)
+		} else {
+			require.Equal(t, directionCredit, gotEntries[i].Direction)
+		}
+	}
+}
+
+func requireBodyMatchTransfers(t *testing.T, body *bytes.Buffer, accountID int64, transfers []db.ListAccountTransfersRow) {
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+
+	var gotTransfers []transferResponse
+	err = json.Unmarshal(data, &gotTransfers)
+	require.NoError(t, err)
+	require.Len(t, gotTransfers, len(transfers))
+
+	for i, transfer := range transfers {
+		require.Equal(t, newTransferResponse(accountID, transfer), gotTransfers[i])
+	}
+}
+
+func TestListAccountEntriesCursorAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+	n := 5
+
+	createdAt := time.Now().UTC().Truncate(time.Microsecond)
+	entries := make([]db.ListAccountEntriesRow, n+1)
+	for i := range entries {
+		entries[i] = db.ListAccountEntriesRow{
+			ID:        int64(i + 1),
+			AccountID: account.ID,
+			Amount:    util.RandomMoney(),
+			CreatedAt: pgtype.Timestamptz{Time: createdAt.Add(time.Duration(i) * time.Second), Valid: true},
+		}
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+
+	listEntries := func(query url.Values) *httptest.ResponseRecorder {
+		url := fmt.Sprintf("/accounts/%d/entries?%s", account.ID, query.Encode())
+		request, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+		addAuhorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	store.EXPECT().
+		GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+		AnyTimes().
+		Return(account, nil)
+
+	store.EXPECT().
+		ListAccountEntries(gomock.Any(), gomock.Eq(db.ListAccountEntriesParams{
+			AccountID: account.ID,
+			Direction: pgtype.Text{String: directionCredit, Valid: true},
+			Limit:     int32(n + 1),
+		})).
+		Times(1).
+		Return(entries, nil)
+
+	recorder := listEntries(url.Values{"page_size": {fmt.Sprint(n)}, "direction": {directionCredit}})
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var rsp listEntriesResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.Len(t, rsp.Entries, n)
+	require.NotEmpty(t, rsp.NextCursor)
+
+	// filters are sent again with the cursor
+	store.EXPECT().
+		ListAccountEntries(gomock.Any(), gomock.Eq(db.ListAccountEntriesParams{
+			AccountID:      account.ID,
+			AfterCreatedAt: entries[n-1].CreatedAt,
+			AfterID:        pgtype.Int8{Int64: entries[n-1].ID, Valid: true},
+			Direction:      pgtype.Text{String: directionCredit, Valid: true},
+			Limit:          int32(n + 1),
+		})).
+		Times(1).
+		Return(entries[n:], nil)
+
+	recorder = listEntries(url.Values{"page_size": {fmt.Sprint(n)}, "direction": {directionCredit}, "cursor": {rsp.NextCursor}})
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var lastPage listEntriesResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &lastPage))
+	require.Len(t, lastPage.Entries, 1)
+	require.Equal(t, entries[n].ID, lastPage.Entries[0].ID)
+	require.Empty(t, lastPage.NextCursor)
+
+	// a cursor issued for the transfers of the account does not page its entries
+	transfersCursor, err := server.cursors.encode(pageCursor{
+		Scope:     fmt.Sprintf("transfers:%d", account.ID),
+		CreatedAt: createdAt,
+		ID:        1,
+	})
+	require.NoError(t, err)
+
+	recorder = listEntries(url.Values{"page_size": {fmt.Sprint(n)}, "cursor": {transfersCursor}})
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+}
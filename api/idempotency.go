@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/niloy104/simplebank/db/sqlc"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// checkIdempotencyKey reads the Idempotency-Key header of a request sent by username.
+// If the key was already used for the same request, the stored response is replayed and
+// false is returned. An empty key in the returned params means the header was not sent.
+func (server *Server) checkIdempotencyKey(ctx *gin.Context, username string, req any) (db.IdempotencyParams, bool) {
+	key := ctx.GetHeader(idempotencyKeyHeader)
+	if key == "" {
+		return db.IdempotencyParams{}, true
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		err := fmt.Errorf("%s header must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength)
+		writeError(ctx, http.StatusBadRequest, errorCodeInvalidRequest, err)
+		return db.IdempotencyParams{}, false
+	}
+
+	requestHash, err := hashRequest(ctx, req)
+	if err != nil {
+		writeInternalError(ctx, err)
+		return db.IdempotencyParams{}, false
+	}
+
+	arg := db.IdempotencyParams{
+		Username:    username,
+		Key:         key,
+		RequestHash: requestHash,
+	}
+
+	stored, err := server.store.GetIdempotencyKey(ctx, db.GetIdempotencyKeyParams{
+		Username: username,
+		Key:      key,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return arg, true
+		}
+		writeInternalError(ctx, err)
+		return arg, false
+	}
+
+	if stored.RequestHash != requestHash {
+		writeError(ctx, http.StatusConflict, errorCodeIdempotencyKeyReused, db.ErrIdempotencyKeyReused)
+		return arg, false
+	}
+
+	// the first request is still in flight: let the store wait for it to finish
+	if stored.ResponseBody == nil {
+		return arg, true
+	}
+
+	ctx.Header(idempotentReplayedHeader, "true")
+	ctx.Data(http.StatusCreated, "application/json; charset=utf-8", stored.ResponseBody)
+	return arg, false
+}
+
+// hashRequest fingerprints the method, path and bound body of a request,
+// so a key reused for a different request can be detected.
+func hashRequest(ctx *gin.Context, req any) (string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(ctx.Request.Method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(ctx.Request.URL.Path))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// PruneIdempotencyKeys periodically deletes the idempotency keys older than the TTL of the config,
+// so a key can be used again once it expired. It blocks until the context is cancelled.
+func (server *Server) PruneIdempotencyKeys(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := server.pruneIdempotencyKeys(ctx, time.Now()); err != nil {
+			slog.ErrorContext(ctx, "cannot prune idempotency keys", slog.Any("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (server *Server) pruneIdempotencyKeys(ctx context.Context, now time.Time) error {
+	before := now.Add(-server.config.IdempotencyKeyTTL)
+	return server.store.DeleteIdempotencyKeysBefore(ctx, pgtype.Timestamptz{Time: before, Valid: true})
+}
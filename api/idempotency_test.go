@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/niloy104/simplebank/db/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func testRequestHash(t *testing.T, method, path string, req any) string {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(method, path, nil)
+
+	hash, err := hashRequest(ctx, req)
+	require.NoError(t, err)
+	return hash
+}
+
+func TestHashRequest(t *testing.T) {
+	req := createAccountRequest{Currency: "USD"}
+
+	hash := testRequestHash(t, http.MethodPost, "/accounts", req)
+	require.Len(t, hash, 64)
+	require.Equal(t, hash, testRequestHash(t, http.MethodPost, "/accounts", req))
+
+	require.NotEqual(t, hash, testRequestHash(t, http.MethodPost, "/accounts", createAccountRequest{Currency: "EUR"}))
+	require.NotEqual(t, hash, testRequestHash(t, http.MethodPost, "/transfers", req))
+	require.NotEqual(t, hash, testRequestHash(t, http.MethodPut, "/accounts", req))
+}
+
+func TestPruneIdempotencyKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Now()
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		DeleteIdempotencyKeysBefore(gomock.Any(), gomock.Eq(pgtype.Timestamptz{Time: now.Add(-24 * time.Hour), Valid: true})).
+		Times(1).
+		Return(nil)
+
+	server := newTestServer(t, store)
+	server.config.IdempotencyKeyTTL = 24 * time.Hour
+
+	err := server.pruneIdempotencyKeys(context.Background(), now)
+	require.NoError(t, err)
+}
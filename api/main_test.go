@@ -13,8 +13,11 @@ import (
 
 func newTestServer(t *testing.T, store db.Store) *Server {
 	config := util.Config{
-		TokenSymmetricKey:   util.RandomString(32),
-		AccessTokenDuration: time.Minute,
+		TokenSymmetricKey:    util.RandomString(32),
+		AccessTokenDuration:  time.Minute,
+		RefreshTokenDuration: time.Hour,
+		CursorSigningKey:     util.RandomString(32),
+		FXQuoteDuration:      time.Minute,
 	}
 	server, err := NewServer(config, store)
 	require.NoError(t, err)
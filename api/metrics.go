@@ -0,0 +1,257 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	db "github.com/niloy104/simplebank/db/sqlc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	metricsNamespace = "simplebank"
+
+	// unmatchedRoute labels the requests that matched no route, so unknown paths do not create new series
+	unmatchedRoute = "unmatched"
+	otherMethod    = "OTHER"
+
+	// kinds of money movements counted by the business metrics
+	movementTransfer   = "transfer"
+	movementFXTransfer = "fx_transfer"
+	movementReversal   = "reversal"
+	movementDeposit    = "deposit"
+	movementWithdrawal = "withdrawal"
+)
+
+// metrics holds the Prometheus metrics of a server.
+// Every label has a bounded set of values: routes are templates, never paths with IDs,
+// and business metrics are labelled by currency, never by account or user.
+type metrics struct {
+	registry *prometheus.Registry
+	handler  http.Handler
+
+	httpRequests        *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+
+	moneyMovements        *prometheus.CounterVec
+	moneyMovedAmount      *prometheus.CounterVec
+	scheduledTransferRuns *prometheus.CounterVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route template and status.",
+		}, []string{"method", "route", "status"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests by method, route template and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		moneyMovements: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "money_movements_total",
+			Help:      "Transfers, reversals, deposits and withdrawals by kind and currency.",
+		}, []string{"kind", "currency"}),
+		moneyMovedAmount: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "money_moved_amount_total",
+			Help:      "Amount moved by kind and currency, in the currency the money left from.",
+		}, []string{"kind", "currency"}),
+		scheduledTransferRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "scheduled_transfer_runs_total",
+			Help:      "Runs of scheduled transfers by status.",
+		}, []string{"status"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpRequestDuration,
+		m.moneyMovements,
+		m.moneyMovedAmount,
+		m.scheduledTransferRuns,
+	)
+	m.handler = promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	return m
+}
+
+// moneyMoved counts a movement of money that completed
+func (m *metrics) moneyMoved(kind string, currency string, amount int64) {
+	m.moneyMovements.WithLabelValues(kind, currency).Inc()
+	m.moneyMovedAmount.WithLabelValues(kind, currency).Add(float64(amount))
+}
+
+// metricsMiddleware counts the requests and their latency by the template of the route they matched
+func metricsMiddleware(m *metrics) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		method, route := ctx.Request.Method, ctx.FullPath()
+		if route == "" {
+			method, route = metricsMethod(method), unmatchedRoute
+		}
+		status := strconv.Itoa(ctx.Writer.Status())
+
+		m.httpRequests.WithLabelValues(method, route, status).Inc()
+		m.httpRequestDuration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// metricsMethod keeps the standard methods, so clients cannot create series with made-up ones
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return otherMethod
+}
+
+// txCollector reports the transaction stats of the store when metrics are scraped
+type txCollector struct {
+	store db.Store
+
+	committed        *prometheus.Desc
+	rolledBack       *prometheus.Desc
+	retries          *prometheus.Desc
+	retriesExhausted *prometheus.Desc
+	duration         *prometheus.Desc
+}
+
+func newTxCollector(store db.Store) *txCollector {
+	return &txCollector{
+		store: store,
+		committed: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "db", "tx_committed_total"),
+			"Transactions committed.", nil, nil),
+		rolledBack: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "db", "tx_rolled_back_total"),
+			"Transaction attempts rolled back.", nil, nil),
+		retries: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "db", "tx_retries_total"),
+			"Transaction attempts run again by the reason the previous one failed.", []string{"reason"}, nil),
+		retriesExhausted: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "db", "tx_retries_exhausted_total"),
+			"Transactions that still failed after their last attempt.", nil, nil),
+		duration: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "db", "tx_duration_seconds"),
+			"Duration of transactions, retries included.", nil, nil),
+	}
+}
+
+// Describe sends the descriptors without reading the stats, since the store may not be usable yet when registered
+func (collector *txCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- collector.committed
+	ch <- collector.rolledBack
+	ch <- collector.retries
+	ch <- collector.retriesExhausted
+	ch <- collector.duration
+}
+
+func (collector *txCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := collector.store.TxStats()
+
+	ch <- prometheus.MustNewConstMetric(collector.committed, prometheus.CounterValue, float64(stats.Committed))
+	ch <- prometheus.MustNewConstMetric(collector.rolledBack, prometheus.CounterValue, float64(stats.RolledBack))
+	ch <- prometheus.MustNewConstMetric(collector.retries, prometheus.CounterValue, float64(stats.SerializationRetries), "serialization_failure")
+	ch <- prometheus.MustNewConstMetric(collector.retries, prometheus.CounterValue, float64(stats.DeadlockRetries), "deadlock")
+	ch <- prometheus.MustNewConstMetric(collector.retriesExhausted, prometheus.CounterValue, float64(stats.RetriesExhausted))
+
+	buckets := make(map[float64]uint64, len(db.TxDurationBuckets))
+	for i, bound := range db.TxDurationBuckets {
+		buckets[bound] = uint64(stats.Durations.Buckets[i])
+	}
+	ch <- prometheus.MustNewConstHistogram(collector.duration,
+		uint64(stats.Durations.Count), stats.Durations.Sum.Seconds(), buckets)
+}
+
+// poolCollector reports the stats of a connection pool when metrics are scraped
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	acquiredConns   *prometheus.Desc
+	idleConns       *prometheus.Desc
+	totalConns      *prometheus.Desc
+	maxConns        *prometheus.Desc
+	acquires        *prometheus.Desc
+	emptyAcquires   *prometheus.Desc
+	acquireWait     *prometheus.Desc
+	canceledAcquire *prometheus.Desc
+}
+
+func newPoolCollector(pool *pgxpool.Pool) *poolCollector {
+	desc := func(name string, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "db_pool", name), help, nil, nil)
+	}
+
+	return &poolCollector{
+		pool:            pool,
+		acquiredConns:   desc("acquired_conns", "Connections currently in use."),
+		idleConns:       desc("idle_conns", "Connections currently idle."),
+		totalConns:      desc("total_conns", "Connections currently open, including those being established."),
+		maxConns:        desc("max_conns", "Maximum size of the pool."),
+		acquires:        desc("acquires_total", "Connections acquired from the pool."),
+		emptyAcquires:   desc("empty_acquires_total", "Acquires that waited for a connection because none was idle."),
+		acquireWait:     desc("empty_acquire_wait_seconds_total", "Time spent waiting for a connection because none was idle."),
+		canceledAcquire: desc("canceled_acquires_total", "Acquires canceled before a connection was available."),
+	}
+}
+
+func (collector *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- collector.acquiredConns
+	ch <- collector.idleConns
+	ch <- collector.totalConns
+	ch <- collector.maxConns
+	ch <- collector.acquires
+	ch <- collector.emptyAcquires
+	ch <- collector.acquireWait
+	ch <- collector.canceledAcquire
+}
+
+func (collector *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := collector.pool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(collector.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(collector.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(collector.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(collector.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(collector.acquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(collector.emptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(collector.acquireWait, prometheus.CounterValue, stat.EmptyAcquireWaitTime().Seconds())
+	ch <- prometheus.MustNewConstMetric(collector.canceledAcquire, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+}
+
+// RegisterPoolMetrics reports the stats of the connection pool the store uses in /metrics
+func (server *Server) RegisterPoolMetrics(pool *pgxpool.Pool) {
+	server.metrics.registry.MustRegister(newPoolCollector(pool))
+}
+
+// StartMetrics runs the HTTP server of /metrics on specific address.
+// The metrics include business volumes, so they are served apart from the API,
+// on an address only the internal network can reach.
+// It returns http.ErrServerClosed once Shutdown is called.
+func (server *Server) StartMetrics(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("cannot create listener: %w", err)
+	}
+
+	return server.serveHTTP(listener, server.metricsHandler())
+}
+
+// metricsHandler serves the metrics in the Prometheus text format
+func (server *Server) metricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", server.metrics.handler)
+	return mux
+}
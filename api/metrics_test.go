@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	mockdb "github.com/niloy104/simplebank/db/mock"
+	db "github.com/niloy104/simplebank/db/sqlc"
+	"github.com/niloy104/simplebank/util"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	server := newTestServer(t, nil)
+	server.router.GET("/items/:id", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	requests := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/items/1"},
+		{http.MethodGet, "/items/2"},
+		{http.MethodGet, "/unknown/1"},
+		{http.MethodGet, "/unknown/2"},
+		{"PURGE", "/items/1"},
+	}
+	for _, req := range requests {
+		request, err := http.NewRequest(req.method, req.path, nil)
+		require.NoError(t, err)
+		server.router.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	// paths are counted by their route template, so IDs do not create new series
+	require.Equal(t, 2.0, testutil.ToFloat64(server.metrics.httpRequests.WithLabelValues(http.MethodGet, "/items/:id", "200")))
+	require.Equal(t, 2.0, testutil.ToFloat64(server.metrics.httpRequests.WithLabelValues(http.MethodGet, unmatchedRoute, "404")))
+	require.Equal(t, 1.0, testutil.ToFloat64(server.metrics.httpRequests.WithLabelValues(otherMethod, unmatchedRoute, "404")))
+	require.Equal(t, 3, testutil.CollectAndCount(server.metrics.httpRequests))
+	require.Equal(t, 3, testutil.CollectAndCount(server.metrics.httpRequestDuration))
+}
+
+func TestGetMetrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var durations db.TxDurations
+	for i := range durations.Buckets {
+		durations.Buckets[i] = 4
+	}
+	durations.Count = 4
+	durations.Sum = 40 * time.Millisecond
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		TxStats().
+		Times(1).
+		Return(db.TxStats{Committed: 3, RolledBack: 1, SerializationRetries: 2, Durations: durations})
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+	require.NoError(t, err)
+
+	// the metrics are not served by the public API
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	server.metricsHandler().ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	body := recorder.Body.String()
+	for _, line := range []string{
+		"simplebank_db_tx_committed_total 3",
+		"simplebank_db_tx_rolled_back_total 1",
+		`simplebank_db_tx_retries_total{reason="serialization_failure"} 2`,
+		`simplebank_db_tx_retries_total{reason="deadlock"} 0`,
+		"simplebank_db_tx_duration_seconds_count 4",
+		"simplebank_db_tx_duration_seconds_sum 0.04",
+		`simplebank_db_tx_duration_seconds_bucket{le="0.001"} 4`,
+		"go_goroutines",
+	} {
+		require.Contains(t, body, line)
+	}
+}
+
+func TestMoneyMovedMetrics(t *testing.T) {
+	account := randomAccount("banker")
+	amount := util.RandomInt(1, 1000)
+	result := randomCashTxResult(account, amount)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+		Times(2).
+		Return(account, nil)
+	gomock.InOrder(
+		store.EXPECT().
+			DepositTx(gomock.Any(), gomock.Any()).
+			Times(1).
+			Return(result, nil),
+		store.EXPECT().
+			DepositTx(gomock.Any(), gomock.Any()).
+			Times(1).
+			Return(db.CashTxResult{}, errInternal),
+	)
+
+	server := newTestServer(t, store)
+	for i := 0; i < 2; i++ {
+		data, err := json.Marshal(gin.H{"amount": amount, "currency": account.Currency})
+		require.NoError(t, err)
+
+		url := fmt.Sprintf("/accounts/%d/deposits", account.ID)
+		request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+		require.NoError(t, err)
+		addAuhorization(t, request, server.tokenMaker, authorizationTypeBearer, "banker", util.BankerRole, time.Minute)
+
+		server.router.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	// only the deposit that succeeded is counted
+	require.Equal(t, 1.0, testutil.ToFloat64(server.metrics.moneyMovements.WithLabelValues(movementDeposit, account.Currency)))
+	require.Equal(t, float64(amount), testutil.ToFloat64(server.metrics.moneyMovedAmount.WithLabelValues(movementDeposit, account.Currency)))
+
+	// the series are labelled by kind and currency only
+	expected := fmt.Sprintf(`
+		# HELP simplebank_money_movements_total Transfers, reversals, deposits and withdrawals by kind and currency.
+		# TYPE simplebank_money_movements_total counter
+		simplebank_money_movements_total{currency=%q,kind="deposit"} 1
+	`, account.Currency)
+	err := testutil.CollectAndCompare(server.metrics.moneyMovements, strings.NewReader(expected))
+	require.NoError(t, err)
+}
@@ -2,44 +2,134 @@ package api
 
 import (
 	"errors"
+	"io"
+	"log/slog"
 	"net/http"
+	"runtime/debug"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/niloy104/simplebank/token"
+	"github.com/niloy104/simplebank/util"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	authorizationHeaderKey  = "authorization"
 	authorizationTypeBearer = "bearer"
 	authorizationPayloadKey = "authorization_payload"
+
+	requestIDHeader    = "X-Request-ID"
+	requestIDKey       = "request_id"
+	maxRequestIDLength = 128
 )
 
-func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
+// requestIDMiddleware tags every request with an ID, so error responses can be traced in the logs.
+// The ID sent by the client in X-Request-ID is kept; otherwise a new one is generated.
+// Every log line written with the context of the request carries the ID, and the trace ID when it is traced.
+func requestIDMiddleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		authorizeionHeader := ctx.GetHeader(authorizationHeaderKey)
-		if len(authorizeionHeader) == 0 {
-			err := errors.New("authorization header is not provided")
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
-			return
+		requestID := ctx.GetHeader(requestIDHeader)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.NewString()
 		}
 
-		fields := strings.Fields(authorizeionHeader)
-		if len(fields) != 2 || strings.ToLower(fields[0]) != authorizationTypeBearer {
-			err := errors.New("invalid authorization header format")
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
-			return
+		ctx.Set(requestIDKey, requestID)
+		ctx.Header(requestIDHeader, requestID)
+
+		attrs := []slog.Attr{slog.String(requestIDKey, requestID)}
+		if spanContext := trace.SpanContextFromContext(ctx.Request.Context()); spanContext.HasTraceID() {
+			attrs = append(attrs, slog.String("trace_id", spanContext.TraceID().String()))
 		}
+		addLogAttrs(ctx, attrs...)
+		ctx.Next()
+	}
+}
+
+// addLogAttrs adds attributes to the log lines written with the context of the request from now on
+func addLogAttrs(ctx *gin.Context, attrs ...slog.Attr) {
+	ctx.Request = ctx.Request.WithContext(util.WithLogAttrs(ctx.Request.Context(), attrs...))
+}
 
-		accessToken := fields[1]
-		payload, err := tokenMaker.VerifyToken(accessToken)
+// loggerMiddleware logs every request once it is served.
+// Only the path is logged, since the query string may hold cursors and other opaque values.
+func loggerMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		slog.LogAttrs(ctx, level, "request",
+			slog.String("method", ctx.Request.Method),
+			slog.String("route", ctx.FullPath()),
+			slog.String("path", ctx.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", ctx.ClientIP()),
+		)
+	}
+}
+
+// recoveryMiddleware logs the panics of handlers with their stack, and responds with an internal error.
+// It runs inside the tracing and logging middlewares, so the panic is logged with the attributes of the request.
+func recoveryMiddleware() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(ctx *gin.Context, recovered any) {
+		slog.ErrorContext(ctx, "panic",
+			slog.Any("error", recovered),
+			slog.String("stack", string(debug.Stack())),
+		)
+		writeError(ctx, http.StatusInternalServerError, errorCodeInternal, errInternal)
+	})
+}
+
+func authMiddleware(tokenMaker token.Maker, denylist *token.Denylist) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		payload, err := authenticate(tokenMaker, denylist, ctx.GetHeader(authorizationHeaderKey))
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			writeError(ctx, http.StatusUnauthorized, errorCodeUnauthenticated, err)
 			return
 		}
 
 		ctx.Set(authorizationPayloadKey, payload)
+		addLogAttrs(ctx, slog.String("username", payload.Username))
 		ctx.Next()
 
 	}
 } //have toa some more cors middleware
+
+// authenticate returns the payload of the bearer token in the authorization header,
+// if the token is a valid access token and has not been revoked.
+// Refresh tokens are refused, so they can only be used to renew access tokens.
+// The gRPC server reads the same header from the request metadata.
+func authenticate(tokenMaker token.Maker, denylist *token.Denylist, authorizationHeader string) (*token.Payload, error) {
+	if len(authorizationHeader) == 0 {
+		return nil, errors.New("authorization header is not provided")
+	}
+
+	fields := strings.Fields(authorizationHeader)
+	if len(fields) != 2 || strings.ToLower(fields[0]) != authorizationTypeBearer {
+		return nil, errors.New("invalid authorization header format")
+	}
+
+	accessToken := fields[1]
+	payload, err := tokenMaker.VerifyToken(accessToken, token.TokenTypeAccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if denylist.IsRevoked(payload) {
+		return nil, errors.New("token has been revoked")
+	}
+
+	return payload, nil
+}
@@ -1,14 +1,23 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	mockdb "github.com/niloy104/simplebank/db/mock"
+	db "github.com/niloy104/simplebank/db/sqlc"
 	"github.com/niloy104/simplebank/token"
+	"github.com/niloy104/simplebank/util"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 )
 
 func addAuhorization(
@@ -17,11 +26,13 @@ func addAuhorization(
 	tokenMaker token.Maker,
 	authorizationType string,
 	username string,
+	role string,
 	duration time.Duration,
 ) {
-	token, err := tokenMaker.CreateToken(username, duration)
+	token, payload, err := tokenMaker.CreateToken(username, role, uuid.New(), duration, token.TokenTypeAccessToken)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
+	require.NotEmpty(t, payload)
 
 	authorizationHeader := authorizationType + " " + token
 	request.Header.Set("Authorization", authorizationHeader)
@@ -36,7 +47,7 @@ func TestAuthMiddleware(t *testing.T) {
 		{
 			name: "OK",
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, "user", time.Minute)
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, time.Minute)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
@@ -54,7 +65,7 @@ func TestAuthMiddleware(t *testing.T) {
 		{
 			name: "UnsupportedAuthorization",
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuhorization(t, request, tokenMaker, "unsupported", "user", time.Minute)
+				addAuhorization(t, request, tokenMaker, "unsupported", "user", util.DepositorRole, time.Minute)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
@@ -63,7 +74,7 @@ func TestAuthMiddleware(t *testing.T) {
 		{
 			name: "InvalidAuthorizationFormat",
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuhorization(t, request, tokenMaker, "", "user", time.Minute)
+				addAuhorization(t, request, tokenMaker, "", "user", util.DepositorRole, time.Minute)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
@@ -72,7 +83,18 @@ func TestAuthMiddleware(t *testing.T) {
 		{
 			name: "ExpiredToken",
 			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
-				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, "user", -time.Minute)
+				addAuhorization(t, request, tokenMaker, authorizationTypeBearer, "user", util.DepositorRole, -time.Minute)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "RefreshToken",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				refreshToken, _, err := tokenMaker.CreateToken("user", util.DepositorRole, uuid.New(), time.Hour, token.TokenTypeRefreshToken)
+				require.NoError(t, err)
+				request.Header.Set("Authorization", authorizationTypeBearer+" "+refreshToken)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
@@ -91,7 +113,7 @@ func TestAuthMiddleware(t *testing.T) {
 			authPath := "/auth"
 			server.router.GET(
 				authPath,
-				authMiddleware(server.tokenMaker),
+				authMiddleware(server.tokenMaker, server.denylist),
 				func(ctx *gin.Context) {
 					ctx.JSON(http.StatusOK, gin.H{})
 				},
@@ -107,3 +129,181 @@ func TestAuthMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthMiddlewareRevokedToken(t *testing.T) {
+	testCases := []struct {
+		name          string
+		revoke        func(denylist *token.Denylist, payload *token.Payload)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "RevokedToken",
+			revoke: func(denylist *token.Denylist, payload *token.Payload) {
+				denylist.Revoke(payload.ID, payload.ExpiredAt)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "RevokedUser",
+			revoke: func(denylist *token.Denylist, payload *token.Payload) {
+				denylist.RevokeUser(payload.Username, time.Now())
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "OtherTokenRevoked",
+			revoke: func(denylist *token.Denylist, payload *token.Payload) {
+				other, err := token.NewPayload(payload.Username, payload.Role, uuid.New(), time.Minute, token.TokenTypeAccessToken)
+				require.NoError(t, err)
+				denylist.Revoke(other.ID, other.ExpiredAt)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t, nil)
+
+			authPath := "/auth"
+			server.router.GET(
+				authPath,
+				authMiddleware(server.tokenMaker, server.denylist),
+				func(ctx *gin.Context) {
+					ctx.JSON(http.StatusOK, gin.H{})
+				},
+			)
+
+			accessToken, payload, err := server.tokenMaker.CreateToken("user", util.DepositorRole, uuid.New(), time.Minute, token.TokenTypeAccessToken)
+			require.NoError(t, err)
+			tc.revoke(server.denylist, payload)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, authPath, nil)
+			require.NoError(t, err)
+
+			request.Header.Set("Authorization", authorizationTypeBearer+" "+accessToken)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+// captureLogs sends the logs of the test to the returned buffer
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buffer bytes.Buffer
+	logger, err := util.NewLogger(&buffer, "debug")
+	require.NoError(t, err)
+
+	defaultLogger := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() {
+		slog.SetDefault(defaultLogger)
+	})
+	return &buffer
+}
+
+// findLogRecord returns the first JSON record with the message
+func findLogRecord(t *testing.T, logs *bytes.Buffer, msg string) map[string]any {
+	decoder := json.NewDecoder(bytes.NewReader(logs.Bytes()))
+	for decoder.More() {
+		var record map[string]any
+		require.NoError(t, decoder.Decode(&record))
+		if record["msg"] == msg {
+			return record
+		}
+	}
+
+	t.Fatalf("no log record %q in %s", msg, logs)
+	return nil
+}
+
+func TestRequestLogging(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+		Times(1).
+		Return(db.Account{}, errInternal)
+
+	logs := captureLogs(t)
+	server := newTestServer(t, store)
+
+	url := fmt.Sprintf("/accounts/%d", account.ID)
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	request.Header.Set(requestIDHeader, "request-1")
+	addAuhorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusInternalServerError, recorder.Code)
+
+	// the lines written while serving the request carry its ID and the authenticated user
+	internalError := findLogRecord(t, logs, "internal error")
+	require.Equal(t, "ERROR", internalError["level"])
+	require.Equal(t, "request-1", internalError[requestIDKey])
+	require.Equal(t, user.Username, internalError["username"])
+	require.Equal(t, errInternal.Error(), internalError["error"])
+
+	served := findLogRecord(t, logs, "request")
+	require.Equal(t, "ERROR", served["level"])
+	require.Equal(t, "request-1", served[requestIDKey])
+	require.Equal(t, user.Username, served["username"])
+	require.Equal(t, http.MethodGet, served["method"])
+	require.Equal(t, "/accounts/:id", served["route"])
+	require.Equal(t, url, served["path"])
+	require.Equal(t, float64(http.StatusInternalServerError), served["status"])
+	require.NotContains(t, logs.String(), request.Header.Get(authorizationHeaderKey))
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	logs := captureLogs(t)
+	server := newTestServer(t, nil)
+	server.router.GET("/panic", func(ctx *gin.Context) {
+		panic("boom")
+	})
+
+	request, err := http.NewRequest(http.MethodGet, "/panic", nil)
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusInternalServerError, recorder.Code)
+	requireErrorCode(t, recorder, errorCodeInternal)
+
+	record := findLogRecord(t, logs, "panic")
+	require.Equal(t, "boom", record["error"])
+	require.Equal(t, recorder.Header().Get(requestIDHeader), record[requestIDKey])
+	require.NotEmpty(t, record["stack"])
+}
+
+func TestRequestLogValues(t *testing.T) {
+	logs := captureLogs(t)
+	password := util.RandomString(12)
+	refreshToken := util.RandomString(32)
+
+	slog.Info("requests",
+		slog.Any("login", loginUserRequest{Username: "alice", Password: password}),
+		slog.Any("create", createUserRequest{Username: "alice", Password: password, Email: "alice@email.com"}),
+		slog.Any("renew", renewAccessTokenRequest{RefreshToken: refreshToken}),
+	)
+	require.NotContains(t, logs.String(), password)
+	require.NotContains(t, logs.String(), refreshToken)
+
+	record := findLogRecord(t, logs, "requests")
+	require.Equal(t, map[string]any{"username": "alice", "password": util.Redacted}, record["login"])
+}
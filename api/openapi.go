@@ -0,0 +1,597 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/niloy104/simplebank/util"
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// apiRoute documents a route registered in setupRouter.
+// The request and response values are only used for their types:
+// the OpenAPI schemas are generated from their json, uri, form and binding tags.
+type apiRoute struct {
+	Method      string
+	Path        string
+	Summary     string
+	Description string
+	Tag         string
+	Auth        bool
+	Idempotent  bool
+	URI         any
+	Query       any
+	Body        any
+	// BodyOptional marks a body that may be left out
+	BodyOptional bool
+	Status       int
+	Response     any
+	Errors       []int
+}
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*openAPISchema        `json:"schemas"`
+	SecuritySchemes map[string]openAPISecurityScheme `json:"securitySchemes"`
+}
+
+type openAPISecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+type openAPIOperation struct {
+	Summary     string                      `json:"summary"`
+	Description string                      `json:"description,omitempty"`
+	OperationID string                      `json:"operationId"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+	Parameters  []openAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Enum                 []string                  `json:"enum,omitempty"`
+	Pattern              string                    `json:"pattern,omitempty"`
+	Minimum              *int64                    `json:"minimum,omitempty"`
+	Maximum              *int64                    `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool                      `json:"exclusiveMinimum,omitempty"`
+	MinLength            *int64                    `json:"minLength,omitempty"`
+	MaxLength            *int64                    `json:"maxLength,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+}
+
+const (
+	bearerAuthScheme  = "bearerAuth"
+	errorSchemaName   = "Error"
+	jsonContentType   = "application/json"
+	openAPIDocVersion = "3.0.3"
+)
+
+// pathParamPattern matches the :name and *name parameters of gin paths
+var pathParamPattern = regexp.MustCompile(`[:*]([A-Za-z_]+)`)
+
+// newOpenAPIDocument generates the OpenAPI document of the routes
+func newOpenAPIDocument(routes []apiRoute) ([]byte, error) {
+	generator := &schemaGenerator{
+		schemas: map[string]*openAPISchema{
+			errorSchemaName: {
+				Type: "object",
+				Properties: map[string]*openAPISchema{
+					"code":       {Type: "string", Description: "stable code for clients to branch on"},
+					"message":    {Type: "string"},
+					"details":    {Type: "object", Description: "extra facts about the failure, depending on the code"},
+					"request_id": {Type: "string"},
+					"field_errors": {
+						Type: "array",
+						Items: &openAPISchema{
+							Type: "object",
+							Properties: map[string]*openAPISchema{
+								"field":   {Type: "string"},
+								"rule":    {Type: "string"},
+								"message": {Type: "string"},
+							},
+							Required: []string{"field", "rule", "message"},
+						},
+					},
+				},
+				Required: []string{"code", "message"},
+			},
+		},
+		types: map[string]reflect.Type{},
+	}
+
+	doc := openAPIDocument{
+		OpenAPI: openAPIDocVersion,
+		Info: openAPIInfo{
+			Title:   "Simple Bank API",
+			Version: "1.0.0",
+		},
+		Paths: map[string]map[string]*openAPIOperation{},
+		Components: openAPIComponents{
+			Schemas: generator.schemas,
+			SecuritySchemes: map[string]openAPISecurityScheme{
+				bearerAuthScheme: {Type: "http", Scheme: "bearer", BearerFormat: "PASETO"},
+			},
+		},
+	}
+
+	for _, route := range routes {
+		path := openAPIPath(route.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*openAPIOperation{}
+		}
+
+		method := strings.ToLower(route.Method)
+		if _, ok := doc.Paths[path][method]; ok {
+			return nil, fmt.Errorf("route %s %s is documented twice", route.Method, route.Path)
+		}
+
+		operation, err := generator.operation(route)
+		if err != nil {
+			return nil, fmt.Errorf("cannot document route %s %s: %w", route.Method, route.Path, err)
+		}
+		doc.Paths[path][method] = operation
+	}
+
+	return json.Marshal(doc)
+}
+
+// openAPIPath converts a gin path such as /accounts/:id into /accounts/{id}
+func openAPIPath(path string) string {
+	return pathParamPattern.ReplaceAllString(path, "{$1}")
+}
+
+func (generator *schemaGenerator) operation(route apiRoute) (*openAPIOperation, error) {
+	operation := &openAPIOperation{
+		Summary:     route.Summary,
+		Description: route.Description,
+		OperationID: operationID(route),
+		Responses:   map[string]*openAPIResponse{},
+	}
+	if route.Tag != "" {
+		operation.Tags = []string{route.Tag}
+	}
+	if route.Auth {
+		operation.Security = []map[string][]string{{bearerAuthScheme: {}}}
+	}
+
+	if route.URI != nil {
+		params, err := generator.parameters(route.URI, "uri", "path")
+		if err != nil {
+			return nil, err
+		}
+		operation.Parameters = append(operation.Parameters, params...)
+	}
+
+	for _, match := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+		if !hasParameter(operation.Parameters, match[1]) {
+			operation.Parameters = append(operation.Parameters, openAPIParameter{
+				Name:     match[1],
+				In:       "path",
+				Required: true,
+				Schema:   &openAPISchema{Type: "string"},
+			})
+		}
+	}
+
+	if route.Query != nil {
+		params, err := generator.parameters(route.Query, "form", "query")
+		if err != nil {
+			return nil, err
+		}
+		operation.Parameters = append(operation.Parameters, params...)
+	}
+
+	if route.Idempotent {
+		operation.Parameters = append(operation.Parameters, openAPIParameter{
+			Name:   idempotencyKeyHeader,
+			In:     "header",
+			Schema: &openAPISchema{Type: "string", MaxLength: int64Ptr(maxIdempotencyKeyLength)},
+		})
+	}
+
+	if route.Body != nil {
+		schema, err := generator.schema(reflect.TypeOf(route.Body))
+		if err != nil {
+			return nil, err
+		}
+		operation.RequestBody = &openAPIRequestBody{
+			Required: !route.BodyOptional,
+			Content:  map[string]openAPIMediaType{jsonContentType: {Schema: schema}},
+		}
+	}
+
+	success := &openAPIResponse{Description: http.StatusText(route.Status)}
+	if route.Response != nil {
+		schema, err := generator.schema(reflect.TypeOf(route.Response))
+		if err != nil {
+			return nil, err
+		}
+		success.Content = map[string]openAPIMediaType{jsonContentType: {Schema: schema}}
+	}
+	operation.Responses[strconv.Itoa(route.Status)] = success
+
+	errorStatuses := append([]int{http.StatusInternalServerError}, route.Errors...)
+	if route.URI != nil || route.Query != nil || route.Body != nil {
+		errorStatuses = append(errorStatuses, http.StatusBadRequest)
+	}
+	if route.Auth {
+		errorStatuses = append(errorStatuses, http.StatusUnauthorized)
+	}
+	for _, status := range errorStatuses {
+		operation.Responses[strconv.Itoa(status)] = &openAPIResponse{
+			Description: http.StatusText(status),
+			Content: map[string]openAPIMediaType{
+				jsonContentType: {Schema: &openAPISchema{Ref: schemaRef(errorSchemaName)}},
+			},
+		}
+	}
+
+	return operation, nil
+}
+
+// operationID names the operation after its method and path, e.g. post_accounts_id_freeze
+func operationID(route apiRoute) string {
+	id := strings.ToLower(route.Method)
+	for _, segment := range strings.Split(route.Path, "/") {
+		segment = strings.TrimLeft(segment, ":*")
+		segment = strings.NewReplacer(".", "", "-", "_").Replace(segment)
+		if segment != "" {
+			id += "_" + segment
+		}
+	}
+	return id
+}
+
+func hasParameter(params []openAPIParameter, name string) bool {
+	return slices.ContainsFunc(params, func(param openAPIParameter) bool {
+		return param.Name == name
+	})
+}
+
+// schemaGenerator builds schemas from Go types.
+// Named structs are added to the components and referenced.
+type schemaGenerator struct {
+	schemas map[string]*openAPISchema
+	types   map[string]reflect.Type
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	timestamptzType = reflect.TypeOf(pgtype.Timestamptz{})
+	int8Type        = reflect.TypeOf(pgtype.Int8{})
+	textType        = reflect.TypeOf(pgtype.Text{})
+	numericType     = reflect.TypeOf(pgtype.Numeric{})
+	uuidType        = reflect.TypeOf(uuid.UUID{})
+	nullUUIDType    = reflect.TypeOf(uuid.NullUUID{})
+)
+
+func (generator *schemaGenerator) schema(t reflect.Type) (*openAPISchema, error) {
+	switch t {
+	case timeType:
+		return &openAPISchema{Type: "string", Format: "date-time"}, nil
+	case timestamptzType:
+		return &openAPISchema{Type: "string", Format: "date-time", Nullable: true}, nil
+	case int8Type:
+		return &openAPISchema{Type: "integer", Format: "int64", Nullable: true}, nil
+	case textType:
+		return &openAPISchema{Type: "string", Nullable: true}, nil
+	case numericType:
+		return &openAPISchema{Type: "number", Nullable: true}, nil
+	case uuidType:
+		return &openAPISchema{Type: "string", Format: "uuid"}, nil
+	case nullUUIDType:
+		return &openAPISchema{Type: "string", Format: "uuid", Nullable: true}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema, err := generator.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return schema, nil
+	case reflect.String:
+		return &openAPISchema{Type: "string"}, nil
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int64:
+		return &openAPISchema{Type: "integer", Format: "int64"}, nil
+	case reflect.Int32:
+		return &openAPISchema{Type: "integer", Format: "int32"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := generator.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &openAPISchema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		values, err := generator.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &openAPISchema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		return generator.structSchema(t)
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// structSchema adds the schema of a named struct to the components and returns a reference to it
+func (generator *schemaGenerator) structSchema(t reflect.Type) (*openAPISchema, error) {
+	name := schemaName(t)
+	if existing, ok := generator.types[name]; ok {
+		if existing != t {
+			return nil, fmt.Errorf("schema %s is used by both %s and %s", name, existing, t)
+		}
+		return &openAPISchema{Ref: schemaRef(name)}, nil
+	}
+
+	// register the type first, so recursive types terminate
+	generator.types[name] = t
+
+	schema := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+	err := generator.addFields(schema, t, "json")
+	if err != nil {
+		return nil, err
+	}
+
+	generator.schemas[name] = schema
+	return &openAPISchema{Ref: schemaRef(name)}, nil
+}
+
+// addFields adds the fields of the struct to the schema, flattening embedded structs
+func (generator *schemaGenerator) addFields(schema *openAPISchema, t reflect.Type, tagKey string) error {
+	names := fieldNames(t, tagKey)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get(tagKey) == "" {
+			if err := generator.addFields(schema, field.Type, tagKey); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := fieldName(field, tagKey)
+		if name == "" {
+			continue
+		}
+
+		fieldSchema, err := generator.schema(field.Type)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+
+		required := applyBindingRules(fieldSchema, field, names)
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = fieldSchema
+	}
+
+	return nil
+}
+
+// parameters generates the path or query parameters of a request struct
+func (generator *schemaGenerator) parameters(req any, tagKey string, in string) ([]openAPIParameter, error) {
+	t := reflect.TypeOf(req)
+	schema := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+	if err := generator.addFields(schema, t, tagKey); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	params := make([]openAPIParameter, 0, len(names))
+	for _, name := range names {
+		params = append(params, openAPIParameter{
+			Name:     name,
+			In:       in,
+			Required: in == "path" || slices.Contains(schema.Required, name),
+			Schema:   schema.Properties[name],
+		})
+	}
+	return params, nil
+}
+
+// fieldName returns the name of the field in the tag, or an empty name if it is not serialized
+func fieldName(field reflect.StructField, tagKey string) string {
+	if !field.IsExported() {
+		return ""
+	}
+
+	name, _, _ := strings.Cut(field.Tag.Get(tagKey), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		if tagKey != "json" {
+			return ""
+		}
+		return field.Name
+	}
+	return name
+}
+
+// fieldNames maps the Go names of the fields to their serialized names,
+// so rules comparing fields can name them as clients see them
+func fieldNames(t reflect.Type, tagKey string) map[string]string {
+	names := map[string]string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if name := fieldName(field, tagKey); name != "" {
+			names[field.Name] = name
+		}
+	}
+	return names
+}
+
+// applyBindingRules translates the binding rules of the field into the schema.
+// It reports whether the field is required.
+func applyBindingRules(schema *openAPISchema, field reflect.StructField, names map[string]string) bool {
+	tag := field.Tag.Get("binding")
+	if tag == "" {
+		return false
+	}
+
+	isString := schema.Type == "string"
+	required := false
+	var notes []string
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "min", "gte":
+			if n, err := strconv.ParseInt(param, 10, 64); err == nil {
+				if isString {
+					schema.MinLength = &n
+				} else {
+					schema.Minimum = &n
+				}
+			}
+		case "max", "lte":
+			if n, err := strconv.ParseInt(param, 10, 64); err == nil {
+				if isString {
+					schema.MaxLength = &n
+				} else {
+					schema.Maximum = &n
+				}
+			}
+		case "gt":
+			if n, err := strconv.ParseInt(param, 10, 64); err == nil {
+				schema.Minimum = &n
+				schema.ExclusiveMinimum = true
+			}
+		case "alphanum":
+			schema.Pattern = "^[a-zA-Z0-9]+$"
+		case "email":
+			schema.Format = "email"
+		case "uuid":
+			schema.Format = "uuid"
+		case "currency":
+			schema.Enum = util.SupportedCurrencies
+		case "cron":
+			notes = append(notes, "standard 5-field cron expression, evaluated in UTC")
+		case "oneof":
+			schema.Enum = strings.Fields(param)
+		case "nefield":
+			notes = append(notes, "must differ from "+names[param])
+		case "excluded_with":
+			notes = append(notes, "cannot be set together with "+names[param])
+		case "required_without_all":
+			var others []string
+			for _, other := range strings.Fields(param) {
+				others = append(others, names[other])
+			}
+			notes = append(notes, "required when none of "+strings.Join(others, ", ")+" is set")
+		}
+	}
+
+	if len(notes) > 0 {
+		schema.Description = strings.Join(notes, "; ")
+	}
+	return required
+}
+
+// schemaName names the component of a struct, e.g. Account or CreateAccountRequest
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	if name == "" {
+		return "Object"
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+func schemaRef(name string) string {
+	return "#/components/schemas/" + name
+}
+
+func int64Ptr(n int64) *int64 {
+	return &n
+}
+
+//go:embed docs/index.html
+var swaggerIndex []byte
+
+// getOpenAPI serves the OpenAPI document of the HTTP API
+func (server *Server) getOpenAPI(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, jsonContentType, server.openAPI)
+}
+
+// getDocs serves the Swagger UI, which renders the OpenAPI document
+func (server *Server) getDocs(ctx *gin.Context) {
+	path := ctx.Param("filepath")
+	if path == "/" || path == "/index.html" {
+		ctx.Data(http.StatusOK, "text/html; charset=utf-8", swaggerIndex)
+		return
+	}
+
+	ctx.FileFromFS(path, http.FS(swaggerFiles.FS))
+}
@@ -0,0 +1,322 @@
+package api
+
+import (
+	"net/http"
+
+	db "github.com/niloy104/simplebank/db/sqlc"
+)
+
+const legacyPaginationNote = "Sending page_id keeps the legacy offset pagination, which returns a plain array instead of the paginated object."
+
+// apiRoutes documents every route registered in setupRouter.
+// TestOpenAPICoversRoutes fails when a route is missing here.
+var apiRoutes = []apiRoute{
+	{
+		Method:   http.MethodPost,
+		Path:     "/users",
+		Summary:  "Create a user",
+		Tag:      "users",
+		Body:     createUserRequest{},
+		Status:   http.StatusCreated,
+		Response: userResponse{},
+		Errors:   []int{http.StatusConflict},
+	},
+	{
+		Method:      http.MethodPost,
+		Path:        "/users/login",
+		Summary:     "Log a user in",
+		Description: "Logins are rate limited by client IP and by username, and too many failed attempts lock the user out for a while.",
+		Tag:         "users",
+		Body:        loginUserRequest{},
+		Status:      http.StatusOK,
+		Response:    loginUserResponse{},
+		Errors:      []int{http.StatusUnauthorized, http.StatusNotFound, http.StatusLocked, http.StatusTooManyRequests},
+	},
+	{
+		Method:   http.MethodPost,
+		Path:     "/tokens/renew_access",
+		Summary:  "Renew an access token with a refresh token",
+		Tag:      "tokens",
+		Body:     renewAccessTokenRequest{},
+		Status:   http.StatusOK,
+		Response: renewAccessTokenResponse{},
+		Errors:   []int{http.StatusUnauthorized},
+	},
+	{
+		Method:      http.MethodGet,
+		Path:        "/.well-known/jwks.json",
+		Summary:     "List the public keys that verify access tokens",
+		Description: "Only available when tokens are signed with a private key.",
+		Tag:         "tokens",
+		Status:      http.StatusOK,
+		Response:    listPublicKeysResponse{},
+		Errors:      []int{http.StatusNotFound},
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/openapi.json",
+		Summary: "Get this OpenAPI document",
+		Tag:     "docs",
+		Status:  http.StatusOK,
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    "/docs/*filepath",
+		Summary: "Browse this document with Swagger UI",
+		Tag:     "docs",
+		Status:  http.StatusOK,
+		Errors:  []int{http.StatusNotFound},
+	},
+	{
+		Method:   http.MethodGet,
+		Path:     "/healthz",
+		Summary:  "Check that the server is alive",
+		Tag:      "health",
+		Status:   http.StatusOK,
+		Response: healthResponse{},
+	},
+	{
+		Method:      http.MethodGet,
+		Path:        "/readyz",
+		Summary:     "Check that the server is ready to serve requests",
+		Description: "Reports the result of every dependency check, and fails while the server is shutting down.",
+		Tag:         "health",
+		Status:      http.StatusOK,
+		Response:    healthResponse{},
+		Errors:      []int{http.StatusServiceUnavailable},
+	},
+	{
+		Method:  http.MethodPost,
+		Path:    "/users/logout",
+		Summary: "Log out of a session and revoke the access token",
+		Tag:     "users",
+		Auth:    true,
+		Body:    logoutUserRequest{},
+		Status:  http.StatusNoContent,
+		Errors:  []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		Method:  http.MethodPost,
+		Path:    "/users/logout_all",
+		Summary: "Log out of every session and revoke all tokens of the user",
+		Tag:     "users",
+		Auth:    true,
+		Status:  http.StatusNoContent,
+		Errors:  []int{http.StatusNotFound},
+	},
+	{
+		Method:     http.MethodPost,
+		Path:       "/accounts",
+		Summary:    "Create an account",
+		Tag:        "accounts",
+		Auth:       true,
+		Idempotent: true,
+		Body:       createAccountRequest{},
+		Status:     http.StatusCreated,
+		Response:   db.Account{},
+		Errors:     []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	{
+		Method:   http.MethodGet,
+		Path:     "/accounts/:id",
+		Summary:  "Get an account",
+		Tag:      "accounts",
+		Auth:     true,
+		URI:      getAccountRequest{},
+		Status:   http.StatusOK,
+		Response: db.Account{},
+		Errors:   []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		Method:      http.MethodGet,
+		Path:        "/accounts",
+		Summary:     "List the accounts of a user",
+		Description: legacyPaginationNote,
+		Tag:         "accounts",
+		Auth:        true,
+		Query:       listAccountRequest{},
+		Status:      http.StatusOK,
+		Response:    listAccountResponse{},
+		Errors:      []int{http.StatusForbidden},
+	},
+	{
+		Method:   http.MethodPost,
+		Path:     "/accounts/:id/freeze",
+		Summary:  "Freeze an account",
+		Tag:      "accounts",
+		Auth:     true,
+		URI:      freezeAccountRequest{},
+		Status:   http.StatusOK,
+		Response: db.Account{},
+		Errors:   []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		Method:   http.MethodPost,
+		Path:     "/accounts/:id/unfreeze",
+		Summary:  "Unfreeze an account",
+		Tag:      "accounts",
+		Auth:     true,
+		URI:      freezeAccountRequest{},
+		Status:   http.StatusOK,
+		Response: db.Account{},
+		Errors:   []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		Method:   http.MethodPost,
+		Path:     "/accounts/:id/deposits",
+		Summary:  "Deposit cash into an account",
+		Tag:      "accounts",
+		Auth:     true,
+		URI:      cashAccountRequest{},
+		Body:     cashRequest{},
+		Status:   http.StatusCreated,
+		Response: cashResponse{},
+		Errors:   []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		Method:   http.MethodPost,
+		Path:     "/accounts/:id/withdrawals",
+		Summary:  "Withdraw cash from an account",
+		Tag:      "accounts",
+		Auth:     true,
+		URI:      cashAccountRequest{},
+		Body:     cashRequest{},
+		Status:   http.StatusCreated,
+		Response: cashResponse{},
+		Errors:   []int{http.StatusForbidden, http.StatusNotFound, http.StatusUnprocessableEntity},
+	},
+	{
+		Method:      http.MethodGet,
+		Path:        "/accounts/:id/entries",
+		Summary:     "List the entries of an account",
+		Description: legacyPaginationNote,
+		Tag:         "accounts",
+		Auth:        true,
+		URI:         accountHistoryURI{},
+		Query:       accountHistoryRequest{},
+		Status:      http.StatusOK,
+		Response:    listEntriesResponse{},
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		Method:      http.MethodGet,
+		Path:        "/accounts/:id/transfers",
+		Summary:     "List the transfers of an account",
+		Description: legacyPaginationNote,
+		Tag:         "accounts",
+		Auth:        true,
+		URI:         accountHistoryURI{},
+		Query:       accountHistoryRequest{},
+		Status:      http.StatusOK,
+		Response:    listTransfersResponse{},
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		Method:      http.MethodPost,
+		Path:        "/transfers",
+		Summary:     "Transfer money between accounts",
+		Description: "Sending quote_id makes a cross-currency transfer at the rate of the quote.",
+		Tag:         "transfers",
+		Auth:        true,
+		Idempotent:  true,
+		Body:        transferRequest{},
+		Status:      http.StatusCreated,
+		Response:    db.TransferTxResult{},
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	{
+		Method:       http.MethodPost,
+		Path:         "/transfers/:id/reverse",
+		Summary:      "Reverse a transfer, fully or partially",
+		Description:  "Without an amount, whatever is left of the transfer is reversed.",
+		Tag:          "transfers",
+		Auth:         true,
+		URI:          reverseTransferURI{},
+		Body:         reverseTransferRequest{},
+		BodyOptional: true,
+		Status:       http.StatusCreated,
+		Response:     db.ReverseTransferTxResult{},
+		Errors:       []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	{
+		Method:   http.MethodPost,
+		Path:     "/fx/quotes",
+		Summary:  "Quote an exchange rate for a cross-currency transfer",
+		Tag:      "transfers",
+		Auth:     true,
+		Body:     createFXQuoteRequest{},
+		Status:   http.StatusCreated,
+		Response: fxQuoteResponse{},
+		Errors:   []int{http.StatusForbidden, http.StatusUnprocessableEntity},
+	},
+	{
+		Method:      http.MethodPost,
+		Path:        "/scheduled_transfers",
+		Summary:     "Schedule a transfer",
+		Description: "It repeats on a cron expression or a fixed interval; without either it runs once at start_at.",
+		Tag:         "scheduled transfers",
+		Auth:        true,
+		Body:        createScheduledTransferRequest{},
+		Status:      http.StatusCreated,
+		Response:    db.ScheduledTransfer{},
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		Method:      http.MethodGet,
+		Path:        "/scheduled_transfers",
+		Summary:     "List the scheduled transfers of a user",
+		Description: legacyPaginationNote,
+		Tag:         "scheduled transfers",
+		Auth:        true,
+		Query:       listScheduledTransfersRequest{},
+		Status:      http.StatusOK,
+		Response:    listScheduledTransfersResponse{},
+		Errors:      []int{http.StatusForbidden},
+	},
+	{
+		Method:   http.MethodGet,
+		Path:     "/scheduled_transfers/:id",
+		Summary:  "Get a scheduled transfer",
+		Tag:      "scheduled transfers",
+		Auth:     true,
+		URI:      scheduledTransferRequest{},
+		Status:   http.StatusOK,
+		Response: db.ScheduledTransfer{},
+		Errors:   []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		Method:   http.MethodPatch,
+		Path:     "/scheduled_transfers/:id",
+		Summary:  "Change the amount of a scheduled transfer, or pause and resume it",
+		Tag:      "scheduled transfers",
+		Auth:     true,
+		URI:      scheduledTransferRequest{},
+		Body:     updateScheduledTransferRequest{},
+		Status:   http.StatusOK,
+		Response: db.ScheduledTransfer{},
+		Errors:   []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		Method:  http.MethodDelete,
+		Path:    "/scheduled_transfers/:id",
+		Summary: "Delete a scheduled transfer",
+		Tag:     "scheduled transfers",
+		Auth:    true,
+		URI:     scheduledTransferRequest{},
+		Status:  http.StatusNoContent,
+		Errors:  []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		Method:      http.MethodGet,
+		Path:        "/scheduled_transfers/:id/runs",
+		Summary:     "List the runs of a scheduled transfer",
+		Description: legacyPaginationNote,
+		Tag:         "scheduled transfers",
+		Auth:        true,
+		URI:         scheduledTransferRequest{},
+		Query:       listScheduledTransferRunsRequest{},
+		Status:      http.StatusOK,
+		Response:    listScheduledTransferRunsResponse{},
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	},
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/niloy104/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func loadOpenAPIDocument(t *testing.T, server *Server) openAPIDocument {
+	var doc openAPIDocument
+	err := json.Unmarshal(server.openAPI, &doc)
+	require.NoError(t, err)
+	return doc
+}
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	server := newTestServer(t, nil)
+	doc := loadOpenAPIDocument(t, server)
+
+	registered := map[string]bool{}
+	for _, route := range server.router.Routes() {
+		path := openAPIPath(route.Path)
+		method := strings.ToLower(route.Method)
+		registered[method+" "+path] = true
+
+		_, ok := doc.Paths[path][method]
+		require.Truef(t, ok, "route %s %s is not documented in apiRoutes", route.Method, route.Path)
+	}
+
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			require.Truef(t, registered[method+" "+path], "documented route %s %s is not registered", method, path)
+		}
+	}
+}
+
+func TestOpenAPIValidationRules(t *testing.T) {
+	server := newTestServer(t, nil)
+	doc := loadOpenAPIDocument(t, server)
+	schemas := doc.Components.Schemas
+
+	currency := schemas["CreateAccountRequest"].Properties["currency"]
+	require.Equal(t, util.SupportedCurrencies, currency.Enum)
+	require.Contains(t, schemas["CreateAccountRequest"].Required, "currency")
+
+	username := schemas["CreateUserRequest"].Properties["username"]
+	require.Equal(t, "^[a-zA-Z0-9]+$", username.Pattern)
+
+	password := schemas["CreateUserRequest"].Properties["password"]
+	require.Equal(t, int64(6), *password.MinLength)
+
+	email := schemas["CreateUserRequest"].Properties["email"]
+	require.Equal(t, "email", email.Format)
+
+	amount := schemas["TransferRequest"].Properties["amount"]
+	require.Equal(t, int64(0), *amount.Minimum)
+	require.True(t, amount.ExclusiveMinimum)
+
+	toCurrency := schemas["CreateFXQuoteRequest"].Properties["to_currency"]
+	require.Equal(t, "must differ from from_currency", toCurrency.Description)
+
+	listAccounts := doc.Paths["/accounts"]["get"]
+	var pageSize *openAPIParameter
+	for i := range listAccounts.Parameters {
+		if listAccounts.Parameters[i].Name == "page_size" {
+			pageSize = &listAccounts.Parameters[i]
+		}
+	}
+	require.NotNil(t, pageSize)
+	require.Equal(t, "query", pageSize.In)
+	require.True(t, pageSize.Required)
+	require.Equal(t, int64(5), *pageSize.Schema.Minimum)
+	require.Equal(t, int64(10), *pageSize.Schema.Maximum)
+
+	direction := doc.Paths["/accounts/{id}/entries"]["get"]
+	require.True(t, hasParameter(direction.Parameters, "id"))
+	require.True(t, hasParameter(direction.Parameters, "direction"))
+
+	reverse := doc.Paths["/transfers/{id}/reverse"]["post"]
+	require.False(t, reverse.RequestBody.Required)
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	testCases := []struct {
+		name          string
+		url           string
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Document",
+			url:  "/openapi.json",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var doc openAPIDocument
+				err := json.Unmarshal(recorder.Body.Bytes(), &doc)
+				require.NoError(t, err)
+				require.Equal(t, openAPIDocVersion, doc.OpenAPI)
+			},
+		},
+		{
+			name: "SwaggerUI",
+			url:  "/docs/",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), `url: "/openapi.json"`)
+			},
+		},
+		{
+			name: "SwaggerUIAsset",
+			url:  "/docs/swagger-ui-bundle.js",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.NotZero(t, recorder.Body.Len())
+			},
+		},
+		{
+			name: "NotFound",
+			url:  "/docs/missing.js",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t, nil)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, tc.url, nil)
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
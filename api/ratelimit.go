@@ -0,0 +1,336 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/niloy104/simplebank/db/sqlc"
+	"github.com/niloy104/simplebank/util"
+)
+
+const (
+	rateLimitLimitHeader     = "X-RateLimit-Limit"
+	rateLimitRemainingHeader = "X-RateLimit-Remaining"
+	rateLimitResetHeader     = "X-RateLimit-Reset"
+	retryAfterHeader         = "Retry-After"
+
+	memoryRateLimitStore = "memory"
+	dbRateLimitStore     = "postgres"
+
+	// loginRateLimitScope is shared by the logins of every transport, so they spend the same buckets
+	loginRateLimitScope = "login"
+)
+
+var errRateLimited = errors.New("too many requests, try again later")
+
+// RateLimit allows Burst requests at once, with tokens refilled steadily
+// so the bucket is full again after Period
+type RateLimit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// disabled reports whether the limit lets every request through
+func (limit RateLimit) disabled() bool {
+	return limit.Burst <= 0 || limit.Period <= 0
+}
+
+// refillRate returns the tokens added to a bucket per second
+func (limit RateLimit) refillRate() float64 {
+	return float64(limit.Burst) / limit.Period.Seconds()
+}
+
+// RateLimitResult is the state of a bucket after a request took a token from it
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is the time until the bucket has a token again, when the request was not allowed
+	RetryAfter time.Duration
+	// ResetAfter is the time until the bucket is full again
+	ResetAfter time.Duration
+}
+
+// RateLimiter is an interface for token buckets shared by the requests with the same key
+type RateLimiter interface {
+	// Allow takes a token from the bucket of the key at now, if it has one
+	Allow(ctx context.Context, key string, limit RateLimit, now time.Time) (RateLimitResult, error)
+	// Prune forgets the buckets not used since before
+	Prune(ctx context.Context, before time.Time) error
+}
+
+// tokenBucket holds the tokens of a key at the time they were last counted
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// refill adds the tokens earned since the bucket was last counted
+func (bucket tokenBucket) refill(limit RateLimit, now time.Time) tokenBucket {
+	elapsed := max(now.Sub(bucket.updatedAt).Seconds(), 0)
+	return tokenBucket{
+		tokens:    min(float64(limit.Burst), bucket.tokens+elapsed*limit.refillRate()),
+		updatedAt: now,
+	}
+}
+
+// result describes the bucket after a request was allowed or not
+func (bucket tokenBucket) result(limit RateLimit, allowed bool) RateLimitResult {
+	rate := limit.refillRate()
+	result := RateLimitResult{
+		Allowed:    allowed,
+		Limit:      limit.Burst,
+		Remaining:  int(math.Floor(bucket.tokens)),
+		ResetAfter: secondsToDuration((float64(limit.Burst) - bucket.tokens) / rate),
+	}
+	if !allowed {
+		result.RetryAfter = secondsToDuration((1 - bucket.tokens) / rate)
+	}
+	return result
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(max(seconds, 0) * float64(time.Second))
+}
+
+// MemoryRateLimiter keeps token buckets in memory, so each replica limits requests on its own
+type MemoryRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]tokenBucket
+}
+
+// NewMemoryRateLimiter creates a rate limiter without buckets
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{buckets: make(map[string]tokenBucket)}
+}
+
+// Allow takes a token from the bucket of the key, starting with a full bucket for a new key
+func (limiter *MemoryRateLimiter) Allow(ctx context.Context, key string, limit RateLimit, now time.Time) (RateLimitResult, error) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	bucket, ok := limiter.buckets[key]
+	if !ok {
+		bucket = tokenBucket{tokens: float64(limit.Burst), updatedAt: now}
+	}
+
+	bucket = bucket.refill(limit, now)
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+	limiter.buckets[key] = bucket
+
+	return bucket.result(limit, allowed), nil
+}
+
+// Prune forgets the buckets not used since before
+func (limiter *MemoryRateLimiter) Prune(ctx context.Context, before time.Time) error {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	for key, bucket := range limiter.buckets {
+		if bucket.updatedAt.Before(before) {
+			delete(limiter.buckets, key)
+		}
+	}
+	return nil
+}
+
+// DBRateLimiter keeps token buckets in the rate_limit_buckets table, so all replicas share them
+type DBRateLimiter struct {
+	store db.Querier
+}
+
+// NewDBRateLimiter creates a rate limiter storing its buckets in the database
+func NewDBRateLimiter(store db.Querier) *DBRateLimiter {
+	return &DBRateLimiter{store: store}
+}
+
+// Allow takes a token from the bucket of the key in a single statement, so concurrent requests cannot overspend it
+func (limiter *DBRateLimiter) Allow(ctx context.Context, key string, limit RateLimit, now time.Time) (RateLimitResult, error) {
+	taken, err := limiter.store.TakeRateLimitToken(ctx, db.TakeRateLimitTokenParams{
+		Key:        key,
+		Burst:      float64(limit.Burst),
+		Now:        pgtype.Timestamptz{Time: now, Valid: true},
+		RefillRate: limit.refillRate(),
+	})
+	if err == nil {
+		return tokenBucket{tokens: taken.Tokens}.result(limit, true), nil
+	}
+	if !errors.Is(err, db.ErrRecordNotFound) {
+		return RateLimitResult{}, err
+	}
+
+	// the bucket is empty: read it to tell when it has a token again
+	empty, err := limiter.store.GetRateLimitBucket(ctx, key)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	bucket := tokenBucket{tokens: empty.Tokens, updatedAt: empty.UpdatedAt.Time}.refill(limit, now)
+	return bucket.result(limit, false), nil
+}
+
+// Prune deletes the buckets not used since before
+func (limiter *DBRateLimiter) Prune(ctx context.Context, before time.Time) error {
+	return limiter.store.DeleteRateLimitBuckets(ctx, pgtype.Timestamptz{Time: before, Valid: true})
+}
+
+// newRateLimiter keeps buckets in the configured store, in memory by default
+func newRateLimiter(config util.Config, store db.Store) (RateLimiter, error) {
+	switch config.RateLimitStore {
+	case "", memoryRateLimitStore:
+		return NewMemoryRateLimiter(), nil
+	case dbRateLimitStore:
+		return NewDBRateLimiter(store), nil
+	}
+
+	return nil, fmt.Errorf("unsupported rate limit store %q", config.RateLimitStore)
+}
+
+// rateLimitKey returns the key of the bucket a request counts against, or false when it does not count
+type rateLimitKey func(ctx *gin.Context) (string, bool)
+
+// loginRateLimit is the limit of login attempts of a client IP address or a username
+func (server *Server) loginRateLimit() RateLimit {
+	return RateLimit{Burst: server.config.LoginRateLimit, Period: server.config.LoginRateLimitPeriod}
+}
+
+// clientIPKey counts requests by the IP address of the client
+func clientIPKey(ctx *gin.Context) (string, bool) {
+	return clientIPRateLimitKey(ctx.ClientIP()), true
+}
+
+// loginUsernameKey counts requests by the username they log in as,
+// so guessing the password of one user from many addresses is limited too
+func loginUsernameKey(ctx *gin.Context) (string, bool) {
+	var req struct {
+		Username string `json:"username"`
+	}
+	if err := ctx.ShouldBindBodyWith(&req, binding.JSON); err != nil {
+		return "", false
+	}
+	return usernameRateLimitKey(req.Username)
+}
+
+func clientIPRateLimitKey(clientIP string) string {
+	return "ip:" + clientIP
+}
+
+func usernameRateLimitKey(username string) (string, bool) {
+	if username == "" {
+		return "", false
+	}
+	return "user:" + username, true
+}
+
+// takeRateLimit takes a token from the bucket of every key, scoped to the limited operation,
+// and returns the most restrictive result, or nil when no bucket was checked.
+// Keys are skipped when the limiter fails, so an outage of its store does not lock everybody out.
+func takeRateLimit(ctx context.Context, limiter RateLimiter, limit RateLimit, scope string, keys ...string) *RateLimitResult {
+	if limit.disabled() {
+		return nil
+	}
+
+	now := time.Now()
+
+	var strictest *RateLimitResult
+	for _, key := range keys {
+		result, err := limiter.Allow(ctx, scope+":"+key, limit, now)
+		if err != nil {
+			slog.WarnContext(ctx, "cannot check rate limit", slog.String("scope", scope), slog.Any("error", err))
+			continue
+		}
+
+		if strictest == nil || stricter(result, *strictest) {
+			strictest = &result
+		}
+	}
+
+	return strictest
+}
+
+// rateLimitMiddleware limits the requests of every key to the limit, with buckets scoped to the route.
+// The headers report the most restrictive of the buckets.
+func rateLimitMiddleware(limiter RateLimiter, limit RateLimit, scope string, keys ...rateLimitKey) gin.HandlerFunc {
+	if limit.disabled() {
+		return func(ctx *gin.Context) {
+			ctx.Next()
+		}
+	}
+
+	return func(ctx *gin.Context) {
+		var bucketKeys []string
+		for _, key := range keys {
+			if k, ok := key(ctx); ok {
+				bucketKeys = append(bucketKeys, k)
+			}
+		}
+
+		strictest := takeRateLimit(ctx, limiter, limit, scope, bucketKeys...)
+		if strictest == nil {
+			ctx.Next()
+			return
+		}
+
+		ctx.Header(rateLimitLimitHeader, strconv.Itoa(strictest.Limit))
+		ctx.Header(rateLimitRemainingHeader, strconv.Itoa(strictest.Remaining))
+		ctx.Header(rateLimitResetHeader, strconv.Itoa(ceilSeconds(strictest.ResetAfter)))
+
+		if !strictest.Allowed {
+			ctx.Header(retryAfterHeader, strconv.Itoa(ceilSeconds(strictest.RetryAfter)))
+			writeError(ctx, http.StatusTooManyRequests, errorCodeRateLimited, errRateLimited)
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// stricter reports whether result limits the request more than other
+func stricter(result RateLimitResult, other RateLimitResult) bool {
+	if result.Allowed != other.Allowed {
+		return !result.Allowed
+	}
+	if !result.Allowed {
+		return result.RetryAfter > other.RetryAfter
+	}
+	return result.Remaining < other.Remaining
+}
+
+// ceilSeconds rounds the duration up to whole seconds, as the headers expect
+func ceilSeconds(duration time.Duration) int {
+	return int(math.Ceil(duration.Seconds()))
+}
+
+// PruneRateLimits periodically forgets the rate limit buckets that are full again.
+// It blocks until the context is cancelled.
+func (server *Server) PruneRateLimits(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// a bucket untouched for a whole period is full, as if it never existed
+		before := time.Now().Add(-server.config.LoginRateLimitPeriod)
+		if err := server.rateLimiter.Prune(ctx, before); err != nil {
+			slog.ErrorContext(ctx, "cannot prune rate limits", slog.Any("error", err))
+		}
+	}
+}
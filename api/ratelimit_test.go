@@ -0,0 +1,281 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/niloy104/simplebank/db/mock"
+	db "github.com/niloy104/simplebank/db/sqlc"
+	"github.com/niloy104/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestMemoryRateLimiter(t *testing.T) {
+	limiter := NewMemoryRateLimiter()
+	limit := RateLimit{Burst: 3, Period: 3 * time.Second}
+	now := time.Now()
+
+	for i := 0; i < limit.Burst; i++ {
+		result, err := limiter.Allow(context.Background(), "key", limit, now)
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+		require.Equal(t, limit.Burst-i-1, result.Remaining)
+	}
+
+	result, err := limiter.Allow(context.Background(), "key", limit, now)
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+	require.Zero(t, result.Remaining)
+	require.Equal(t, time.Second, result.RetryAfter)
+	require.Equal(t, limit.Period, result.ResetAfter)
+
+	// other keys have their own bucket
+	result, err = limiter.Allow(context.Background(), "other", limit, now)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+
+	// a token is refilled every second
+	result, err = limiter.Allow(context.Background(), "key", limit, now.Add(time.Second))
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+
+	result, err = limiter.Allow(context.Background(), "key", limit, now.Add(time.Second))
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+
+	err = limiter.Prune(context.Background(), now.Add(time.Millisecond))
+	require.NoError(t, err)
+	require.Len(t, limiter.buckets, 1)
+	require.Contains(t, limiter.buckets, "key")
+}
+
+func TestDBRateLimiter(t *testing.T) {
+	limit := RateLimit{Burst: 10, Period: 10 * time.Second}
+	now := time.Now()
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, result RateLimitResult, err error)
+	}{
+		{
+			name: "Allowed",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					TakeRateLimitToken(gomock.Any(), gomock.Eq(db.TakeRateLimitTokenParams{
+						Key:        "key",
+						Burst:      10,
+						Now:        pgtype.Timestamptz{Time: now, Valid: true},
+						RefillRate: 1,
+					})).
+					Times(1).
+					Return(db.RateLimitBucket{Key: "key", Tokens: 7.5}, nil)
+				store.EXPECT().
+					GetRateLimitBucket(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, result RateLimitResult, err error) {
+				require.NoError(t, err)
+				require.True(t, result.Allowed)
+				require.Equal(t, 7, result.Remaining)
+				require.Equal(t, 2500*time.Millisecond, result.ResetAfter)
+			},
+		},
+		{
+			name: "Denied",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					TakeRateLimitToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RateLimitBucket{}, db.ErrRecordNotFound)
+				store.EXPECT().
+					GetRateLimitBucket(gomock.Any(), gomock.Eq("key")).
+					Times(1).
+					Return(db.RateLimitBucket{
+						Key:       "key",
+						Tokens:    0,
+						UpdatedAt: pgtype.Timestamptz{Time: now.Add(-250 * time.Millisecond), Valid: true},
+					}, nil)
+			},
+			checkResponse: func(t *testing.T, result RateLimitResult, err error) {
+				require.NoError(t, err)
+				require.False(t, result.Allowed)
+				require.Zero(t, result.Remaining)
+				require.Equal(t, 750*time.Millisecond, result.RetryAfter)
+			},
+		},
+		{
+			name: "StoreError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					TakeRateLimitToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RateLimitBucket{}, errInternal)
+			},
+			checkResponse: func(t *testing.T, result RateLimitResult, err error) {
+				require.ErrorIs(t, err, errInternal)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			limiter := NewDBRateLimiter(store)
+			result, err := limiter.Allow(context.Background(), "key", limit, now)
+			tc.checkResponse(t, result, err)
+		})
+	}
+}
+
+func TestLoginRateLimit(t *testing.T) {
+	user, password := randomUserWithPassword(t)
+	otherUser, _ := randomUserWithPassword(t)
+	otherUser.HashedPassword = user.HashedPassword
+
+	type login struct {
+		username     string
+		clientIP     string
+		forwardedFor string
+	}
+
+	testCases := []struct {
+		name           string
+		trustedProxies string
+		logins         []login
+	}{
+		{
+			name: "SameClientIP",
+			logins: []login{
+				{user.Username, "192.0.2.1", ""},
+				{otherUser.Username, "192.0.2.1", ""},
+				{user.Username, "192.0.2.1", ""},
+			},
+		},
+		{
+			name: "SameUsername",
+			logins: []login{
+				{user.Username, "192.0.2.1", ""},
+				{user.Username, "192.0.2.2", ""},
+				{user.Username, "192.0.2.3", ""},
+			},
+		},
+		{
+			// the client is not a trusted proxy, so a new X-Forwarded-For does not get it a new bucket
+			name: "SpoofedForwardedFor",
+			logins: []login{
+				{user.Username, "192.0.2.1", "198.51.100.1"},
+				{otherUser.Username, "192.0.2.1", "198.51.100.2"},
+				{user.Username, "192.0.2.1", "198.51.100.3"},
+			},
+		},
+		{
+			name:           "TrustedProxy",
+			trustedProxies: "192.0.2.0/24",
+			logins: []login{
+				{user.Username, "192.0.2.1", "198.51.100.1"},
+				{otherUser.Username, "192.0.2.2", "198.51.100.1"},
+				{user.Username, "192.0.2.1", "198.51.100.1"},
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().
+				GetUser(gomock.Any(), gomock.Any()).
+				Times(2).
+				DoAndReturn(func(_ context.Context, username string) (db.User, error) {
+					if username == otherUser.Username {
+						return otherUser, nil
+					}
+					return user, nil
+				})
+			store.EXPECT().
+				CreateSession(gomock.Any(), gomock.Any()).
+				AnyTimes().
+				Return(db.Session{}, nil)
+
+			server := newTestServer(t, store)
+			server.config.LoginRateLimit = 2
+			server.config.LoginRateLimitPeriod = time.Minute
+			server.config.TrustedProxies = tc.trustedProxies
+			require.NoError(t, server.setupRouter())
+
+			for i, login := range tc.logins {
+				data, err := json.Marshal(gin.H{
+					"username": login.username,
+					"password": password,
+				})
+				require.NoError(t, err)
+
+				request, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(data))
+				require.NoError(t, err)
+				request.RemoteAddr = login.clientIP + ":1234"
+				if login.forwardedFor != "" {
+					request.Header.Set("X-Forwarded-For", login.forwardedFor)
+				}
+
+				recorder := httptest.NewRecorder()
+				server.router.ServeHTTP(recorder, request)
+				require.Equal(t, "2", recorder.Header().Get(rateLimitLimitHeader))
+
+				if i < 2 {
+					require.Equal(t, http.StatusOK, recorder.Code)
+					require.Equal(t, strconv.Itoa(1-i), recorder.Header().Get(rateLimitRemainingHeader))
+					continue
+				}
+
+				require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+				requireErrorCode(t, recorder, errorCodeRateLimited)
+				require.Equal(t, "0", recorder.Header().Get(rateLimitRemainingHeader))
+				require.Equal(t, "30", recorder.Header().Get(retryAfterHeader))
+				require.Equal(t, "60", recorder.Header().Get(rateLimitResetHeader))
+			}
+		})
+	}
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	limiter, err := newRateLimiter(util.Config{}, nil)
+	require.NoError(t, err)
+	require.IsType(t, &MemoryRateLimiter{}, limiter)
+
+	limiter, err = newRateLimiter(util.Config{RateLimitStore: dbRateLimitStore}, nil)
+	require.NoError(t, err)
+	require.IsType(t, &DBRateLimiter{}, limiter)
+
+	_, err = newRateLimiter(util.Config{RateLimitStore: "redis"}, nil)
+	require.Error(t, err)
+}
+
+func TestTrustedProxies(t *testing.T) {
+	require.Nil(t, trustedProxies(util.Config{}))
+	require.Equal(t, []string{"10.0.0.1", "192.0.2.0/24"}, trustedProxies(util.Config{TrustedProxies: " 10.0.0.1, 192.0.2.0/24,"}))
+
+	server := newTestServer(t, nil)
+	server.config.TrustedProxies = "not-an-ip"
+	require.Error(t, server.setupRouter())
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	db "github.com/niloy104/simplebank/db/sqlc"
+	"github.com/niloy104/simplebank/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (rpc *rpcServer) CreateAccount(ctx context.Context, req *pb.CreateAccountRequest) (*pb.CreateAccountResponse, error) {
+	authPayload, err := rpc.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateRequest(createAccountRequest{Currency: req.GetCurrency()}); err != nil {
+		return nil, err
+	}
+
+	if err := rpc.authorize(authPayload, permissionCreateAccount, authPayload.Username); err != nil {
+		return nil, err
+	}
+
+	// Verify that the user exists before creating account
+	_, err = rpc.server.store.GetUser(ctx, authPayload.Username)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return nil, status.Error(codes.NotFound, "user does not exist")
+		}
+		return nil, rpcInternalError(ctx, err)
+	}
+
+	account, err := rpc.server.store.CreateAccount(ctx, db.CreateAccountParams{
+		Owner:    authPayload.Username,
+		Currency: req.GetCurrency(),
+		Balance:  0,
+	})
+	if err != nil {
+		return nil, rpcStoreError(ctx, err)
+	}
+
+	return &pb.CreateAccountResponse{Account: convertAccount(account)}, nil
+}
+
+func (rpc *rpcServer) GetAccount(ctx context.Context, req *pb.GetAccountRequest) (*pb.GetAccountResponse, error) {
+	authPayload, err := rpc.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateRequest(getAccountRequest{ID: req.GetId()}); err != nil {
+		return nil, err
+	}
+
+	account, err := rpc.server.store.GetAccount(ctx, req.GetId())
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return nil, status.Errorf(codes.NotFound, "account [%d] not found", req.GetId())
+		}
+		return nil, rpcInternalError(ctx, err)
+	}
+
+	if err := rpc.authorize(authPayload, permissionReadAccount, account.Owner); err != nil {
+		return nil, err
+	}
+
+	return &pb.GetAccountResponse{Account: convertAccount(account)}, nil
+}
+
+// ListAccounts walks the accounts of the owner with cursors.
+// The legacy offset pagination of the HTTP API is not offered over gRPC.
+func (rpc *rpcServer) ListAccounts(ctx context.Context, req *pb.ListAccountsRequest) (*pb.ListAccountsResponse, error) {
+	authPayload, err := rpc.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	listReq := listAccountRequest{
+		Owner: req.GetOwner(),
+		pageRequest: pageRequest{
+			PageSize: req.GetPageSize(),
+			Cursor:   req.GetCursor(),
+		},
+	}
+	if err := validateRequest(listReq); err != nil {
+		return nil, err
+	}
+
+	owner := listReq.Owner
+	if owner == "" {
+		owner = authPayload.Username
+	}
+	if err := rpc.authorize(authPayload, permissionListAccounts, owner); err != nil {
+		return nil, err
+	}
+
+	scope := "accounts:" + owner
+	page, err := listReq.query(rpc.server.cursors, scope)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	accounts, err := rpc.server.store.ListAccounts(ctx, db.ListAccountsParams{
+		Owner:          owner,
+		AfterCreatedAt: page.AfterCreatedAt,
+		AfterID:        page.AfterID,
+		Limit:          page.Limit,
+		Offset:         page.Offset,
+	})
+	if err != nil {
+		return nil, rpcInternalError(ctx, err)
+	}
+
+	accounts, nextCursor, err := nextPage(rpc.server.cursors, listReq.pageRequest, scope, accounts, func(account db.Account) (time.Time, int64) {
+		return account.CreatedAt.Time, account.ID
+	})
+	if err != nil {
+		return nil, rpcInternalError(ctx, err)
+	}
+
+	rsp := &pb.ListAccountsResponse{NextCursor: nextCursor}
+	for _, account := range accounts {
+		rsp.Accounts = append(rsp.Accounts, convertAccount(account))
+	}
+	return rsp, nil
+}
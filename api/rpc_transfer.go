@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	db "github.com/niloy104/simplebank/db/sqlc"
+	"github.com/niloy104/simplebank/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CreateTransfer moves money between two accounts of the same currency.
+// Cross-currency and idempotent transfers are only offered by the HTTP API.
+func (rpc *rpcServer) CreateTransfer(ctx context.Context, req *pb.CreateTransferRequest) (*pb.CreateTransferResponse, error) {
+	authPayload, err := rpc.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateRequest(transferRequest{
+		FromAccountID: req.GetFromAccountId(),
+		ToAccountID:   req.GetToAccountId(),
+		Amount:        req.GetAmount(),
+		Currency:      req.GetCurrency(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fromAccount, err := rpc.validAccount(ctx, req.GetFromAccountId(), req.GetCurrency())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rpc.authorize(authPayload, permissionTransferFrom, fromAccount.Owner); err != nil {
+		return nil, err
+	}
+
+	if _, err := rpc.validAccount(ctx, req.GetToAccountId(), req.GetCurrency()); err != nil {
+		return nil, err
+	}
+
+	result, err := rpc.server.store.TransferTx(ctx, db.TransferTxParams{
+		FromAccountID: req.GetFromAccountId(),
+		ToAccountID:   req.GetToAccountId(),
+		Amount:        req.GetAmount(),
+	})
+	if err != nil {
+		return nil, rpcStoreError(ctx, err)
+	}
+	rpc.server.metrics.moneyMoved(movementTransfer, req.GetCurrency(), result.Transfer.Amount)
+
+	return &pb.CreateTransferResponse{
+		Transfer:    convertTransfer(result.Transfer),
+		FromAccount: convertAccount(result.FromAccount),
+		ToAccount:   convertAccount(result.ToAccount),
+		FromEntry:   convertEntry(result.FromEntry),
+		ToEntry:     convertEntry(result.ToEntry),
+	}, nil
+}
+
+// validAccount applies the checks of the HTTP API to an account taking part in a transfer
+func (rpc *rpcServer) validAccount(ctx context.Context, accountID int64, currency string) (db.Account, error) {
+	account, err := rpc.server.store.GetAccount(ctx, accountID)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return account, status.Errorf(codes.NotFound, "account [%d] not found", accountID)
+		}
+		return account, rpcInternalError(ctx, err)
+	}
+
+	if account.Currency != currency {
+		return account, status.Errorf(codes.InvalidArgument, "account [%d] currency mismatch: %s vs %s", accountID, account.Currency, currency)
+	}
+
+	if account.IsSystem {
+		return account, status.Errorf(codes.FailedPrecondition, "account [%d] is a system account", accountID)
+	}
+
+	if account.IsFrozen {
+		return account, status.Errorf(codes.FailedPrecondition, "account [%d] is frozen", accountID)
+	}
+
+	return account, nil
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/niloy104/simplebank/db/sqlc"
+	"github.com/niloy104/simplebank/pb"
+	"github.com/niloy104/simplebank/token"
+	"github.com/niloy104/simplebank/util"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (rpc *rpcServer) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
+	err := validateRequest(createUserRequest{
+		Username: req.GetUsername(),
+		Password: req.GetPassword(),
+		FullName: req.GetFullName(),
+		Email:    req.GetEmail(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := util.HashPassword(req.GetPassword())
+	if err != nil {
+		return nil, rpcInternalError(ctx, err)
+	}
+
+	user, err := rpc.server.store.CreateUser(ctx, db.CreateUserParams{
+		Username:       req.GetUsername(),
+		HashedPassword: hashedPassword,
+		FullName:       req.GetFullName(),
+		Email:          req.GetEmail(),
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrUniqueViolation) {
+			return nil, status.Error(codes.AlreadyExists, "user already exists")
+		}
+		return nil, rpcInternalError(ctx, err)
+	}
+
+	return &pb.CreateUserResponse{User: convertUser(user)}, nil
+}
+
+func (rpc *rpcServer) LoginUser(ctx context.Context, req *pb.LoginUserRequest) (*pb.LoginUserResponse, error) {
+	if err := rpc.limitLogin(ctx, req.GetUsername()); err != nil {
+		return nil, err
+	}
+
+	err := validateRequest(loginUserRequest{
+		Username: req.GetUsername(),
+		Password: req.GetPassword(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := rpc.server.store.GetUser(ctx, req.GetUsername())
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		return nil, rpcInternalError(ctx, err)
+	}
+
+	err = rpc.server.checkLogin(ctx, user, req.GetPassword())
+	if err != nil {
+		var lockedErr *accountLockedError
+		switch {
+		case errors.As(err, &lockedErr):
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		case errors.Is(err, errIncorrectPassword):
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return nil, rpcInternalError(ctx, err)
+	}
+
+	sessionID, err := uuid.NewRandom()
+	if err != nil {
+		return nil, rpcInternalError(ctx, err)
+	}
+
+	config := rpc.server.config
+	accessToken, accessPayload, err := rpc.server.tokenMaker.CreateToken(user.Username, user.Role, sessionID, config.AccessTokenDuration, token.TokenTypeAccessToken)
+	if err != nil {
+		return nil, rpcInternalError(ctx, err)
+	}
+
+	refreshToken, refreshPayload, err := rpc.server.tokenMaker.CreateToken(user.Username, user.Role, sessionID, config.RefreshTokenDuration, token.TokenTypeRefreshToken)
+	if err != nil {
+		return nil, rpcInternalError(ctx, err)
+	}
+
+	userAgent, clientIP := clientMetadata(ctx)
+	session, err := rpc.server.store.CreateSession(ctx, db.CreateSessionParams{
+		ID:           sessionID,
+		Username:     user.Username,
+		RefreshToken: refreshToken,
+		UserAgent:    userAgent,
+		ClientIp:     clientIP,
+		IsBlocked:    false,
+		ExpiresAt:    pgtype.Timestamptz{Time: refreshPayload.ExpiredAt, Valid: true},
+	})
+	if err != nil {
+		return nil, rpcInternalError(ctx, err)
+	}
+
+	return &pb.LoginUserResponse{
+		SessionId:             session.ID.String(),
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  timestamppb.New(accessPayload.ExpiredAt),
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: timestamppb.New(refreshPayload.ExpiredAt),
+		User:                  convertUser(user),
+	}, nil
+}
+
+// limitLogin applies the login rate limits of the HTTP API, by client IP address and by username.
+// The buckets are shared with it, so moving to gRPC or the gateway gives no new attempts.
+func (rpc *rpcServer) limitLogin(ctx context.Context, username string) error {
+	_, clientIP := clientMetadata(ctx)
+	keys := []string{clientIPRateLimitKey(clientIP)}
+	if key, ok := usernameRateLimitKey(username); ok {
+		keys = append(keys, key)
+	}
+
+	result := takeRateLimit(ctx, rpc.server.rateLimiter, rpc.server.loginRateLimit(), loginRateLimitScope, keys...)
+	if result != nil && !result.Allowed {
+		return status.Error(codes.ResourceExhausted, errRateLimited.Error())
+	}
+
+	return nil
+}
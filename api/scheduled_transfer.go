@@ -0,0 +1,316 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/niloy104/simplebank/db/sqlc"
+	"github.com/niloy104/simplebank/token"
+	"github.com/niloy104/simplebank/util"
+)
+
+// Create a scheduled transfer.
+// It repeats on a cron expression or a fixed interval; without either it runs once at start_at.
+type createScheduledTransferRequest struct {
+	FromAccountID   int64      `json:"from_account_id" binding:"required,min=1"`
+	ToAccountID     int64      `json:"to_account_id" binding:"required,min=1,nefield=FromAccountID"`
+	Amount          int64      `json:"amount" binding:"required,gt=0"`
+	Currency        string     `json:"currency" binding:"required,currency"`
+	CronExpression  string     `json:"cron_expression,omitempty" binding:"omitempty,cron,excluded_with=IntervalSeconds"`
+	IntervalSeconds int64      `json:"interval_seconds,omitempty" binding:"omitempty,min=60"`
+	StartAt         *time.Time `json:"start_at,omitempty" binding:"required_without_all=CronExpression IntervalSeconds"`
+}
+
+func (server *Server) createScheduledTransfer(ctx *gin.Context) {
+	var req createScheduledTransferRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		writeBindError(ctx, err)
+		return
+	}
+
+	fromAccount, valid := server.validAccount(ctx, req.FromAccountID, req.Currency)
+	if !valid {
+		return
+	}
+
+	if !server.authorize(ctx, permissionTransferFrom, fromAccount.Owner) {
+		return
+	}
+
+	_, valid = server.validAccount(ctx, req.ToAccountID, req.Currency)
+	if !valid {
+		return
+	}
+
+	recurrence := util.Recurrence{
+		CronExpression: req.CronExpression,
+		Interval:       time.Duration(req.IntervalSeconds) * time.Second,
+	}
+
+	start := time.Now()
+	if req.StartAt != nil {
+		start = *req.StartAt
+	}
+
+	firstRunAt, err := recurrence.First(start)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, errorCodeInvalidRequest, err)
+		return
+	}
+
+	arg := db.CreateScheduledTransferParams{
+		Owner:           fromAccount.Owner,
+		FromAccountID:   req.FromAccountID,
+		ToAccountID:     req.ToAccountID,
+		Amount:          req.Amount,
+		CronExpression:  pgtype.Text{String: req.CronExpression, Valid: req.CronExpression != ""},
+		IntervalSeconds: pgtype.Int8{Int64: req.IntervalSeconds, Valid: req.IntervalSeconds > 0},
+		NextRunAt:       pgtype.Timestamptz{Time: firstRunAt, Valid: true},
+	}
+
+	scheduled, err := server.store.CreateScheduledTransfer(ctx, arg)
+	if err != nil {
+		writeInternalError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, scheduled)
+}
+
+// Get a scheduled transfer by ID
+type scheduledTransferRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+func (server *Server) getScheduledTransfer(ctx *gin.Context) {
+	scheduled, ok := server.bindScheduledTransfer(ctx, permissionReadScheduledTransfer)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, scheduled)
+}
+
+// List scheduled transfers of a user
+type listScheduledTransfersRequest struct {
+	Owner string `form:"owner" binding:"omitempty,alphanum"`
+	pageRequest
+}
+
+type listScheduledTransfersResponse struct {
+	ScheduledTransfers []db.ScheduledTransfer `json:"scheduled_transfers"`
+	NextCursor         string                 `json:"next_cursor,omitempty"`
+}
+
+func (server *Server) listScheduledTransfers(ctx *gin.Context) {
+	var req listScheduledTransfersRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		writeBindError(ctx, err)
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	owner := req.Owner
+	if owner == "" {
+		owner = authPayload.Username
+	}
+	if !server.authorize(ctx, permissionReadScheduledTransfer, owner) {
+		return
+	}
+
+	scope := "scheduled_transfers:" + owner
+	page, err := req.query(server.cursors, scope)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, errorCodeInvalidRequest, err)
+		return
+	}
+
+	scheduledTransfers, err := server.store.ListScheduledTransfers(ctx, db.ListScheduledTransfersParams{
+		Owner:          owner,
+		AfterCreatedAt: page.AfterCreatedAt,
+		AfterID:        page.AfterID,
+		Limit:          page.Limit,
+		Offset:         page.Offset,
+	})
+	if err != nil {
+		writeInternalError(ctx, err)
+		return
+	}
+
+	if req.isLegacy() {
+		ctx.JSON(http.StatusOK, scheduledTransfers)
+		return
+	}
+
+	scheduledTransfers, nextCursor, err := nextPage(server.cursors, req.pageRequest, scope, scheduledTransfers, func(scheduled db.ScheduledTransfer) (time.Time, int64) {
+		return scheduled.CreatedAt.Time, scheduled.ID
+	})
+	if err != nil {
+		writeInternalError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, listScheduledTransfersResponse{
+		ScheduledTransfers: scheduledTransfers,
+		NextCursor:         nextCursor,
+	})
+}
+
+// Change the amount of a scheduled transfer, or pause and resume it
+type updateScheduledTransferRequest struct {
+	Amount   *int64 `json:"amount" binding:"omitempty,gt=0"`
+	IsActive *bool  `json:"is_active"`
+}
+
+func (server *Server) updateScheduledTransfer(ctx *gin.Context) {
+	var req updateScheduledTransferRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		writeBindError(ctx, err)
+		return
+	}
+
+	scheduled, ok := server.bindScheduledTransfer(ctx, permissionManageScheduledTransfer)
+	if !ok {
+		return
+	}
+
+	arg := db.UpdateScheduledTransferParams{
+		ID: scheduled.ID,
+	}
+	if req.Amount != nil {
+		arg.Amount = pgtype.Int8{Int64: *req.Amount, Valid: true}
+	}
+	if req.IsActive != nil {
+		arg.IsActive = pgtype.Bool{Bool: *req.IsActive, Valid: true}
+	}
+
+	// runs missed while the transfer was paused are skipped when it resumes
+	now := time.Now()
+	resumed := req.IsActive != nil && *req.IsActive && !scheduled.IsActive
+	if resumed && scheduled.NextRunAt.Valid && scheduled.NextRunAt.Time.Before(now) {
+		nextRunAt, repeats, err := scheduled.Recurrence().Next(scheduled.NextRunAt.Time, now)
+		if err != nil {
+			writeInternalError(ctx, err)
+			return
+		}
+		if repeats {
+			arg.NextRunAt = pgtype.Timestamptz{Time: nextRunAt, Valid: true}
+		}
+	}
+
+	scheduled, err := server.store.UpdateScheduledTransfer(ctx, arg)
+	if err != nil {
+		writeInternalError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, scheduled)
+}
+
+// Delete a scheduled transfer together with its run history.
+// Transfers it already made are kept.
+func (server *Server) deleteScheduledTransfer(ctx *gin.Context) {
+	scheduled, ok := server.bindScheduledTransfer(ctx, permissionManageScheduledTransfer)
+	if !ok {
+		return
+	}
+
+	if err := server.store.DeleteScheduledTransfer(ctx, scheduled.ID); err != nil {
+		writeInternalError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// List the runs of a scheduled transfer
+type listScheduledTransferRunsRequest struct {
+	pageRequest
+}
+
+type listScheduledTransferRunsResponse struct {
+	Runs       []db.ScheduledTransferRun `json:"runs"`
+	NextCursor string                    `json:"next_cursor,omitempty"`
+}
+
+func (server *Server) listScheduledTransferRuns(ctx *gin.Context) {
+	var req listScheduledTransferRunsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		writeBindError(ctx, err)
+		return
+	}
+
+	scheduled, ok := server.bindScheduledTransfer(ctx, permissionReadScheduledTransfer)
+	if !ok {
+		return
+	}
+
+	scope := "scheduled_transfer_runs:" + strconv.FormatInt(scheduled.ID, 10)
+	page, err := req.query(server.cursors, scope)
+	if err != nil {
+		writeError(ctx, http.StatusBadRequest, errorCodeInvalidRequest, err)
+		return
+	}
+
+	runs, err := server.store.ListScheduledTransferRuns(ctx, db.ListScheduledTransferRunsParams{
+		ScheduledTransferID: scheduled.ID,
+		AfterCreatedAt:      page.AfterCreatedAt,
+		AfterID:             page.AfterID,
+		Limit:               page.Limit,
+		Offset:              page.Offset,
+	})
+	if err != nil {
+		writeInternalError(ctx, err)
+		return
+	}
+
+	if req.isLegacy() {
+		ctx.JSON(http.StatusOK, runs)
+		return
+	}
+
+	runs, nextCursor, err := nextPage(server.cursors, req.pageRequest, scope, runs, func(run db.ScheduledTransferRun) (time.Time, int64) {
+		return run.CreatedAt.Time, run.ID
+	})
+	if err != nil {
+		writeInternalError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, listScheduledTransferRunsResponse{
+		Runs:       runs,
+		NextCursor: nextCursor,
+	})
+}
+
+// bindScheduledTransfer loads the scheduled transfer of the request URI and checks the user has the permission on it.
+// It writes an error response and returns false if the request cannot go ahead.
+func (server *Server) bindScheduledTransfer(ctx *gin.Context, perm permission) (db.ScheduledTransfer, bool) {
+	var uri scheduledTransferRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		writeBindError(ctx, err)
+		return db.ScheduledTransfer{}, false
+	}
+
+	scheduled, err := server.store.GetScheduledTransfer(ctx, uri.ID)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			err = fmt.Errorf("scheduled transfer [%d] not found", uri.ID)
+			writeError(ctx, http.StatusNotFound, errorCodeNotFound, err)
+			return scheduled, false
+		}
+		writeInternalError(ctx, err)
+		return scheduled, false
+	}
+
+	if !server.authorize(ctx, perm, scheduled.Owner) {
+		return scheduled, false
+	}
+
+	return scheduled, true
+}
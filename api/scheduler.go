@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	db "github.com/niloy104/simplebank/db/sqlc"
+)
+
+// RunScheduledTransfers periodically executes the scheduled transfers that are due.
+// Every replica can run it: each due transfer is claimed by a single one of them.
+// It blocks until the context is cancelled.
+func (server *Server) RunScheduledTransfers(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		server.runDueScheduledTransfers(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDueScheduledTransfers runs due transfers one at a time until none is left.
+// Cancelling ctx stops it between runs: a run already started completes, so shutdown does not cut it off.
+// It returns how many runs were recorded.
+func (server *Server) runDueScheduledTransfers(ctx context.Context) int {
+	runs := 0
+	for ctx.Err() == nil {
+		result, err := server.store.RunScheduledTransferTx(context.WithoutCancel(ctx), time.Now())
+		if err != nil {
+			if !errors.Is(err, db.ErrRecordNotFound) {
+				slog.ErrorContext(ctx, "cannot run scheduled transfer", slog.Any("error", err))
+			}
+			return runs
+		}
+
+		runs++
+		server.metrics.scheduledTransferRuns.WithLabelValues(result.Run.Status).Inc()
+		if result.Run.Status == db.RunStatusSucceeded {
+			server.metrics.moneyMoved(movementTransfer, result.FromAccount.Currency, result.Transfer.Amount)
+		}
+		if result.Run.Status == db.RunStatusFailed {
+			slog.WarnContext(ctx, "scheduled transfer failed",
+				slog.Int64("scheduled_transfer_id", result.ScheduledTransfer.ID),
+				slog.String("error", result.Run.Error.String),
+			)
+		}
+	}
+	return runs
+}
+
+// ExpireHolds periodically releases the holds that expired without being captured.
+// Every replica can run it: each expired hold is released by a single one of them.
+// It blocks until the context is cancelled.
+func (server *Server) ExpireHolds(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		server.expireDueHolds(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expireDueHolds releases expired holds one at a time until none is left.
+// Like runDueScheduledTransfers, cancelling ctx stops it between holds.
+// It returns how many holds were expired.
+func (server *Server) expireDueHolds(ctx context.Context) int {
+	expired := 0
+	for ctx.Err() == nil {
+		_, err := server.store.ExpireHoldTx(context.WithoutCancel(ctx), time.Now())
+		if err != nil {
+			if !errors.Is(err, db.ErrRecordNotFound) {
+				slog.ErrorContext(ctx, "cannot expire hold", slog.Any("error", err))
+			}
+			return expired
+		}
+		expired++
+	}
+	return expired
+}
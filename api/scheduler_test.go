@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	mockdb "github.com/niloy104/simplebank/db/mock"
+	db "github.com/niloy104/simplebank/db/sqlc"
+	"github.com/niloy104/simplebank/util"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRunDueScheduledTransfers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	succeeded := db.RunScheduledTransferTxResult{
+		TransferTxResult: db.TransferTxResult{
+			Transfer:    db.Transfer{Amount: 25},
+			FromAccount: db.Account{Currency: util.USD},
+		},
+		Run: db.ScheduledTransferRun{Status: db.RunStatusSucceeded},
+	}
+
+	store := mockdb.NewMockStore(ctrl)
+	gomock.InOrder(
+		store.EXPECT().
+			RunScheduledTransferTx(gomock.Any(), gomock.Any()).
+			Return(succeeded, nil),
+		store.EXPECT().
+			RunScheduledTransferTx(gomock.Any(), gomock.Any()).
+			Return(db.RunScheduledTransferTxResult{Run: db.ScheduledTransferRun{Status: db.RunStatusFailed}}, nil),
+		store.EXPECT().
+			RunScheduledTransferTx(gomock.Any(), gomock.Any()).
+			Return(db.RunScheduledTransferTxResult{}, db.ErrRecordNotFound),
+	)
+
+	server := newTestServer(t, store)
+	require.Equal(t, 2, server.runDueScheduledTransfers(context.Background()))
+
+	// only the run that succeeded moved money
+	require.Equal(t, 1.0, testutil.ToFloat64(server.metrics.moneyMovements.WithLabelValues(movementTransfer, util.USD)))
+	require.Equal(t, 25.0, testutil.ToFloat64(server.metrics.moneyMovedAmount.WithLabelValues(movementTransfer, util.USD)))
+	require.Equal(t, 1, testutil.CollectAndCount(server.metrics.moneyMovements))
+}
+
+func TestRunDueScheduledTransfersStopsOnError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		RunScheduledTransferTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.RunScheduledTransferTxResult{}, sql.ErrConnDone)
+
+	server := newTestServer(t, store)
+	require.Zero(t, server.runDueScheduledTransfers(context.Background()))
+}
+
+func TestExpireDueHolds(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	gomock.InOrder(
+		store.EXPECT().
+			ExpireHoldTx(gomock.Any(), gomock.Any()).
+			Times(3).
+			Return(db.HoldTxResult{Hold: db.Hold{Status: db.HoldStatusExpired}}, nil),
+		store.EXPECT().
+			ExpireHoldTx(gomock.Any(), gomock.Any()).
+			Return(db.HoldTxResult{}, db.ErrRecordNotFound),
+	)
+
+	server := newTestServer(t, store)
+	require.Equal(t, 3, server.expireDueHolds(context.Background()))
+}
+
+func TestExpireDueHoldsStopsOnCancel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		ExpireHoldTx(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	server := newTestServer(t, store)
+	require.Zero(t, server.expireDueHolds(ctx))
+}
+
+func TestRunDueScheduledTransfersCompletesRunOnCancel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		RunScheduledTransferTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(runCtx context.Context, _ time.Time) (db.RunScheduledTransferTxResult, error) {
+			// shutdown starts while the run is in flight
+			cancel()
+			require.NoError(t, runCtx.Err())
+			return db.RunScheduledTransferTxResult{Run: db.ScheduledTransferRun{Status: db.RunStatusSucceeded}}, nil
+		})
+
+	server := newTestServer(t, store)
+	require.Equal(t, 1, server.runDueScheduledTransfers(ctx))
+}
@@ -1,7 +1,14 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
@@ -9,60 +16,294 @@ import (
 	db "github.com/niloy104/simplebank/db/sqlc"
 	"github.com/niloy104/simplebank/token"
 	"github.com/niloy104/simplebank/util"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc"
 )
 
+// tracer starts the spans of the handlers, with the globally registered provider
+var tracer = otel.Tracer("github.com/niloy104/simplebank/api")
+
 // Server serves HTTP requests for our banking service
 type Server struct {
-	config     util.Config
-	store      db.Store
-	tokenMaker token.Maker
-	router     *gin.Engine
+	config      util.Config
+	store       db.Store
+	tokenMaker  token.Maker
+	denylist    *token.Denylist
+	cursors     *cursorSigner
+	fxRates     FXRateProvider
+	rateLimiter RateLimiter
+	openAPI     []byte
+	health      *healthRegistry
+	metrics     *metrics
+	router      *gin.Engine
+
+	// the servers started so far, stopped by Shutdown
+	mu           sync.Mutex
+	httpServers  []*http.Server
+	grpcServers  []*grpc.Server
+	shuttingDown bool
 }
 
 // NewServer creates new HTTP server and setup routing
 func NewServer(config util.Config, store db.Store) (*Server, error) {
-	tokenMaker, err := token.NewPasetoMaker(config.TokenSymmetricKey)
+	tokenMaker, err := newTokenMaker(config)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create token maker: %w", err)
 	}
 
+	cursors, err := newCursorSigner(config.CursorSigningKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create cursor signer: %w", err)
+	}
+
+	fxRates, err := newFXRateProvider(config, store)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create fx rate provider: %w", err)
+	}
+
+	rateLimiter, err := newRateLimiter(config, store)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create rate limiter: %w", err)
+	}
+
+	openAPI, err := newOpenAPIDocument(apiRoutes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate openapi document: %w", err)
+	}
+
 	server := &Server{
-		config:     config,
-		store:      store,
-		tokenMaker: tokenMaker,
+		config:      config,
+		store:       store,
+		tokenMaker:  tokenMaker,
+		denylist:    token.NewDenylist(),
+		cursors:     cursors,
+		fxRates:     fxRates,
+		rateLimiter: rateLimiter,
+		openAPI:     openAPI,
+		health:      newHealthRegistry(),
+		metrics:     newMetrics(),
+	}
+	server.registerDefaultHealthCheckers()
+	if store != nil {
+		server.metrics.registry.MustRegister(newTxCollector(store))
 	}
 
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		v.RegisterValidation("currency", validCurrency)
+		v.RegisterValidation("cron", validCronExpression)
+		v.RegisterTagNameFunc(fieldTagName)
 	}
 
-	server.setupRouter()
+	if err := server.setupRouter(); err != nil {
+		return nil, fmt.Errorf("cannot set up router: %w", err)
+	}
 	return server, nil
 }
 
-func (server *Server) setupRouter() {
-	router := gin.Default()
+// newTokenMaker creates a PASETO v4.public maker when a private key is configured,
+// so other services can verify tokens with the published public key.
+// Otherwise it falls back to the symmetric v2.local maker, using the key ring
+// when several keys are configured for rotation.
+func newTokenMaker(config util.Config) (token.Maker, error) {
+	if config.TokenPrivateKey != "" {
+		privateKey, err := token.ParseEd25519PrivateKey(config.TokenPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		return token.NewPasetoPublicMaker(privateKey)
+	}
+
+	if config.TokenSymmetricKeys != "" {
+		keyRing, err := token.ParseKeyRing(config.TokenSymmetricKeys, config.TokenActiveKeyID)
+		if err != nil {
+			return nil, err
+		}
+		return token.NewPasetoKeyRingMaker(keyRing)
+	}
+
+	return token.NewPasetoMaker(config.TokenSymmetricKey)
+}
+
+func (server *Server) setupRouter() error {
+	router := gin.New()
+	// handlers pass the gin context to the store and the logger,
+	// so it must carry the span and the log attributes of the request
+	router.ContextWithFallback = true
+	// ClientIP reads X-Forwarded-For only from the trusted proxies,
+	// so clients cannot pick the address they are rate limited and logged by
+	if err := router.SetTrustedProxies(trustedProxies(server.config)); err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	router.Use(
+		otelgin.Middleware(util.ServiceName, otelgin.WithFilter(tracedRequest)),
+		requestIDMiddleware(),
+		loggerMiddleware(),
+		recoveryMiddleware(),
+		metricsMiddleware(server.metrics),
+	)
 
 	router.POST("/users", server.createUser)
-	router.POST("/users/login", server.loginUser)
+	router.POST("/users/login", rateLimitMiddleware(server.rateLimiter, server.loginRateLimit(), loginRateLimitScope, clientIPKey, loginUsernameKey), server.loginUser)
+	router.POST("/tokens/renew_access", server.renewAccessToken)
+	router.GET("/.well-known/jwks.json", server.listPublicKeys)
+	router.GET("/openapi.json", server.getOpenAPI)
+	router.GET("/docs/*filepath", server.getDocs)
+	router.GET("/healthz", server.getHealth)
+	router.GET("/readyz", server.getReadiness)
 
-	authRoutes := router.Group("/").Use(authMiddleware(server.tokenMaker))
+	authRoutes := router.Group("/").Use(authMiddleware(server.tokenMaker, server.denylist))
 	{
+		authRoutes.POST("/users/logout", server.logoutUser)
+		authRoutes.POST("/users/logout_all", server.logoutAllUser)
+
 		authRoutes.POST("/accounts", server.createAccount)
 		authRoutes.GET("/accounts/:id", server.getAccount)
 		authRoutes.GET("/accounts", server.listAccount)
+		authRoutes.POST("/accounts/:id/freeze", server.freezeAccount)
+		authRoutes.POST("/accounts/:id/unfreeze", server.unfreezeAccount)
+		authRoutes.POST("/accounts/:id/deposits", server.createDeposit)
+		authRoutes.POST("/accounts/:id/withdrawals", server.createWithdrawal)
+		authRoutes.GET("/accounts/:id/entries", server.listAccountEntries)
+		authRoutes.GET("/accounts/:id/transfers", server.listAccountTransfers)
 
 		authRoutes.POST("/transfers", server.createTransfer)
+		authRoutes.POST("/transfers/:id/reverse", server.reverseTransfer)
+		authRoutes.POST("/fx/quotes", server.createFXQuote)
+
+		authRoutes.POST("/scheduled_transfers", server.createScheduledTransfer)
+		authRoutes.GET("/scheduled_transfers", server.listScheduledTransfers)
+		authRoutes.GET("/scheduled_transfers/:id", server.getScheduledTransfer)
+		authRoutes.PATCH("/scheduled_transfers/:id", server.updateScheduledTransfer)
+		authRoutes.DELETE("/scheduled_transfers/:id", server.deleteScheduledTransfer)
+		authRoutes.GET("/scheduled_transfers/:id/runs", server.listScheduledTransferRuns)
 	}
 
 	server.router = router
+	return nil
 }
 
-// Start runs the HTTP sever and on specific adress
+// trustedProxies returns the IP addresses and CIDR ranges of the proxies in front of the server,
+// or nil when clients connect directly
+func trustedProxies(config util.Config) []string {
+	var proxies []string
+	for _, proxy := range strings.Split(config.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// tracedRequest leaves out the probes and scrapes polling the server, so they do not flood the traces
+func tracedRequest(request *http.Request) bool {
+	switch request.URL.Path {
+	case "/healthz", "/readyz":
+		return false
+	}
+	return true
+}
+
+// Start runs the HTTP sever and on specific adress.
+// It returns http.ErrServerClosed once Shutdown is called.
 func (server *Server) Start(address string) error {
-	return server.router.Run(address)
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("cannot create listener: %w", err)
+	}
+
+	return server.Serve(listener)
+}
+
+// Serve runs the HTTP server on the listener until Shutdown is called
+func (server *Server) Serve(listener net.Listener) error {
+	return server.serveHTTP(listener, server.router)
+}
+
+// newHTTPServer creates an HTTP server with the timeouts and limits of the config,
+// so slow or oversized requests cannot hold connections forever
+func (server *Server) newHTTPServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: server.config.HTTPReadTimeout,
+		ReadTimeout:       server.config.HTTPReadTimeout,
+		WriteTimeout:      server.config.HTTPWriteTimeout,
+		IdleTimeout:       server.config.HTTPIdleTimeout,
+		MaxHeaderBytes:    server.config.HTTPMaxHeaderBytes,
+	}
+}
+
+func (server *Server) serveHTTP(listener net.Listener, handler http.Handler) error {
+	httpServer := server.newHTTPServer(handler)
+
+	server.mu.Lock()
+	if server.shuttingDown {
+		server.mu.Unlock()
+		listener.Close()
+		return http.ErrServerClosed
+	}
+	server.httpServers = append(server.httpServers, httpServer)
+	server.mu.Unlock()
+
+	return httpServer.Serve(listener)
 }
 
-func errorResponse(err error) gin.H {
-	return gin.H{"error": err.Error()}
+// Shutdown stops the HTTP, gateway and gRPC servers from accepting connections
+// and waits for their in-flight requests to complete.
+// /readyz fails from the start, and the servers keep serving for the drain delay of the config
+// so load balancers can notice and stop sending requests first.
+// When ctx is done first, the remaining connections are closed and ctx.Err() is returned.
+func (server *Server) Shutdown(ctx context.Context) error {
+	server.mu.Lock()
+	server.shuttingDown = true
+	server.mu.Unlock()
+
+	if server.config.ShutdownDrainDelay > 0 {
+		drain := time.NewTimer(server.config.ShutdownDrainDelay)
+		select {
+		case <-drain.C:
+		case <-ctx.Done():
+			drain.Stop()
+		}
+	}
+
+	server.mu.Lock()
+	httpServers := server.httpServers
+	grpcServers := server.grpcServers
+	server.mu.Unlock()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(httpServers)+len(grpcServers))
+	for i, httpServer := range httpServers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := httpServer.Shutdown(ctx); err != nil {
+				httpServer.Close()
+				errs[i] = err
+			}
+		}()
+	}
+
+	for i, grpcServer := range grpcServers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stopped := make(chan struct{})
+			go func() {
+				grpcServer.GracefulStop()
+				close(stopped)
+			}()
+
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				grpcServer.Stop()
+				errs[len(httpServers)+i] = ctx.Err()
+			}
+		}()
+	}
+
+	wg.Wait()
+	return errors.Join(errs...)
 }
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	mockdb "github.com/niloy104/simplebank/db/mock"
+	db "github.com/niloy104/simplebank/db/sqlc"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/mock/gomock"
+)
+
+// serveTestServer runs the HTTP server on a free port and returns its base URL
+// and the result of Serve
+func serveTestServer(t *testing.T, server *Server) (string, <-chan error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	return "http://" + listener.Addr().String(), serveErr
+}
+
+func TestShutdownCompletesInFlightRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := newTestServer(t, mockdb.NewMockStore(ctrl))
+
+	started := make(chan struct{})
+	server.router.GET("/slow", func(ctx *gin.Context) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		ctx.String(http.StatusOK, "done")
+	})
+
+	baseURL, serveErr := serveTestServer(t, server)
+
+	type response struct {
+		status int
+		body   string
+		err    error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		rsp, err := http.Get(baseURL + "/slow")
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		defer rsp.Body.Close()
+
+		body, err := io.ReadAll(rsp.Body)
+		responses <- response{status: rsp.StatusCode, body: string(body), err: err}
+	}()
+
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Shutdown returns only once the slow request completed
+	err := server.Shutdown(ctx)
+	require.NoError(t, err)
+
+	select {
+	case rsp := <-responses:
+		require.NoError(t, rsp.err)
+		require.Equal(t, http.StatusOK, rsp.status)
+		require.Equal(t, "done", rsp.body)
+	default:
+		t.Fatal("shutdown returned before the in-flight request completed")
+	}
+
+	require.ErrorIs(t, <-serveErr, http.ErrServerClosed)
+
+	// new connections are refused
+	_, err = http.Get(baseURL + "/slow")
+	require.Error(t, err)
+
+	// servers started after the shutdown stop at once
+	_, serveErr = serveTestServer(t, server)
+	require.ErrorIs(t, <-serveErr, http.ErrServerClosed)
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := newTestServer(t, mockdb.NewMockStore(ctrl))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	server.router.GET("/stuck", func(ctx *gin.Context) {
+		close(started)
+		<-release
+	})
+
+	baseURL, serveErr := serveTestServer(t, server)
+	go http.Get(baseURL + "/stuck")
+
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := server.Shutdown(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorIs(t, <-serveErr, http.ErrServerClosed)
+}
+
+func TestNewHTTPServer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := newTestServer(t, mockdb.NewMockStore(ctrl))
+	server.config.HTTPReadTimeout = 5 * time.Second
+	server.config.HTTPWriteTimeout = 10 * time.Second
+	server.config.HTTPIdleTimeout = time.Minute
+	server.config.HTTPMaxHeaderBytes = 8192
+
+	httpServer := server.newHTTPServer(server.router)
+	require.Equal(t, 5*time.Second, httpServer.ReadHeaderTimeout)
+	require.Equal(t, 5*time.Second, httpServer.ReadTimeout)
+	require.Equal(t, 10*time.Second, httpServer.WriteTimeout)
+	require.Equal(t, time.Minute, httpServer.IdleTimeout)
+	require.Equal(t, 8192, httpServer.MaxHeaderBytes)
+}
+
+func TestRequestTracing(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	defaultProvider, defaultPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(defaultProvider)
+		otel.SetTextMapPropagator(defaultPropagator)
+	}()
+
+	// the trace context sent by the caller
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+	callerSpanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	require.NoError(t, err)
+
+	user, _ := randomUser(t)
+	account1 := randomAccount(user.Username)
+	account2 := randomAccount("other")
+	account2.Currency = account1.Currency
+
+	requireTraced := func(ctx context.Context) {
+		require.Equal(t, traceID, trace.SpanContextFromContext(ctx).TraceID())
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	for _, account := range []db.Account{account1, account2} {
+		store.EXPECT().
+			GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+			Times(1).
+			DoAndReturn(func(ctx context.Context, _ int64) (db.Account, error) {
+				requireTraced(ctx)
+				return account, nil
+			})
+	}
+	store.EXPECT().
+		TransferTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(ctx context.Context, _ db.TransferTxParams) (db.TransferTxResult, error) {
+			requireTraced(ctx)
+			return db.TransferTxResult{}, nil
+		})
+
+	server := newTestServer(t, store)
+
+	data, err := json.Marshal(gin.H{
+		"from_account_id": account1.ID,
+		"to_account_id":   account2.ID,
+		"amount":          10,
+		"currency":        account1.Currency,
+	})
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPost, "/transfers", bytes.NewReader(data))
+	require.NoError(t, err)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	addAuhorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, user.Role, time.Minute)
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusCreated, recorder.Code)
+
+	ended := spans.Ended()
+	require.Len(t, ended, 3)
+
+	// the request span continues the trace of the caller, and the handler steps are its children
+	requestSpan := ended[len(ended)-1]
+	require.Equal(t, "POST /transfers", requestSpan.Name())
+	require.Equal(t, traceID, requestSpan.SpanContext().TraceID())
+	require.Equal(t, callerSpanID, requestSpan.Parent().SpanID())
+
+	for _, span := range ended[:len(ended)-1] {
+		require.Equal(t, "validAccount", span.Name())
+		require.Equal(t, requestSpan.SpanContext().SpanID(), span.Parent().SpanID())
+	}
+
+	// probes are not traced
+	request, err = http.NewRequest(http.MethodGet, "/healthz", nil)
+	require.NoError(t, err)
+	server.router.ServeHTTP(httptest.NewRecorder(), request)
+	require.Len(t, spans.Ended(), 3)
+}
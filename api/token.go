@@ -0,0 +1,130 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/niloy104/simplebank/db/sqlc"
+	"github.com/niloy104/simplebank/token"
+	"github.com/niloy104/simplebank/util"
+)
+
+// Renew access token
+type renewAccessTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogValue keeps the refresh token out of the logs
+func (req renewAccessTokenRequest) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("refresh_token", util.Redacted))
+}
+
+type renewAccessTokenResponse struct {
+	AccessToken          string    `json:"access_token"`
+	AccessTokenExpiresAt time.Time `json:"access_token_expires_at"`
+}
+
+func (server *Server) renewAccessToken(ctx *gin.Context) {
+	var req renewAccessTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		writeBindError(ctx, err)
+		return
+	}
+
+	refreshPayload, err := server.tokenMaker.VerifyToken(req.RefreshToken, token.TokenTypeRefreshToken)
+	if err != nil {
+		writeError(ctx, http.StatusUnauthorized, errorCodeUnauthenticated, err)
+		return
+	}
+
+	session, err := server.store.GetSession(ctx, refreshPayload.SessionID)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			err := fmt.Errorf("session not found")
+			writeError(ctx, http.StatusUnauthorized, errorCodeUnauthenticated, err)
+			return
+		}
+		writeInternalError(ctx, err)
+		return
+	}
+
+	if session.IsBlocked {
+		err := fmt.Errorf("blocked session")
+		writeError(ctx, http.StatusUnauthorized, errorCodeUnauthenticated, err)
+		return
+	}
+
+	if session.Username != refreshPayload.Username {
+		err := fmt.Errorf("incorrect session user")
+		writeError(ctx, http.StatusUnauthorized, errorCodeUnauthenticated, err)
+		return
+	}
+
+	if session.RefreshToken != req.RefreshToken {
+		err := fmt.Errorf("mismatched session token")
+		writeError(ctx, http.StatusUnauthorized, errorCodeUnauthenticated, err)
+		return
+	}
+
+	if time.Now().After(session.ExpiresAt.Time) {
+		err := fmt.Errorf("expired session")
+		writeError(ctx, http.StatusUnauthorized, errorCodeUnauthenticated, err)
+		return
+	}
+
+	// the role comes from the user rather than the refresh token, so a role change applies on the next renewal
+	user, err := server.store.GetUser(ctx, session.Username)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			err := fmt.Errorf("user not found")
+			writeError(ctx, http.StatusUnauthorized, errorCodeUnauthenticated, err)
+			return
+		}
+		writeInternalError(ctx, err)
+		return
+	}
+
+	accessToken, accessPayload, err := server.tokenMaker.CreateToken(
+		user.Username,
+		user.Role,
+		session.ID,
+		server.config.AccessTokenDuration,
+		token.TokenTypeAccessToken,
+	)
+	if err != nil {
+		writeInternalError(ctx, err)
+		return
+	}
+
+	rsp := renewAccessTokenResponse{
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: accessPayload.ExpiredAt,
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// List public keys for token verification
+type listPublicKeysResponse struct {
+	Keys []token.JSONWebKey `json:"keys"`
+}
+
+func (server *Server) listPublicKeys(ctx *gin.Context) {
+	provider, ok := server.tokenMaker.(token.PublicKeyProvider)
+	if !ok {
+		err := errors.New("tokens are not signed with a public key")
+		writeError(ctx, http.StatusNotFound, errorCodeNotFound, err)
+		return
+	}
+
+	rsp := listPublicKeysResponse{Keys: []token.JSONWebKey{}}
+	for _, key := range provider.PublicKeys() {
+		rsp.Keys = append(rsp.Keys, key.JWK())
+	}
+
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, rsp)
+}
@@ -1,13 +1,17 @@
 package api
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	db "github.com/niloy104/simplebank/db/sqlc"
 	"github.com/niloy104/simplebank/token"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type transferRequest struct {
@@ -15,12 +19,19 @@ type transferRequest struct {
 	ToAccountID   int64  `json:"to_account_id" binding:"required,min=1"`
 	Amount        int64  `json:"amount" binding:"required,gt=0"`
 	Currency      string `json:"currency" binding:"required,currency"`
+	QuoteID       string `json:"quote_id,omitempty" binding:"omitempty,uuid"`
 }
 
 func (server *Server) createTransfer(ctx *gin.Context) {
 	var req transferRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		writeBindError(ctx, err)
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	idempotency, ok := server.checkIdempotencyKey(ctx, authPayload.Username, req)
+	if !ok {
 		return
 	}
 
@@ -29,14 +40,22 @@ func (server *Server) createTransfer(ctx *gin.Context) {
 		return
 	}
 
-	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
-	if fromAaccount.Owner != authPayload.Username {
-		err := fmt.Errorf("account [%d] doesn't belong to the authenticated user", fromAaccount.ID)
-		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+	if !server.authorize(ctx, permissionTransferFrom, fromAaccount.Owner) {
 		return
 	}
 
-	_, valid = server.validAccount(ctx, req.ToAccountID, req.Currency)
+	// a cross-currency transfer credits the destination account in the currency of the quote
+	toCurrency := req.Currency
+	var quote db.FxQuote
+	if req.QuoteID != "" {
+		quote, valid = server.validFXQuote(ctx, uuid.MustParse(req.QuoteID), authPayload.Username, req.Currency)
+		if !valid {
+			return
+		}
+		toCurrency = quote.ToCurrency
+	}
+
+	_, valid = server.validAccount(ctx, req.ToAccountID, toCurrency)
 	if !valid {
 		return
 	}
@@ -47,31 +66,164 @@ func (server *Server) createTransfer(ctx *gin.Context) {
 		Amount:        req.Amount,
 	}
 
-	result, err := server.store.TransferTx(ctx, arg)
+	fxArg := db.FXTransferTxParams{
+		FromAccountID: req.FromAccountID,
+		ToAccountID:   req.ToAccountID,
+		Amount:        req.Amount,
+		QuoteID:       quote.ID,
+		Username:      authPayload.Username,
+	}
+
+	var result db.TransferTxResult
+	var replayed bool
+	var err error
+	switch {
+	case req.QuoteID != "" && idempotency.Key != "":
+		result, replayed, err = server.store.IdempotentFXTransferTx(ctx, db.IdempotentFXTransferTxParams{
+			FXTransferTxParams: fxArg,
+			Idempotency:        idempotency,
+		})
+	case req.QuoteID != "":
+		result, err = server.store.FXTransferTx(ctx, fxArg)
+	case idempotency.Key != "":
+		result, replayed, err = server.store.IdempotentTransferTx(ctx, db.IdempotentTransferTxParams{
+			TransferTxParams: arg,
+			Idempotency:      idempotency,
+		})
+	default:
+		result, err = server.store.TransferTx(ctx, arg)
+	}
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		writeStoreError(ctx, err)
 		return
 	}
 
+	if replayed {
+		ctx.Header(idempotentReplayedHeader, "true")
+	} else if req.QuoteID != "" {
+		server.metrics.moneyMoved(movementFXTransfer, req.Currency, result.Transfer.Amount)
+	} else {
+		server.metrics.moneyMoved(movementTransfer, req.Currency, result.Transfer.Amount)
+	}
 	ctx.JSON(http.StatusCreated, result)
 
 }
 
+// Reverse a transfer, fully or partially
+type reverseTransferURI struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type reverseTransferRequest struct {
+	Amount int64 `json:"amount" binding:"omitempty,gt=0"`
+}
+
+func (server *Server) reverseTransfer(ctx *gin.Context) {
+	var uri reverseTransferURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		writeBindError(ctx, err)
+		return
+	}
+
+	// the body is optional: without an amount, whatever is left of the transfer is reversed
+	var req reverseTransferRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			writeBindError(ctx, err)
+			return
+		}
+	}
+
+	transfer, err := server.store.GetTransfer(ctx, uri.ID)
+	if err != nil {
+		writeStoreError(ctx, err)
+		return
+	}
+
+	fromAccount, err := server.store.GetAccount(ctx, transfer.FromAccountID.Int64)
+	if err != nil {
+		writeInternalError(ctx, err)
+		return
+	}
+
+	if !server.authorize(ctx, permissionReverseTransfer, fromAccount.Owner) {
+		return
+	}
+
+	result, err := server.store.ReverseTransferTx(ctx, db.ReverseTransferTxParams{
+		TransferID: transfer.ID,
+		Amount:     req.Amount,
+	})
+	if err != nil {
+		writeStoreError(ctx, err)
+		return
+	}
+
+	// the reversal moves money back from the recipient, in the currency of its account
+	server.metrics.moneyMoved(movementReversal, result.FromAccount.Currency, result.Transfer.Amount)
+	ctx.JSON(http.StatusCreated, result)
+}
+
+// validFXQuote checks the quote belongs to the user, converts from the currency of the transfer
+// and can still be used. The store checks it again when the transfer consumes the quote.
+func (server *Server) validFXQuote(ctx *gin.Context, quoteID uuid.UUID, username string, currency string) (db.FxQuote, bool) {
+	quote, err := server.store.GetFXQuote(ctx, quoteID)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			writeError(ctx, http.StatusUnprocessableEntity, errorCodeInvalidFXQuote, db.ErrInvalidFXQuote)
+			return quote, false
+		}
+
+		writeInternalError(ctx, err)
+		return quote, false
+	}
+
+	if quote.Username != username || quote.FromCurrency != currency || quote.UsedAt.Valid || !quote.ExpiresAt.Time.After(time.Now()) {
+		writeError(ctx, http.StatusUnprocessableEntity, errorCodeInvalidFXQuote, db.ErrInvalidFXQuote)
+		return quote, false
+	}
+
+	return quote, true
+}
+
 func (server *Server) validAccount(ctx *gin.Context, accountID int64, currency string) (db.Account, bool) {
-	account, err := server.store.GetAccount(ctx, accountID)
+	spanCtx, span := tracer.Start(ctx, "validAccount", trace.WithAttributes(attribute.Int64("account.id", accountID)))
+	defer span.End()
+
+	account, err := server.store.GetAccount(spanCtx, accountID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+		if errors.Is(err, db.ErrRecordNotFound) {
+			err = fmt.Errorf("account [%d] not found", accountID)
+			writeError(ctx, http.StatusNotFound, errorCodeNotFound, err)
 			return account, false
 		}
 
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		writeInternalError(ctx, err)
 		return account, false
 
 	}
 	if account.Currency != currency {
-		err := fmt.Errorf("account [%d] currency mismatch: %s vs %s", accountID, account.Currency, currency)
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		writeAPIError(ctx, http.StatusBadRequest, apiError{
+			Code:    errorCodeCurrencyMismatch,
+			Message: fmt.Sprintf("account [%d] currency mismatch: %s vs %s", accountID, account.Currency, currency),
+			Details: map[string]any{
+				"account_id":       accountID,
+				"account_currency": account.Currency,
+				"currency":         currency,
+			},
+		})
+		return account, false
+	}
+
+	if account.IsSystem {
+		err := fmt.Errorf("account [%d] is a system account", accountID)
+		writeError(ctx, http.StatusForbidden, errorCodeSystemAccount, err)
+		return account, false
+	}
+
+	if account.IsFrozen {
+		err := fmt.Errorf("account [%d] is frozen", accountID)
+		writeError(ctx, http.StatusForbidden, errorCodeAccountFrozen, err)
 		return account, false
 	}
 
@@ -1,13 +1,20 @@
 package api
 
 import (
-	"database/sql"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
-	"github.com/lib/pq"
 	db "github.com/niloy104/simplebank/db/sqlc"
+	"github.com/niloy104/simplebank/token"
 	"github.com/niloy104/simplebank/util"
 )
 
@@ -19,8 +26,19 @@ type createUserRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 }
 
+// LogValue keeps the password out of the logs
+func (req createUserRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("username", req.Username),
+		slog.String("password", util.Redacted),
+		slog.String("full_name", req.FullName),
+		slog.String("email", req.Email),
+	)
+}
+
 type userResponse struct {
 	Username          string             `json:"username"`
+	Role              string             `json:"role"`
 	FullName          string             `json:"full_name"`
 	Email             string             `json:"email"`
 	PasswordChangedAt pgtype.Timestamptz `json:"password_changed_at"`
@@ -30,6 +48,7 @@ type userResponse struct {
 func newUserResponse(user db.User) userResponse {
 	return userResponse{
 		Username:          user.Username,
+		Role:              user.Role,
 		FullName:          user.FullName,
 		Email:             user.Email,
 		PasswordChangedAt: user.PasswordChangedAt,
@@ -40,13 +59,13 @@ func newUserResponse(user db.User) userResponse {
 func (server *Server) createUser(ctx *gin.Context) {
 	var req createUserRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		writeBindError(ctx, err)
 		return
 	}
 
 	hashedPassword, err := util.HashPassword(req.Password)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		writeInternalError(ctx, err)
 		return
 	}
 
@@ -59,14 +78,7 @@ func (server *Server) createUser(ctx *gin.Context) {
 
 	user, err := server.store.CreateUser(ctx, arg)
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			switch pqErr.Code.Name() {
-			case "unique_violation":
-				ctx.JSON(http.StatusForbidden, errorResponse(err))
-				return
-			}
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		writeStoreError(ctx, err)
 		return
 	}
 
@@ -81,46 +93,239 @@ type loginUserRequest struct {
 	Password string `json:"password" binding:"required,min=6"`
 }
 
+// LogValue keeps the password out of the logs
+func (req loginUserRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("username", req.Username),
+		slog.String("password", util.Redacted),
+	)
+}
+
 type loginUserResponse struct {
-	AccessToken string       `json:"access_token"`
-	User        userResponse `json:"user"`
+	SessionID             uuid.UUID    `json:"session_id"`
+	AccessToken           string       `json:"access_token"`
+	AccessTokenExpiresAt  time.Time    `json:"access_token_expires_at"`
+	RefreshToken          string       `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time    `json:"refresh_token_expires_at"`
+	User                  userResponse `json:"user"`
 }
 
 func (server *Server) loginUser(ctx *gin.Context) {
 	var req loginUserRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+	// the rate limiter may have read the body already
+	if err := ctx.ShouldBindBodyWith(&req, binding.JSON); err != nil {
+		writeBindError(ctx, err)
 		return
 	}
 
 	user, err := server.store.GetUser(ctx, req.Username)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		if errors.Is(err, db.ErrRecordNotFound) {
+			writeError(ctx, http.StatusNotFound, errorCodeNotFound, errors.New("user not found"))
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		writeInternalError(ctx, err)
+		return
+	}
+
+	err = server.checkLogin(ctx, user, req.Password)
+	if err != nil {
+		var lockedErr *accountLockedError
+		switch {
+		case errors.As(err, &lockedErr):
+			ctx.Header(retryAfterHeader, strconv.Itoa(ceilSeconds(time.Until(lockedErr.until))))
+			writeAPIError(ctx, http.StatusLocked, apiError{
+				Code:    errorCodeAccountLocked,
+				Message: err.Error(),
+				Details: map[string]any{"locked_until": lockedErr.until},
+			})
+		case errors.Is(err, errIncorrectPassword):
+			writeError(ctx, http.StatusUnauthorized, errorCodeUnauthenticated, err)
+		default:
+			writeInternalError(ctx, err)
+		}
 		return
 	}
 
-	err = util.CheckPassword(req.Password, user.HashedPassword)
+	// every token of the session carries its ID, so logging out the session revokes them all
+	sessionID, err := uuid.NewRandom()
 	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		writeInternalError(ctx, err)
 		return
 	}
-	accessToken, err := server.tokenMaker.CreateToken(
+
+	accessToken, accessPayload, err := server.tokenMaker.CreateToken(
 		user.Username,
+		user.Role,
+		sessionID,
 		server.config.AccessTokenDuration,
+		token.TokenTypeAccessToken,
+	)
+	if err != nil {
+		writeInternalError(ctx, err)
+		return
+	}
+
+	refreshToken, refreshPayload, err := server.tokenMaker.CreateToken(
+		user.Username,
+		user.Role,
+		sessionID,
+		server.config.RefreshTokenDuration,
+		token.TokenTypeRefreshToken,
 	)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		writeInternalError(ctx, err)
+		return
+	}
+
+	session, err := server.store.CreateSession(ctx, db.CreateSessionParams{
+		ID:           sessionID,
+		Username:     user.Username,
+		RefreshToken: refreshToken,
+		UserAgent:    ctx.Request.UserAgent(),
+		ClientIp:     ctx.ClientIP(),
+		IsBlocked:    false,
+		ExpiresAt:    pgtype.Timestamptz{Time: refreshPayload.ExpiredAt, Valid: true},
+	})
+	if err != nil {
+		writeInternalError(ctx, err)
 		return
 	}
 
 	rsp := loginUserResponse{
-		AccessToken: accessToken,
-		User:        newUserResponse(user),
+		SessionID:             session.ID,
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessPayload.ExpiredAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshPayload.ExpiredAt,
+		User:                  newUserResponse(user),
 	}
 
 	ctx.JSON(http.StatusOK, rsp)
 }
+
+var errIncorrectPassword = errors.New("incorrect password")
+
+// accountLockedError refuses the logins of a user locked out after too many failed attempts
+type accountLockedError struct {
+	until time.Time
+}
+
+func (err *accountLockedError) Error() string {
+	return "too many failed logins, the account is locked until " + err.until.Format(time.RFC3339)
+}
+
+// checkLogin checks the password of the user.
+// When lockout is enabled, MaxFailedLogins failed attempts in a row lock the user out for LoginLockoutDuration,
+// and a successful login forgets the failed attempts.
+func (server *Server) checkLogin(ctx context.Context, user db.User, password string) error {
+	lockout := server.config.MaxFailedLogins > 0
+	now := time.Now()
+
+	if lockout && user.LockedUntil.Time.After(now) {
+		return &accountLockedError{until: user.LockedUntil.Time}
+	}
+
+	if err := util.CheckPassword(password, user.HashedPassword); err != nil {
+		if lockout {
+			_, err := server.store.RecordFailedLogin(ctx, db.RecordFailedLoginParams{
+				MaxAttempts: server.config.MaxFailedLogins,
+				LockedUntil: pgtype.Timestamptz{Time: now.Add(server.config.LoginLockoutDuration), Valid: true},
+				Username:    user.Username,
+			})
+			if err != nil {
+				return fmt.Errorf("cannot record failed login: %w", err)
+			}
+		}
+		return errIncorrectPassword
+	}
+
+	if lockout && user.FailedLoginAttempts > 0 {
+		if err := server.store.ResetFailedLogins(ctx, user.Username); err != nil {
+			return fmt.Errorf("cannot reset failed logins: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Logout
+type logoutUserRequest struct {
+	SessionID string `json:"session_id" binding:"required,uuid"`
+}
+
+func (server *Server) logoutUser(ctx *gin.Context) {
+	var req logoutUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		writeBindError(ctx, err)
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	session, err := server.store.GetSession(ctx, uuid.MustParse(req.SessionID))
+	if err != nil {
+		writeStoreError(ctx, err)
+		return
+	}
+
+	if !server.authorize(ctx, permissionBlockSession, session.Username) {
+		return
+	}
+
+	err = server.store.BlockSession(ctx, session.ID)
+	if err != nil {
+		writeInternalError(ctx, err)
+		return
+	}
+
+	// revoking the session ID revokes every token issued for the session, on whichever device holds it
+	revoked := []db.RevokeTokenParams{
+		{
+			ID:        session.ID,
+			Username:  session.Username,
+			ExpiresAt: session.ExpiresAt,
+		},
+	}
+	if authPayload.SessionID == session.ID {
+		revoked = append(revoked, db.RevokeTokenParams{
+			ID:        authPayload.ID,
+			Username:  authPayload.Username,
+			ExpiresAt: pgtype.Timestamptz{Time: authPayload.ExpiredAt, Valid: true},
+		})
+	}
+	for _, arg := range revoked {
+		err = server.store.RevokeToken(ctx, arg)
+		if err != nil {
+			writeInternalError(ctx, err)
+			return
+		}
+		server.denylist.Revoke(arg.ID, arg.ExpiresAt.Time)
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// Logout from every device
+func (server *Server) logoutAllUser(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	err := server.store.BlockUserSessions(ctx, authPayload.Username)
+	if err != nil {
+		writeInternalError(ctx, err)
+		return
+	}
+
+	revokedAt := time.Now()
+	_, err = server.store.RevokeUserTokens(ctx, db.RevokeUserTokensParams{
+		Username:  authPayload.Username,
+		RevokedAt: pgtype.Timestamptz{Time: revokedAt, Valid: true},
+	})
+	if err != nil {
+		writeStoreError(ctx, err)
+		return
+	}
+	server.denylist.RevokeUser(authPayload.Username, revokedAt)
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -13,10 +14,11 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
-	"github.com/lib/pq"
 	mockdb "github.com/niloy104/simplebank/db/mock"
 	db "github.com/niloy104/simplebank/db/sqlc"
+	"github.com/niloy104/simplebank/token"
 	"github.com/niloy104/simplebank/util"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
@@ -67,10 +69,11 @@ func TestCreateUserAPI(t *testing.T) {
 				store.EXPECT().
 					CreateUser(gomock.Any(), gomock.Any()).
 					Times(1).
-					Return(db.User{}, &pq.Error{Code: "23505"})
+					Return(db.User{}, db.ErrUniqueViolation)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusForbidden, recorder.Code)
+				require.Equal(t, http.StatusConflict, recorder.Code)
+				requireErrorCode(t, recorder, errorCodeAlreadyExists)
 			},
 		},
 		{
@@ -189,6 +192,20 @@ func TestLoginUserAPI(t *testing.T) {
 					GetUser(gomock.Any(), gomock.Eq(user.Username)).
 					Times(1).
 					Return(user, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateSessionParams) (db.Session, error) {
+						return db.Session{
+							ID:           arg.ID,
+							Username:     arg.Username,
+							RefreshToken: arg.RefreshToken,
+							UserAgent:    arg.UserAgent,
+							ClientIp:     arg.ClientIp,
+							IsBlocked:    arg.IsBlocked,
+							ExpiresAt:    arg.ExpiresAt,
+						}, nil
+					})
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
 				require.Equal(t, http.StatusOK, recorder.Code)
@@ -200,10 +217,37 @@ func TestLoginUserAPI(t *testing.T) {
 				require.NoError(t, err)
 
 				require.NotEmpty(t, rsp.AccessToken)
-				payload, err := server.tokenMaker.VerifyToken(rsp.AccessToken)
+				payload, err := server.tokenMaker.VerifyToken(rsp.AccessToken, token.TokenTypeAccessToken)
 				require.NoError(t, err)
 				require.Equal(t, user.Username, payload.Username)
 				require.Equal(t, newUserResponse(user), rsp.User)
+
+				require.NotEmpty(t, rsp.RefreshToken)
+				refreshPayload, err := server.tokenMaker.VerifyToken(rsp.RefreshToken, token.TokenTypeRefreshToken)
+				require.NoError(t, err)
+				require.Equal(t, refreshPayload.SessionID, rsp.SessionID)
+				require.Equal(t, payload.SessionID, rsp.SessionID)
+				require.True(t, rsp.RefreshTokenExpiresAt.After(rsp.AccessTokenExpiresAt))
+			},
+		},
+		{
+			name: "CreateSessionError",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
 			},
 		},
 		{
@@ -216,7 +260,7 @@ func TestLoginUserAPI(t *testing.T) {
 				store.EXPECT().
 					GetUser(gomock.Any(), gomock.Eq(user.Username)).
 					Times(1).
-					Return(db.User{}, sql.ErrNoRows)
+					Return(db.User{}, db.ErrRecordNotFound)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
 				require.Equal(t, http.StatusNotFound, recorder.Code)
@@ -311,6 +355,478 @@ func TestLoginUserAPI(t *testing.T) {
 	}
 }
 
+func TestLoginLockout(t *testing.T) {
+	user, password := randomUserWithPassword(t)
+	maxFailedLogins := int32(3)
+
+	lockedUser := user
+	lockedUser.LockedUntil = pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true}
+
+	failedUser := user
+	failedUser.FailedLoginAttempts = 2
+	failedUser.LockedUntil = pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true}
+
+	testCases := []struct {
+		name          string
+		password      string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "Locked",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(lockedUser, nil)
+				store.EXPECT().
+					RecordFailedLogin(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusLocked, recorder.Code)
+				requireErrorCode(t, recorder, errorCodeAccountLocked)
+				require.Equal(t, "60", recorder.Header().Get(retryAfterHeader))
+			},
+		},
+		{
+			name:     "IncorrectPasswordIsRecorded",
+			password: "wrong-password",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					RecordFailedLogin(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.RecordFailedLoginParams) (db.User, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, maxFailedLogins, arg.MaxAttempts)
+						require.WithinDuration(t, time.Now().Add(15*time.Minute), arg.LockedUntil.Time, time.Second)
+						return user, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireErrorCode(t, recorder, errorCodeUnauthenticated)
+			},
+		},
+		{
+			name:     "RecordFailedLoginError",
+			password: "wrong-password",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					RecordFailedLogin(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+		{
+			name:     "SuccessResetsFailedLogins",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(failedUser, nil)
+				store.EXPECT().
+					ResetFailedLogins(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			server.config.MaxFailedLogins = maxFailedLogins
+			server.config.LoginLockoutDuration = 15 * time.Minute
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{
+				"username": user.Username,
+				"password": tc.password,
+			})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func TestLogoutUserAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	session := db.Session{
+		ID:        uuid.New(),
+		Username:  user.Username,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+	}
+	refreshPayload := &token.Payload{
+		ID:        uuid.New(),
+		SessionID: session.ID,
+		Type:      token.TokenTypeRefreshToken,
+		Username:  session.Username,
+		IssuedAt:  time.Now(),
+		ExpiredAt: session.ExpiresAt.Time,
+	}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		sessionID     uuid.UUID
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, payload *token.Payload)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"session_id": session.ID,
+			},
+			sessionID: session.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().
+					BlockSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(nil)
+				store.EXPECT().
+					RevokeToken(gomock.Any(), gomock.Eq(db.RevokeTokenParams{
+						ID:        session.ID,
+						Username:  session.Username,
+						ExpiresAt: session.ExpiresAt,
+					})).
+					Times(1).
+					Return(nil)
+				store.EXPECT().
+					RevokeToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, payload *token.Payload) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+				require.True(t, server.denylist.IsRevoked(payload))
+				require.True(t, server.denylist.IsRevoked(refreshPayload))
+			},
+		},
+		{
+			name: "OtherSession",
+			body: gin.H{
+				"session_id": session.ID,
+			},
+			sessionID: uuid.New(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().
+					BlockSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(nil)
+				store.EXPECT().
+					RevokeToken(gomock.Any(), gomock.Eq(db.RevokeTokenParams{
+						ID:        session.ID,
+						Username:  session.Username,
+						ExpiresAt: session.ExpiresAt,
+					})).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, payload *token.Payload) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+				require.False(t, server.denylist.IsRevoked(payload))
+				require.True(t, server.denylist.IsRevoked(refreshPayload))
+			},
+		},
+		{
+			name: "SessionNotFound",
+			body: gin.H{
+				"session_id": session.ID,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(db.Session{}, db.ErrRecordNotFound)
+				store.EXPECT().
+					RevokeToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, payload *token.Payload) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+				require.False(t, server.denylist.IsRevoked(payload))
+			},
+		},
+		{
+			name: "UnauthorizedUser",
+			body: gin.H{
+				"session_id": session.ID,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				otherSession := session
+				otherSession.Username = util.RandomOwner()
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(otherSession, nil)
+				store.EXPECT().
+					BlockSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, payload *token.Payload) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "RevokeTokenError",
+			body: gin.H{
+				"session_id": session.ID,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().
+					BlockSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(nil)
+				store.EXPECT().
+					RevokeToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, payload *token.Payload) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+				require.False(t, server.denylist.IsRevoked(payload))
+				require.False(t, server.denylist.IsRevoked(refreshPayload))
+			},
+		},
+		{
+			name: "InvalidSessionID",
+			body: gin.H{
+				"session_id": "invalid",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, payload *token.Payload) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/logout", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			accessToken, payload, err := server.tokenMaker.CreateToken(user.Username, user.Role, tc.sessionID, time.Minute, token.TokenTypeAccessToken)
+			require.NoError(t, err)
+			request.Header.Set("Authorization", authorizationTypeBearer+" "+accessToken)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder, server, payload)
+		})
+	}
+}
+
+func TestLogoutOtherSession(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+
+	user, _ := randomUser(t)
+	sessionA := uuid.New()
+	sessionB := db.Session{
+		ID:        uuid.New(),
+		Username:  user.Username,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+	}
+
+	accessTokenA, _, err := server.tokenMaker.CreateToken(user.Username, user.Role, sessionA, time.Minute, token.TokenTypeAccessToken)
+	require.NoError(t, err)
+	accessTokenB, _, err := server.tokenMaker.CreateToken(user.Username, user.Role, sessionB.ID, time.Minute, token.TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	logout := func(accessToken string, sessionID uuid.UUID) int {
+		data, err := json.Marshal(gin.H{"session_id": sessionID})
+		require.NoError(t, err)
+
+		request, err := http.NewRequest(http.MethodPost, "/users/logout", bytes.NewReader(data))
+		require.NoError(t, err)
+		request.Header.Set("Authorization", authorizationTypeBearer+" "+accessToken)
+
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	// session A logs out session B, e.g. the session of a lost device
+	store.EXPECT().
+		GetSession(gomock.Any(), gomock.Eq(sessionB.ID)).
+		Times(1).
+		Return(sessionB, nil)
+	store.EXPECT().
+		BlockSession(gomock.Any(), gomock.Eq(sessionB.ID)).
+		Times(1).
+		Return(nil)
+	store.EXPECT().
+		RevokeToken(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(nil)
+	require.Equal(t, http.StatusNoContent, logout(accessTokenA, sessionB.ID))
+
+	// the access token of session B is rejected before reaching the store
+	require.Equal(t, http.StatusUnauthorized, logout(accessTokenB, sessionB.ID))
+
+	// the access token of session A still works
+	store.EXPECT().
+		GetSession(gomock.Any(), gomock.Eq(sessionA)).
+		Times(1).
+		Return(db.Session{}, db.ErrRecordNotFound)
+	require.Equal(t, http.StatusNotFound, logout(accessTokenA, sessionA))
+}
+
+func TestLogoutAllUserAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, payload *token.Payload)
+	}{
+		{
+			name: "OK",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					BlockUserSessions(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(nil)
+				store.EXPECT().
+					RevokeUserTokens(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, payload *token.Payload) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+				require.True(t, server.denylist.IsRevoked(payload))
+			},
+		},
+		{
+			name: "BlockSessionsError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					BlockUserSessions(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(sql.ErrConnDone)
+				store.EXPECT().
+					RevokeUserTokens(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, payload *token.Payload) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+				require.False(t, server.denylist.IsRevoked(payload))
+			},
+		},
+		{
+			name: "RevokeTokensError",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					BlockUserSessions(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(nil)
+				store.EXPECT().
+					RevokeUserTokens(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, payload *token.Payload) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+				require.False(t, server.denylist.IsRevoked(payload))
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/users/logout_all", nil)
+			require.NoError(t, err)
+
+			accessToken, payload, err := server.tokenMaker.CreateToken(user.Username, user.Role, uuid.New(), time.Minute, token.TokenTypeAccessToken)
+			require.NoError(t, err)
+			request.Header.Set("Authorization", authorizationTypeBearer+" "+accessToken)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder, server, payload)
+		})
+	}
+}
+
 func randomUserWithPassword(t *testing.T) (user db.User, password string) {
 	password = util.RandomString(6)
 	hashedPassword, err := util.HashPassword(password)
@@ -319,6 +835,7 @@ func randomUserWithPassword(t *testing.T) (user db.User, password string) {
 	now := time.Now().UTC()
 	user = db.User{
 		Username:          util.RandomOwner(),
+		Role:              util.DepositorRole,
 		HashedPassword:    hashedPassword,
 		FullName:          util.RandomOwner(),
 		Email:             util.RandomEmail(),
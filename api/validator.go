@@ -11,3 +11,11 @@ var validCurrency validator.Func = func(feildlevel validator.FieldLevel) bool {
 	}
 	return false
 }
+
+var validCronExpression validator.Func = func(fieldLevel validator.FieldLevel) bool {
+	if expression, ok := fieldLevel.Field().Interface().(string); ok {
+		_, err := util.ParseCronExpression(expression)
+		return err == nil
+	}
+	return false
+}
@@ -0,0 +1 @@
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_balance_nonnegative" CHECK ("balance" >= 0);
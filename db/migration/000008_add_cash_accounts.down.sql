@@ -0,0 +1,16 @@
+DELETE FROM "entries" WHERE "account_id" IN (SELECT "id" FROM "accounts" WHERE "is_system");
+
+DELETE FROM "transfers"
+WHERE "from_account_id" IN (SELECT "id" FROM "accounts" WHERE "is_system")
+   OR "to_account_id" IN (SELECT "id" FROM "accounts" WHERE "is_system");
+
+DELETE FROM "accounts" WHERE "is_system";
+
+DELETE FROM "users" WHERE "username" = '_system';
+
+DROP INDEX IF EXISTS "accounts_system_currency_key";
+
+ALTER TABLE IF EXISTS "accounts" DROP CONSTRAINT IF EXISTS "accounts_balance_nonnegative";
+ALTER TABLE IF EXISTS "accounts" ADD CONSTRAINT "accounts_balance_nonnegative" CHECK ("balance" >= 0);
+
+ALTER TABLE IF EXISTS "accounts" DROP COLUMN IF EXISTS "is_system";
@@ -0,0 +1,17 @@
+ALTER TABLE "accounts" ADD COLUMN "is_system" boolean NOT NULL DEFAULT false;
+
+-- system cash accounts go negative by the amount of money deposited into the bank
+ALTER TABLE "accounts" DROP CONSTRAINT "accounts_balance_nonnegative";
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_balance_nonnegative" CHECK ("is_system" OR "balance" >= 0);
+
+CREATE UNIQUE INDEX "accounts_system_currency_key" ON "accounts" ("currency") WHERE "is_system";
+
+-- the system user cannot log in: its username fails validation and its password hash is empty
+INSERT INTO "users" ("username", "hashed_password", "full_name", "email", "role")
+VALUES ('_system', '', 'System', 'system@simplebank.invalid', 'system');
+
+INSERT INTO "accounts" ("owner", "balance", "currency", "is_system")
+VALUES
+  ('_system', 0, 'USD', true),
+  ('_system', 0, 'EUR', true),
+  ('_system', 0, 'BDT', true);
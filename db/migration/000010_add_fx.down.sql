@@ -0,0 +1,17 @@
+DELETE FROM "entries" WHERE "account_id" IN (SELECT "id" FROM "accounts" WHERE "owner" = '_fx');
+
+DELETE FROM "transfers" WHERE "quote_id" IS NOT NULL;
+
+DELETE FROM "accounts" WHERE "owner" = '_fx';
+
+DELETE FROM "users" WHERE "username" = '_fx';
+
+CREATE UNIQUE INDEX "accounts_system_currency_key" ON "accounts" ("currency") WHERE "is_system";
+
+ALTER TABLE IF EXISTS "transfers" DROP COLUMN IF EXISTS "quote_id";
+ALTER TABLE IF EXISTS "transfers" DROP COLUMN IF EXISTS "fx_rate";
+ALTER TABLE IF EXISTS "transfers" DROP COLUMN IF EXISTS "to_amount";
+
+DROP TABLE IF EXISTS "fx_quotes";
+
+DROP TABLE IF EXISTS "fx_rates";
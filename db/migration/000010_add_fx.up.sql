@@ -0,0 +1,43 @@
+CREATE TABLE "fx_rates" (
+  "from_currency" varchar NOT NULL,
+  "to_currency" varchar NOT NULL,
+  "rate" numeric(20,8) NOT NULL,
+  "updated_at" timestamptz NOT NULL DEFAULT (now()),
+  PRIMARY KEY ("from_currency", "to_currency"),
+  CONSTRAINT "fx_rates_rate_positive" CHECK ("rate" > 0)
+);
+
+CREATE TABLE "fx_quotes" (
+  "id" uuid PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "from_currency" varchar NOT NULL,
+  "to_currency" varchar NOT NULL,
+  "rate" numeric(20,8) NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "used_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "fx_quotes" ("username");
+
+ALTER TABLE "fx_quotes" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+ALTER TABLE "transfers" ADD COLUMN "to_amount" bigint;
+ALTER TABLE "transfers" ADD COLUMN "fx_rate" numeric(20,8);
+ALTER TABLE "transfers" ADD COLUMN "quote_id" uuid UNIQUE;
+
+COMMENT ON COLUMN "transfers"."to_amount" IS 'amount credited in the destination currency of a cross-currency transfer';
+
+ALTER TABLE "transfers" ADD FOREIGN KEY ("quote_id") REFERENCES "fx_quotes" ("id");
+
+-- each currency now has a cash account and an FX clearing account, owned by different system users
+DROP INDEX IF EXISTS "accounts_system_currency_key";
+
+INSERT INTO "users" ("username", "hashed_password", "full_name", "email", "role")
+VALUES ('_fx', '', 'FX Clearing', 'fx@simplebank.invalid', 'system');
+
+INSERT INTO "accounts" ("owner", "balance", "currency", "is_system")
+VALUES
+  ('_fx', 0, 'USD', true),
+  ('_fx', 0, 'EUR', true),
+  ('_fx', 0, 'BDT', true);
@@ -0,0 +1,48 @@
+CREATE TABLE "scheduled_transfers" (
+  "id" bigserial PRIMARY KEY,
+  "owner" varchar NOT NULL,
+  "from_account_id" bigint NOT NULL,
+  "to_account_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "cron_expression" varchar,
+  "interval_seconds" bigint,
+  "next_run_at" timestamptz,
+  "is_active" boolean NOT NULL DEFAULT true,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  CONSTRAINT "scheduled_transfers_amount_positive" CHECK ("amount" > 0),
+  CONSTRAINT "scheduled_transfers_single_recurrence" CHECK ("cron_expression" IS NULL OR "interval_seconds" IS NULL),
+  CONSTRAINT "scheduled_transfers_interval_positive" CHECK ("interval_seconds" > 0)
+);
+
+CREATE TABLE "scheduled_transfer_runs" (
+  "id" bigserial PRIMARY KEY,
+  "scheduled_transfer_id" bigint NOT NULL,
+  "scheduled_at" timestamptz NOT NULL,
+  "status" varchar NOT NULL,
+  "transfer_id" bigint,
+  "error" varchar,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "scheduled_transfers" ("owner", "created_at", "id");
+
+-- the scheduler only scans active rows that still have a run ahead
+CREATE INDEX ON "scheduled_transfers" ("next_run_at") WHERE "is_active" AND "next_run_at" IS NOT NULL;
+
+CREATE INDEX ON "scheduled_transfer_runs" ("scheduled_transfer_id", "created_at", "id");
+
+COMMENT ON COLUMN "scheduled_transfers"."cron_expression" IS 'standard 5-field cron expression, evaluated in UTC';
+
+COMMENT ON COLUMN "scheduled_transfers"."next_run_at" IS 'null once a one-off transfer has run';
+
+COMMENT ON COLUMN "scheduled_transfer_runs"."status" IS 'succeeded or failed';
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("from_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "scheduled_transfers" ADD FOREIGN KEY ("to_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "scheduled_transfer_runs" ADD FOREIGN KEY ("scheduled_transfer_id") REFERENCES "scheduled_transfers" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "scheduled_transfer_runs" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
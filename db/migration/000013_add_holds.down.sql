@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS "holds";
+
+ALTER TABLE IF EXISTS "accounts" DROP CONSTRAINT IF EXISTS "accounts_held_amount_nonnegative";
+ALTER TABLE IF EXISTS "accounts" DROP CONSTRAINT IF EXISTS "accounts_balance_nonnegative";
+ALTER TABLE IF EXISTS "accounts" ADD CONSTRAINT "accounts_balance_nonnegative" CHECK ("is_system" OR "balance" >= 0);
+
+ALTER TABLE IF EXISTS "accounts" DROP COLUMN IF EXISTS "available_balance";
+ALTER TABLE IF EXISTS "accounts" DROP COLUMN IF EXISTS "held_amount";
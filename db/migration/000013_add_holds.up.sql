@@ -0,0 +1,38 @@
+ALTER TABLE "accounts" ADD COLUMN "held_amount" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "accounts" ADD COLUMN "available_balance" bigint NOT NULL GENERATED ALWAYS AS ("balance" - "held_amount") STORED;
+
+COMMENT ON COLUMN "accounts"."held_amount" IS 'total of the pending holds on the account';
+
+-- funds reserved by holds cannot be spent, so the available balance is what must stay non-negative
+ALTER TABLE "accounts" DROP CONSTRAINT "accounts_balance_nonnegative";
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_balance_nonnegative" CHECK ("is_system" OR "balance" - "held_amount" >= 0);
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_held_amount_nonnegative" CHECK ("held_amount" >= 0);
+
+CREATE TABLE "holds" (
+  "id" bigserial PRIMARY KEY,
+  "account_id" bigint NOT NULL,
+  "to_account_id" bigint NOT NULL,
+  "amount" bigint NOT NULL,
+  "captured_amount" bigint NOT NULL DEFAULT 0,
+  "status" varchar NOT NULL DEFAULT 'pending',
+  "transfer_id" bigint,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  CONSTRAINT "holds_amount_positive" CHECK ("amount" > 0),
+  CONSTRAINT "holds_captured_amount_check" CHECK ("captured_amount" BETWEEN 0 AND "amount")
+);
+
+CREATE INDEX ON "holds" ("account_id");
+
+-- the expiry worker only scans pending holds
+CREATE INDEX ON "holds" ("expires_at") WHERE "status" = 'pending';
+
+COMMENT ON COLUMN "holds"."status" IS 'pending, captured, released or expired';
+
+COMMENT ON COLUMN "holds"."transfer_id" IS 'the transfer made when the hold was captured';
+
+ALTER TABLE "holds" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "holds" ADD FOREIGN KEY ("to_account_id") REFERENCES "accounts" ("id");
+
+ALTER TABLE "holds" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
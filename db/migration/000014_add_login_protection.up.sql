@@ -0,0 +1,16 @@
+ALTER TABLE "users" ADD COLUMN "failed_login_attempts" int NOT NULL DEFAULT 0;
+ALTER TABLE "users" ADD COLUMN "locked_until" timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00Z';
+
+COMMENT ON COLUMN "users"."failed_login_attempts" IS 'failed logins since the last successful one or the last lockout';
+COMMENT ON COLUMN "users"."locked_until" IS 'logins are refused until this time';
+
+CREATE TABLE "rate_limit_buckets" (
+  "key" varchar PRIMARY KEY,
+  "tokens" double precision NOT NULL,
+  "updated_at" timestamptz NOT NULL
+);
+
+-- buckets untouched for a whole period are full again and get pruned
+CREATE INDEX ON "rate_limit_buckets" ("updated_at");
+
+COMMENT ON TABLE "rate_limit_buckets" IS 'token buckets of the rate limiter shared by all replicas';
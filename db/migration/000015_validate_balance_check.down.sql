@@ -0,0 +1,2 @@
+ALTER TABLE IF EXISTS "accounts" DROP CONSTRAINT IF EXISTS "accounts_balance_nonnegative";
+ALTER TABLE IF EXISTS "accounts" ADD CONSTRAINT "accounts_balance_nonnegative" CHECK ("is_system" OR "balance" - "held_amount" >= 0) NOT VALID;
@@ -0,0 +1,19 @@
+-- Databases migrated with an earlier revision of 000007, 000008 or 000013 have the balance check NOT VALID,
+-- since they may hold negative balances.
+-- It is validated here when no account breaks it. Otherwise it stays enforced on new writes only:
+-- correct the accounts listed by
+--   SELECT "id", "owner", "balance", "held_amount" FROM "accounts" WHERE NOT "is_system" AND "balance" - "held_amount" < 0;
+-- with a deposit or an adjusting entry, then run
+--   ALTER TABLE "accounts" VALIDATE CONSTRAINT "accounts_balance_nonnegative";
+DO $$
+DECLARE
+  negative bigint;
+BEGIN
+  SELECT count(*) INTO negative FROM "accounts" WHERE NOT "is_system" AND "balance" - "held_amount" < 0;
+
+  IF negative = 0 THEN
+    ALTER TABLE "accounts" VALIDATE CONSTRAINT "accounts_balance_nonnegative";
+  ELSE
+    RAISE WARNING 'accounts_balance_nonnegative is not validated: % accounts have a negative balance', negative;
+  END IF;
+END $$;
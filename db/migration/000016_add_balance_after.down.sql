@@ -0,0 +1,3 @@
+ALTER TABLE IF EXISTS "transfers" DROP COLUMN IF EXISTS "to_balance_after";
+ALTER TABLE IF EXISTS "transfers" DROP COLUMN IF EXISTS "from_balance_after";
+ALTER TABLE IF EXISTS "entries" DROP COLUMN IF EXISTS "balance_after";
@@ -0,0 +1,73 @@
+-- the balance of the account right after each entry and transfer is stored when it is posted,
+-- so a page of history reads it instead of summing the whole history of the account
+ALTER TABLE "entries" ADD COLUMN "balance_after" bigint;
+ALTER TABLE "transfers" ADD COLUMN "from_balance_after" bigint;
+ALTER TABLE "transfers" ADD COLUMN "to_balance_after" bigint;
+
+-- existing rows get the balance the history queries computed so far:
+-- the current balance minus everything posted to the account after the row
+UPDATE "entries" SET "balance_after" = history."balance_after"
+FROM (
+  SELECT
+    "entries"."id",
+    "accounts"."balance" - COALESCE(SUM("entries"."amount") OVER (
+      PARTITION BY "entries"."account_id"
+      ORDER BY "entries"."created_at" DESC, "entries"."id" DESC
+      ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+    ), 0) AS "balance_after"
+  FROM "entries"
+  JOIN "accounts" ON "accounts"."id" = "entries"."account_id"
+) history
+WHERE "entries"."id" = history."id";
+
+ALTER TABLE "entries" ALTER COLUMN "balance_after" SET NOT NULL;
+
+WITH changes AS (
+  SELECT
+    "id",
+    "from_account_id" AS "account_id",
+    "created_at",
+    CASE WHEN "from_account_id" = "to_account_id" THEN 0 ELSE -"amount" END AS "balance_change",
+    true AS "debit"
+  FROM "transfers"
+  WHERE "from_account_id" IS NOT NULL
+  UNION ALL
+  SELECT
+    "id",
+    "to_account_id",
+    "created_at",
+    CASE WHEN "from_account_id" = "to_account_id" THEN 0 ELSE COALESCE("to_amount", "amount") END,
+    false
+  FROM "transfers"
+  WHERE "to_account_id" IS NOT NULL
+),
+history AS (
+  SELECT
+    changes."id",
+    changes."debit",
+    "accounts"."balance" - COALESCE(SUM(changes."balance_change") OVER (
+      PARTITION BY changes."account_id"
+      ORDER BY changes."created_at" DESC, changes."id" DESC
+      ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+    ), 0) AS "balance_after"
+  FROM changes
+  JOIN "accounts" ON "accounts"."id" = changes."account_id"
+)
+UPDATE "transfers" SET
+  "from_balance_after" = balances."from_balance_after",
+  "to_balance_after" = balances."to_balance_after"
+FROM (
+  SELECT
+    "id",
+    MAX("balance_after") FILTER (WHERE "debit") AS "from_balance_after",
+    MAX("balance_after") FILTER (WHERE NOT "debit") AS "to_balance_after"
+  FROM history
+  GROUP BY "id"
+) balances
+WHERE "transfers"."id" = balances."id";
+
+COMMENT ON COLUMN "entries"."balance_after" IS 'balance of the account right after the entry';
+
+COMMENT ON COLUMN "transfers"."from_balance_after" IS 'balance of the source account right after the transfer';
+
+COMMENT ON COLUMN "transfers"."to_balance_after" IS 'balance of the destination account right after the transfer';
@@ -0,0 +1,20 @@
+-- a transfer always moves a positive amount from its sender to its recipient, whichever store path writes it.
+-- Negative reversals may already exist, so the check is validated only when no transfer breaks it;
+-- otherwise it stays enforced on new writes until the transfers listed by
+--   SELECT "id", "amount", "reversal_of" FROM "transfers" WHERE "amount" <= 0;
+-- are corrected and the constraint is validated with
+--   ALTER TABLE "transfers" VALIDATE CONSTRAINT "transfers_amount_positive";
+ALTER TABLE "transfers" ADD CONSTRAINT "transfers_amount_positive" CHECK ("amount" > 0) NOT VALID;
+
+DO $$
+DECLARE
+  invalid bigint;
+BEGIN
+  SELECT count(*) INTO invalid FROM "transfers" WHERE "amount" <= 0;
+
+  IF invalid = 0 THEN
+    ALTER TABLE "transfers" VALIDATE CONSTRAINT "transfers_amount_positive";
+  ELSE
+    RAISE WARNING 'transfers_amount_positive is not validated: % transfers have an amount that is not positive', invalid;
+  END IF;
+END $$;
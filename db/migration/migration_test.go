@@ -0,0 +1,17 @@
+package migration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatestVersion(t *testing.T) {
+	version, err := LatestVersion()
+	require.NoError(t, err)
+
+	names, err := files.ReadDir(".")
+	require.NoError(t, err)
+	// every migration has an up and a down file
+	require.Equal(t, int64(len(names)/2), version)
+}
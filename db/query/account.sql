@@ -14,12 +14,28 @@ WHERE id = $1
 LIMIT 1
 FOR NO KEY UPDATE;
 
+-- name: GetCashAccount :one
+SELECT * FROM accounts
+WHERE is_system AND owner = '_system' AND currency = $1
+LIMIT 1;
+
+-- name: GetFXClearingAccount :one
+SELECT * FROM accounts
+WHERE is_system AND owner = '_fx' AND currency = $1
+LIMIT 1;
+
 -- name: ListAccounts :many
+-- rows come after the (after_created_at, after_id) keyset position, or from the start if it is null
 SELECT * FROM accounts
-WHERE owner = $1
-ORDER BY id
-LIMIT $2
-OFFSET $3;
+WHERE
+  owner = sqlc.arg(owner) AND
+  (created_at, id) > (
+    COALESCE(sqlc.narg(after_created_at)::timestamptz, '-infinity'),
+    COALESCE(sqlc.narg(after_id)::bigint, 0)
+  )
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
 
 -- name: UpdateAccount :one
 UPDATE accounts
@@ -33,6 +49,18 @@ set balance = balance + sqlc.arg(amount)
 WHERE id = sqlc.arg(id)
 RETURNING *;
 
+-- name: AddAccountHeldAmount :one
+UPDATE accounts
+set held_amount = held_amount + sqlc.arg(amount)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: SetAccountFrozen :one
+UPDATE accounts
+set is_frozen = sqlc.arg(is_frozen)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
 -- name: DeleteAccount :exec
 DELETE FROM accounts
 WHERE id = $1;
@@ -1,9 +1,10 @@
 -- name: CreateEntry :one
 INSERT INTO entries (
   account_id,
-  amount
+  amount,
+  balance_after
 ) VALUES (
-  $1, $2
+  $1, $2, $3
 ) RETURNING *;
 
 -- name: GetEntry :one
@@ -15,4 +16,31 @@ SELECT * FROM entries
 WHERE account_id = $1
 ORDER BY id
 LIMIT $2
-OFFSET $3;
\ No newline at end of file
+OFFSET $3;
+
+-- name: ListAccountEntries :many
+-- running_balance is the account balance right after the entry
+-- rows come after the (after_created_at, after_id) keyset position, or from the start if it is null
+SELECT
+  id,
+  account_id,
+  amount,
+  created_at,
+  balance_after AS running_balance
+FROM entries
+WHERE
+  account_id = sqlc.arg(account_id) AND
+  (created_at, id) > (
+    COALESCE(sqlc.narg(after_created_at)::timestamptz, '-infinity'),
+    COALESCE(sqlc.narg(after_id)::bigint, 0)
+  ) AND
+  (sqlc.narg(start_time)::timestamptz IS NULL OR created_at >= sqlc.narg(start_time)) AND
+  (sqlc.narg(end_time)::timestamptz IS NULL OR created_at < sqlc.narg(end_time)) AND
+  (sqlc.narg(min_amount)::bigint IS NULL OR abs(amount) >= sqlc.narg(min_amount)) AND
+  (sqlc.narg(max_amount)::bigint IS NULL OR abs(amount) <= sqlc.narg(max_amount)) AND
+  (sqlc.narg(direction)::text IS NULL OR
+    (sqlc.narg(direction) = 'credit' AND amount > 0) OR
+    (sqlc.narg(direction) = 'debit' AND amount < 0))
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
@@ -0,0 +1,34 @@
+-- name: UpsertFXRate :one
+INSERT INTO fx_rates (from_currency, to_currency, rate)
+VALUES ($1, $2, $3)
+ON CONFLICT (from_currency, to_currency)
+DO UPDATE SET rate = EXCLUDED.rate, updated_at = now()
+RETURNING *;
+
+-- name: GetFXRate :one
+SELECT * FROM fx_rates
+WHERE from_currency = $1 AND to_currency = $2
+LIMIT 1;
+
+-- name: CreateFXQuote :one
+INSERT INTO fx_quotes (id, username, from_currency, to_currency, rate, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetFXQuote :one
+SELECT * FROM fx_quotes
+WHERE id = $1
+LIMIT 1;
+
+-- name: UseFXQuote :one
+-- marks an unexpired quote as used and converts the amount at its rate, rounding down
+UPDATE fx_quotes
+SET used_at = now()
+WHERE
+  id = sqlc.arg(id) AND
+  username = sqlc.arg(username) AND
+  from_currency = sqlc.arg(from_currency) AND
+  to_currency = sqlc.arg(to_currency) AND
+  used_at IS NULL AND
+  expires_at > now()
+RETURNING id, rate, floor(sqlc.arg(amount)::bigint * rate)::bigint AS to_amount;
@@ -0,0 +1,44 @@
+-- name: CreateHold :one
+INSERT INTO holds (
+  account_id,
+  to_account_id,
+  amount,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4
+) RETURNING *;
+
+-- name: GetHold :one
+SELECT * FROM holds
+WHERE id = $1
+LIMIT 1;
+
+-- name: GetHoldForUpdate :one
+SELECT * FROM holds
+WHERE id = $1
+LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: ListAccountHolds :many
+SELECT * FROM holds
+WHERE account_id = $1
+ORDER BY id
+LIMIT $2
+OFFSET $3;
+
+-- name: ClaimExpiredHold :one
+-- locks the earliest expired pending hold, skipping holds another replica is already expiring
+SELECT * FROM holds
+WHERE status = 'pending' AND expires_at <= sqlc.arg(now)
+ORDER BY expires_at
+LIMIT 1
+FOR NO KEY UPDATE SKIP LOCKED;
+
+-- name: SetHoldStatus :one
+UPDATE holds
+SET
+  status = sqlc.arg(status),
+  captured_amount = COALESCE(sqlc.narg(captured_amount), captured_amount),
+  transfer_id = COALESCE(sqlc.narg(transfer_id), transfer_id)
+WHERE id = sqlc.arg(id)
+RETURNING *;
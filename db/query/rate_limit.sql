@@ -0,0 +1,26 @@
+-- name: TakeRateLimitToken :one
+-- It takes a token from the bucket after refilling it at refill_rate tokens per second.
+-- An empty bucket is left untouched and no row is returned.
+INSERT INTO rate_limit_buckets (key, tokens, updated_at)
+VALUES (sqlc.arg(key), sqlc.arg(burst)::float8 - 1, sqlc.arg(now))
+ON CONFLICT (key) DO UPDATE
+SET
+  tokens = LEAST(
+    sqlc.arg(burst)::float8,
+    rate_limit_buckets.tokens + GREATEST(EXTRACT(EPOCH FROM EXCLUDED.updated_at - rate_limit_buckets.updated_at)::float8, 0) * sqlc.arg(refill_rate)::float8
+  ) - 1,
+  updated_at = EXCLUDED.updated_at
+WHERE LEAST(
+  sqlc.arg(burst)::float8,
+  rate_limit_buckets.tokens + GREATEST(EXTRACT(EPOCH FROM EXCLUDED.updated_at - rate_limit_buckets.updated_at)::float8, 0) * sqlc.arg(refill_rate)::float8
+) >= 1
+RETURNING *;
+
+-- name: GetRateLimitBucket :one
+SELECT * FROM rate_limit_buckets
+WHERE key = $1
+LIMIT 1;
+
+-- name: DeleteRateLimitBuckets :exec
+DELETE FROM rate_limit_buckets
+WHERE updated_at < sqlc.arg(before);
@@ -0,0 +1,79 @@
+-- name: CreateScheduledTransfer :one
+INSERT INTO scheduled_transfers (
+  owner,
+  from_account_id,
+  to_account_id,
+  amount,
+  cron_expression,
+  interval_seconds,
+  next_run_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+) RETURNING *;
+
+-- name: GetScheduledTransfer :one
+SELECT * FROM scheduled_transfers
+WHERE id = $1
+LIMIT 1;
+
+-- name: ListScheduledTransfers :many
+SELECT * FROM scheduled_transfers
+WHERE
+  owner = sqlc.arg(owner) AND
+  (created_at, id) > (
+    COALESCE(sqlc.narg(after_created_at)::timestamptz, '-infinity'),
+    COALESCE(sqlc.narg(after_id)::bigint, 0)
+  )
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: UpdateScheduledTransfer :one
+UPDATE scheduled_transfers
+SET
+  amount = COALESCE(sqlc.narg(amount), amount),
+  is_active = COALESCE(sqlc.narg(is_active), is_active),
+  next_run_at = COALESCE(sqlc.narg(next_run_at), next_run_at)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: DeleteScheduledTransfer :exec
+DELETE FROM scheduled_transfers
+WHERE id = $1;
+
+-- name: ClaimDueScheduledTransfer :one
+-- locks the earliest due scheduled transfer, skipping rows another replica is already running
+SELECT * FROM scheduled_transfers
+WHERE is_active AND next_run_at <= sqlc.arg(now)
+ORDER BY next_run_at
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: SetScheduledTransferNextRun :one
+UPDATE scheduled_transfers
+SET next_run_at = sqlc.narg(next_run_at)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: CreateScheduledTransferRun :one
+INSERT INTO scheduled_transfer_runs (
+  scheduled_transfer_id,
+  scheduled_at,
+  status,
+  transfer_id,
+  error
+) VALUES (
+  $1, $2, $3, $4, $5
+) RETURNING *;
+
+-- name: ListScheduledTransferRuns :many
+SELECT * FROM scheduled_transfer_runs
+WHERE
+  scheduled_transfer_id = sqlc.arg(scheduled_transfer_id) AND
+  (created_at, id) > (
+    COALESCE(sqlc.narg(after_created_at)::timestamptz, '-infinity'),
+    COALESCE(sqlc.narg(after_id)::bigint, 0)
+  )
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
@@ -11,6 +11,16 @@ INSERT INTO transfers (
 SELECT * FROM transfers
 WHERE id = $1 LIMIT 1;
 
+-- name: GetTransferForUpdate :one
+SELECT * FROM transfers
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: GetReversedAmount :one
+-- the total amount already moved back by reversals of the transfer
+SELECT COALESCE(SUM(amount), 0)::bigint FROM transfers
+WHERE reversal_of = $1;
+
 -- name: ListTransfers :many
 SELECT * FROM transfers
 WHERE 
@@ -18,4 +28,83 @@ WHERE
     to_account_id = $2
 ORDER BY id
 LIMIT $3
-OFFSET $4;
\ No newline at end of file
+OFFSET $4;
+
+-- name: ListAccountTransfers :many
+-- running_balance is the account balance right after the transfer
+-- rows come after the (after_created_at, after_id) keyset position, or from the start if it is null
+-- account_amount is the amount in the currency of the account, which differs on the credit side of a cross-currency transfer
+WITH account_transfers AS (
+  SELECT
+    transfers.*,
+    CASE
+      WHEN from_account_id = sqlc.arg(account_id)::bigint THEN amount
+      ELSE COALESCE(to_amount, amount)
+    END AS account_amount,
+    CASE
+      WHEN to_account_id = sqlc.arg(account_id)::bigint THEN to_balance_after
+      ELSE from_balance_after
+    END AS running_balance
+  FROM transfers
+  WHERE from_account_id = sqlc.arg(account_id)::bigint OR to_account_id = sqlc.arg(account_id)::bigint
+)
+SELECT
+  id,
+  from_account_id,
+  to_account_id,
+  amount,
+  to_amount,
+  fx_rate,
+  quote_id,
+  reversal_of,
+  created_at,
+  account_amount::bigint AS account_amount,
+  running_balance::bigint AS running_balance
+FROM account_transfers
+WHERE
+  (created_at, id) > (
+    COALESCE(sqlc.narg(after_created_at)::timestamptz, '-infinity'),
+    COALESCE(sqlc.narg(after_id)::bigint, 0)
+  ) AND
+  (sqlc.narg(start_time)::timestamptz IS NULL OR created_at >= sqlc.narg(start_time)) AND
+  (sqlc.narg(end_time)::timestamptz IS NULL OR created_at < sqlc.narg(end_time)) AND
+  (sqlc.narg(min_amount)::bigint IS NULL OR account_amount >= sqlc.narg(min_amount)) AND
+  (sqlc.narg(max_amount)::bigint IS NULL OR account_amount <= sqlc.narg(max_amount)) AND
+  (sqlc.narg(direction)::text IS NULL OR
+    (sqlc.narg(direction) = 'credit' AND to_account_id = sqlc.arg(account_id)::bigint) OR
+    (sqlc.narg(direction) = 'debit' AND from_account_id = sqlc.arg(account_id)::bigint))
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: SetTransferBalancesAfter :one
+-- the balances are known only once the accounts of the transfer are updated
+UPDATE transfers
+SET
+  from_balance_after = sqlc.narg(from_balance_after),
+  to_balance_after = sqlc.narg(to_balance_after)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+
+-- name: CreateFXTransfer :one
+INSERT INTO transfers (
+  from_account_id,
+  to_account_id,
+  amount,
+  to_amount,
+  fx_rate,
+  quote_id
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+) RETURNING *;
+
+-- name: CreateReversalTransfer :one
+INSERT INTO transfers (
+  from_account_id,
+  to_account_id,
+  amount,
+  reversal_of
+) VALUES (
+  $1, $2, $3, $4
+) RETURNING *;
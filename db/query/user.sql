@@ -6,4 +6,34 @@ RETURNING *;
 -- name: GetUser :one
 SELECT * FROM users
 WHERE username = $1
-LIMIT 1;
\ No newline at end of file
+LIMIT 1;
+
+-- name: RevokeUserTokens :one
+UPDATE users
+SET tokens_revoked_at = sqlc.arg(revoked_at)
+WHERE username = sqlc.arg(username)
+RETURNING *;
+
+-- name: ListUserTokenRevocations :many
+SELECT username, tokens_revoked_at FROM users
+WHERE tokens_revoked_at > sqlc.arg(since);
+
+-- name: RecordFailedLogin :one
+-- The attempt reaching max_attempts locks the user until locked_until and starts the count again.
+UPDATE users
+SET
+  failed_login_attempts = CASE
+    WHEN failed_login_attempts + 1 >= sqlc.arg(max_attempts)::int THEN 0
+    ELSE failed_login_attempts + 1
+  END,
+  locked_until = CASE
+    WHEN failed_login_attempts + 1 >= sqlc.arg(max_attempts)::int THEN sqlc.arg(locked_until)::timestamptz
+    ELSE locked_until
+  END
+WHERE username = sqlc.arg(username)
+RETURNING *;
+
+-- name: ResetFailedLogins :exec
+UPDATE users
+SET failed_login_attempts = 0
+WHERE username = $1 AND failed_login_attempts > 0;
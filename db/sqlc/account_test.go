@@ -2,21 +2,24 @@ package db
 
 import (
 	"context"
-	"database/sql"
-	"errors"
 	"testing"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/niloy104/simplebank/util"
 	"github.com/stretchr/testify/require"
 )
 
 func createRandomAccount(t *testing.T) Account {
+	return createRandomAccountWithBalance(t, util.RandomMoney())
+}
+
+func createRandomAccountWithBalance(t *testing.T, balance int64) Account {
 	user := createRandomUser(t)
 
 	arg := CreateAccountParams{
 		Owner:    user.Username,
-		Balance:  util.RandomMoney(),
+		Balance:  balance,
 		Currency: util.RandomCurrency(),
 	}
 
@@ -70,6 +73,27 @@ func TestUpdateAccount(t *testing.T) {
 	require.WithinDuration(t, account1.CreatedAt.Time, account2.CreatedAt.Time, time.Second)
 }
 
+func TestSetAccountFrozen(t *testing.T) {
+	account1 := createRandomAccount(t)
+	require.False(t, account1.IsFrozen)
+
+	account2, err := testQueries.SetAccountFrozen(context.Background(), SetAccountFrozenParams{
+		ID:       account1.ID,
+		IsFrozen: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, account1.ID, account2.ID)
+	require.True(t, account2.IsFrozen)
+	require.Equal(t, account1.Balance, account2.Balance)
+
+	account3, err := testQueries.SetAccountFrozen(context.Background(), SetAccountFrozenParams{
+		ID:       account1.ID,
+		IsFrozen: false,
+	})
+	require.NoError(t, err)
+	require.False(t, account3.IsFrozen)
+}
+
 func TestDeleteAccount(t *testing.T) {
 	account1 := createRandomAccount(t)
 
@@ -78,7 +102,7 @@ func TestDeleteAccount(t *testing.T) {
 
 	account2, err := testQueries.GetAccount(context.Background(), account1.ID)
 	require.Error(t, err)
-	require.True(t, errors.Is(err, sql.ErrNoRows))
+	require.ErrorIs(t, err, ErrRecordNotFound)
 	require.Empty(t, account2)
 }
 
@@ -104,3 +128,38 @@ func TestListAccounts(t *testing.T) {
 	}
 
 }
+
+func TestListAccountsKeyset(t *testing.T) {
+	user := createRandomUser(t)
+
+	var accounts []Account
+	for _, currency := range []string{util.USD, util.EUR, util.BDT} {
+		account, err := testQueries.CreateAccount(context.Background(), CreateAccountParams{
+			Owner:    user.Username,
+			Balance:  util.RandomMoney(),
+			Currency: currency,
+		})
+		require.NoError(t, err)
+		accounts = append(accounts, account)
+	}
+
+	firstPage, err := testQueries.ListAccounts(context.Background(), ListAccountsParams{
+		Owner: user.Username,
+		Limit: 2,
+	})
+	require.NoError(t, err)
+	require.Len(t, firstPage, 2)
+	require.Equal(t, accounts[0].ID, firstPage[0].ID)
+	require.Equal(t, accounts[1].ID, firstPage[1].ID)
+
+	last := firstPage[len(firstPage)-1]
+	secondPage, err := testQueries.ListAccounts(context.Background(), ListAccountsParams{
+		Owner:          user.Username,
+		AfterCreatedAt: last.CreatedAt,
+		AfterID:        pgtype.Int8{Int64: last.ID, Valid: true},
+		Limit:          2,
+	})
+	require.NoError(t, err)
+	require.Len(t, secondPage, 1)
+	require.Equal(t, accounts[2].ID, secondPage[0].ID)
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrSystemAccount is returned when money would be deposited to or withdrawn from a system account
+var ErrSystemAccount = errors.New("account is a system account")
+
+// DepositTxParams contains the input parameters of the deposit transaction
+type DepositTxParams struct {
+	AccountID int64 `json:"account_id"`
+	Amount    int64 `json:"amount"`
+}
+
+// WithdrawTxParams contains the input parameters of the withdraw transaction
+type WithdrawTxParams struct {
+	AccountID int64 `json:"account_id"`
+	Amount    int64 `json:"amount"`
+}
+
+// CashTxResult is the result of a deposit or withdraw transaction
+type CashTxResult struct {
+	Transfer    Transfer `json:"transfer"`
+	Account     Account  `json:"account"`
+	CashAccount Account  `json:"cash_account"`
+	Entry       Entry    `json:"entry"`
+	CashEntry   Entry    `json:"cash_entry"`
+}
+
+// DepositTx moves money into an account from the system cash account of its currency.
+// It posts a transfer with balanced entries, so the deposit shows up in the account history.
+func (store *SQLStore) DepositTx(ctx context.Context, arg DepositTxParams) (CashTxResult, error) {
+	var result CashTxResult
+
+	err := store.execTransferTx(ctx, func(q *Queries) error {
+		cashAccount, err := getCashAccountFor(ctx, q, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		transferResult, err := transfer(ctx, q, TransferTxParams{
+			FromAccountID: cashAccount.ID,
+			ToAccountID:   arg.AccountID,
+			Amount:        arg.Amount,
+		})
+		if err != nil {
+			return err
+		}
+
+		result = CashTxResult{
+			Transfer:    transferResult.Transfer,
+			Account:     transferResult.ToAccount,
+			CashAccount: transferResult.FromAccount,
+			Entry:       transferResult.ToEntry,
+			CashEntry:   transferResult.FromEntry,
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+// WithdrawTx moves money out of an account into the system cash account of its currency.
+// It fails with ErrInsufficientFunds if the account balance is too low.
+func (store *SQLStore) WithdrawTx(ctx context.Context, arg WithdrawTxParams) (CashTxResult, error) {
+	var result CashTxResult
+
+	err := store.execTransferTx(ctx, func(q *Queries) error {
+		cashAccount, err := getCashAccountFor(ctx, q, arg.AccountID)
+		if err != nil {
+			return err
+		}
+
+		transferResult, err := transfer(ctx, q, TransferTxParams{
+			FromAccountID: arg.AccountID,
+			ToAccountID:   cashAccount.ID,
+			Amount:        arg.Amount,
+		})
+		if err != nil {
+			return err
+		}
+
+		result = CashTxResult{
+			Transfer:    transferResult.Transfer,
+			Account:     transferResult.FromAccount,
+			CashAccount: transferResult.ToAccount,
+			Entry:       transferResult.FromEntry,
+			CashEntry:   transferResult.ToEntry,
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+// getCashAccountFor returns the system cash account in the currency of the account.
+// It fails if the account is a system account or is frozen.
+func getCashAccountFor(ctx context.Context, q *Queries, accountID int64) (Account, error) {
+	account, err := q.GetAccount(ctx, accountID)
+	if err != nil {
+		return Account{}, err
+	}
+
+	if account.IsSystem {
+		return Account{}, fmt.Errorf("account [%d]: %w", accountID, ErrSystemAccount)
+	}
+	if account.IsFrozen {
+		return Account{}, fmt.Errorf("account [%d]: %w", accountID, ErrAccountFrozen)
+	}
+
+	cashAccount, err := q.GetCashAccount(ctx, account.Currency)
+	if err != nil {
+		return Account{}, fmt.Errorf("cannot get %s cash account: %w", account.Currency, err)
+	}
+
+	return cashAccount, nil
+}
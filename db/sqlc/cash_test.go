@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/niloy104/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetCashAccount(t *testing.T) {
+	for _, currency := range []string{util.USD, util.EUR, util.BDT} {
+		account, err := testQueries.GetCashAccount(context.Background(), currency)
+		require.NoError(t, err)
+		require.True(t, account.IsSystem)
+		require.Equal(t, currency, account.Currency)
+	}
+}
+
+func TestDepositTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account := createRandomAccountWithBalance(t, 0)
+	amount := util.RandomInt(1, 1000)
+
+	result, err := store.DepositTx(context.Background(), DepositTxParams{
+		AccountID: account.ID,
+		Amount:    amount,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, account.ID, result.Account.ID)
+	require.Equal(t, amount, result.Account.Balance)
+
+	require.True(t, result.CashAccount.IsSystem)
+	require.Equal(t, account.Currency, result.CashAccount.Currency)
+
+	// the deposit is a transfer from the cash account with balanced entries
+	require.Equal(t, result.CashAccount.ID, result.Transfer.FromAccountID.Int64)
+	require.Equal(t, account.ID, result.Transfer.ToAccountID.Int64)
+	require.Equal(t, amount, result.Transfer.Amount)
+
+	require.Equal(t, account.ID, result.Entry.AccountID)
+	require.Equal(t, amount, result.Entry.Amount)
+	require.Equal(t, result.CashAccount.ID, result.CashEntry.AccountID)
+	require.Equal(t, -amount, result.CashEntry.Amount)
+
+	entries, err := store.ListEntries(context.Background(), ListEntriesParams{
+		AccountID: account.ID,
+		Limit:     5,
+		Offset:    0,
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, result.Entry.ID, entries[0].ID)
+}
+
+func TestWithdrawTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account := createRandomAccountWithBalance(t, transferTestBalance)
+	amount := util.RandomInt(1, transferTestBalance)
+
+	result, err := store.WithdrawTx(context.Background(), WithdrawTxParams{
+		AccountID: account.ID,
+		Amount:    amount,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, account.ID, result.Account.ID)
+	require.Equal(t, account.Balance-amount, result.Account.Balance)
+
+	require.Equal(t, account.ID, result.Transfer.FromAccountID.Int64)
+	require.Equal(t, result.CashAccount.ID, result.Transfer.ToAccountID.Int64)
+
+	require.Equal(t, account.ID, result.Entry.AccountID)
+	require.Equal(t, -amount, result.Entry.Amount)
+	require.Equal(t, result.CashAccount.ID, result.CashEntry.AccountID)
+	require.Equal(t, amount, result.CashEntry.Amount)
+}
+
+func TestWithdrawTxInsufficientFunds(t *testing.T) {
+	store := NewStore(testDB)
+
+	account := createRandomAccountWithBalance(t, 10)
+
+	_, err := store.WithdrawTx(context.Background(), WithdrawTxParams{
+		AccountID: account.ID,
+		Amount:    11,
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	updatedAccount, err := store.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, account.Balance, updatedAccount.Balance)
+}
+
+func TestCashTxRejectsSystemAccount(t *testing.T) {
+	store := NewStore(testDB)
+
+	cashAccount, err := testQueries.GetCashAccount(context.Background(), util.USD)
+	require.NoError(t, err)
+
+	_, err = store.DepositTx(context.Background(), DepositTxParams{
+		AccountID: cashAccount.ID,
+		Amount:    10,
+	})
+	require.ErrorIs(t, err, ErrSystemAccount)
+}
+
+func TestCashTxRejectsFrozenAccount(t *testing.T) {
+	store := NewStore(testDB)
+
+	account := createRandomAccount(t)
+	_, err := testQueries.SetAccountFrozen(context.Background(), SetAccountFrozenParams{
+		ID:       account.ID,
+		IsFrozen: true,
+	})
+	require.NoError(t, err)
+
+	_, err = store.DepositTx(context.Background(), DepositTxParams{
+		AccountID: account.ID,
+		Amount:    10,
+	})
+	require.ErrorIs(t, err, ErrAccountFrozen)
+
+	updatedAccount, err := testQueries.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, account.Balance, updatedAccount.Balance)
+}
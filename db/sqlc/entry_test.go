@@ -5,14 +5,17 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/niloy104/simplebank/util"
 	"github.com/stretchr/testify/require"
 )
 
 func createRandomEntry(t *testing.T, account Account) Entry {
+	amount := util.RandomMoney()
 	arg := CreateEntryParams{
-		AccountID: account.ID,
-		Amount:    util.RandomMoney(),
+		AccountID:    account.ID,
+		Amount:       amount,
+		BalanceAfter: account.Balance + amount,
 	}
 
 	entry, err := testQueries.CreateEntry(context.Background(), arg)
@@ -21,6 +24,7 @@ func createRandomEntry(t *testing.T, account Account) Entry {
 
 	require.Equal(t, arg.AccountID, entry.AccountID)
 	require.Equal(t, arg.Amount, entry.Amount)
+	require.Equal(t, arg.BalanceAfter, entry.BalanceAfter)
 
 	require.NotZero(t, entry.ID)
 	require.NotZero(t, entry.CreatedAt)
@@ -67,3 +71,81 @@ func TestListEntries(t *testing.T) {
 		require.Equal(t, arg.AccountID, entry.AccountID)
 	}
 }
+
+func TestListAccountEntries(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createRandomAccountWithBalance(t, transferTestBalance)
+	account2 := createRandomAccountWithBalance(t, transferTestBalance)
+
+	amounts := []int64{10, 20, 30, 40, 50}
+	for i, amount := range amounts {
+		arg := TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        amount,
+		}
+		if i%2 == 1 {
+			arg.FromAccountID, arg.ToAccountID = arg.ToAccountID, arg.FromAccountID
+		}
+
+		_, err := store.TransferTx(context.Background(), arg)
+		require.NoError(t, err)
+	}
+
+	entries, err := testQueries.ListAccountEntries(context.Background(), ListAccountEntriesParams{
+		AccountID: account1.ID,
+		Limit:     10,
+		Offset:    0,
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, len(amounts))
+
+	// each row carries the balance right after it
+	balance := account1.Balance
+	for i, entry := range entries {
+		require.Equal(t, account1.ID, entry.AccountID)
+		balance += entry.Amount
+		require.Equal(t, balance, entry.RunningBalance, "entry %d", i)
+	}
+
+	updatedAccount1, err := testQueries.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, updatedAccount1.Balance, balance)
+
+	credits, err := testQueries.ListAccountEntries(context.Background(), ListAccountEntriesParams{
+		AccountID: account1.ID,
+		Direction: pgtype.Text{String: "credit", Valid: true},
+		Limit:     10,
+		Offset:    0,
+	})
+	require.NoError(t, err)
+	require.Len(t, credits, 2)
+	for _, entry := range credits {
+		require.Positive(t, entry.Amount)
+	}
+
+	debits, err := testQueries.ListAccountEntries(context.Background(), ListAccountEntriesParams{
+		AccountID: account1.ID,
+		Direction: pgtype.Text{String: "debit", Valid: true},
+		MinAmount: pgtype.Int8{Int64: 30, Valid: true},
+		MaxAmount: pgtype.Int8{Int64: 50, Valid: true},
+		Limit:     10,
+		Offset:    0,
+	})
+	require.NoError(t, err)
+	require.Len(t, debits, 2)
+	for _, entry := range debits {
+		require.Negative(t, entry.Amount)
+		require.GreaterOrEqual(t, -entry.Amount, int64(30))
+	}
+
+	future, err := testQueries.ListAccountEntries(context.Background(), ListAccountEntriesParams{
+		AccountID: account1.ID,
+		StartTime: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+		Limit:     10,
+		Offset:    0,
+	})
+	require.NoError(t, err)
+	require.Empty(t, future)
+}
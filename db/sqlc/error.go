@@ -0,0 +1,131 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+	checkViolation      = "23514"
+
+	accountBalanceConstraint = "accounts_balance_nonnegative"
+)
+
+// ErrInsufficientFunds is returned when a transaction would make an account balance negative
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// The store translates the errors of Postgres to these errors, so callers
+// never depend on the driver. The original error stays in the chain,
+// so errors.As still finds the *pgconn.PgError, e.g. for its constraint name.
+var (
+	ErrRecordNotFound      = errors.New("record not found")
+	ErrUniqueViolation     = errors.New("record already exists")
+	ErrForeignKeyViolation = errors.New("referenced record does not exist")
+)
+
+// translatedError is an error of Postgres together with the error of the store it translates to
+type translatedError struct {
+	kind error
+	err  error
+}
+
+func (e *translatedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *translatedError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// translateError translates err to the error of the store it matches, if any
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var translated *translatedError
+	if errors.As(err, &translated) {
+		return err
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &translatedError{kind: ErrRecordNotFound, err: err}
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case uniqueViolation:
+			return &translatedError{kind: ErrUniqueViolation, err: err}
+		case foreignKeyViolation:
+			return &translatedError{kind: ErrForeignKeyViolation, err: err}
+		}
+	}
+
+	return err
+}
+
+// isCheckViolation reports whether err violates the named check constraint
+func isCheckViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == checkViolation && pgErr.ConstraintName == constraint
+}
+
+// translatingDBTX translates the errors of the queries run on db
+type translatingDBTX struct {
+	db DBTX
+}
+
+func translateErrors(db DBTX) DBTX {
+	return translatingDBTX{db: db}
+}
+
+// underlyingDB returns the DBTX that translateErrors wrapped
+func underlyingDB(db DBTX) DBTX {
+	if t, ok := db.(translatingDBTX); ok {
+		return t.db
+	}
+	return db
+}
+
+func (t translatingDBTX) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	tag, err := t.db.Exec(ctx, sql, args...)
+	return tag, translateError(err)
+}
+
+func (t translatingDBTX) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	rows, err := t.db.Query(ctx, sql, args...)
+	if err != nil {
+		return rows, translateError(err)
+	}
+	return translatingRows{Rows: rows}, nil
+}
+
+func (t translatingDBTX) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return translatingRow{row: t.db.QueryRow(ctx, sql, args...)}
+}
+
+type translatingRow struct {
+	row pgx.Row
+}
+
+func (r translatingRow) Scan(dest ...any) error {
+	return translateError(r.row.Scan(dest...))
+}
+
+type translatingRows struct {
+	pgx.Rows
+}
+
+func (r translatingRows) Scan(dest ...any) error {
+	return translateError(r.Rows.Scan(dest...))
+}
+
+func (r translatingRows) Err() error {
+	return translateError(r.Rows.Err())
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/niloy104/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrRecordNotFound(t *testing.T) {
+	store := NewStore(testDB)
+
+	_, err := store.GetAccount(context.Background(), -1)
+	require.ErrorIs(t, err, ErrRecordNotFound)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	// errors of queries run in a transaction are translated too
+	_, err = store.ReleaseHoldTx(context.Background(), -1)
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+func TestErrUniqueViolation(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+
+	_, err := store.CreateUser(context.Background(), CreateUserParams{
+		Username:       user.Username,
+		HashedPassword: user.HashedPassword,
+		FullName:       user.FullName,
+		Email:          util.RandomEmail(),
+	})
+	require.ErrorIs(t, err, ErrUniqueViolation)
+	require.NotErrorIs(t, err, ErrForeignKeyViolation)
+
+	// the error of Postgres stays available
+	var pgErr *pgconn.PgError
+	require.True(t, errors.As(err, &pgErr))
+	require.Equal(t, "users_pkey", pgErr.ConstraintName)
+
+	account := createRandomAccount(t)
+	_, err = store.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    account.Owner,
+		Balance:  0,
+		Currency: account.Currency,
+	})
+	require.ErrorIs(t, err, ErrUniqueViolation)
+}
+
+func TestErrForeignKeyViolation(t *testing.T) {
+	store := NewStore(testDB)
+
+	_, err := store.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    util.RandomOwner(),
+		Balance:  0,
+		Currency: util.USD,
+	})
+	require.ErrorIs(t, err, ErrForeignKeyViolation)
+	require.NotErrorIs(t, err, ErrUniqueViolation)
+}
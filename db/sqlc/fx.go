@@ -0,0 +1,210 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	// ErrInvalidFXQuote is returned when a quote does not exist, has expired, was already used
+	// or does not match the user and currencies of the transfer
+	ErrInvalidFXQuote = errors.New("invalid or expired fx quote")
+
+	// ErrFXAmountTooSmall is returned when the converted amount rounds down to zero
+	ErrFXAmountTooSmall = errors.New("amount is too small to convert")
+)
+
+// FXTransferTxParams contains the input parameters of the cross-currency transfer transaction
+type FXTransferTxParams struct {
+	FromAccountID int64     `json:"from_account_id"`
+	ToAccountID   int64     `json:"to_account_id"`
+	Amount        int64     `json:"amount"`
+	QuoteID       uuid.UUID `json:"quote_id"`
+	Username      string    `json:"username"`
+}
+
+// IdempotentFXTransferTxParams contains the input parameters of the idempotent cross-currency transfer transaction
+type IdempotentFXTransferTxParams struct {
+	FXTransferTxParams
+	Idempotency IdempotencyParams `json:"idempotency"`
+}
+
+// FXTransferTx debits Amount in the source currency and credits the destination account in its own currency,
+// at the rate locked by the quote. Money goes through the FX clearing account of each currency,
+// so the entries of every currency stay balanced. The quote can only be used once.
+func (store *SQLStore) FXTransferTx(ctx context.Context, arg FXTransferTxParams) (TransferTxResult, error) {
+	var result TransferTxResult
+
+	err := store.execTransferTx(ctx, func(q *Queries) error {
+		var err error
+		result, err = fxTransfer(ctx, q, arg)
+		return err
+	})
+
+	return result, err
+}
+
+// IdempotentFXTransferTx performs a cross-currency transfer at most once per idempotency key.
+// It returns true if the result is a replay of an earlier transfer with the same key.
+func (store *SQLStore) IdempotentFXTransferTx(ctx context.Context, arg IdempotentFXTransferTxParams) (TransferTxResult, bool, error) {
+	var result TransferTxResult
+
+	replayed, err := store.idempotentTx(ctx, arg.Idempotency, &result, func(q *Queries) error {
+		var err error
+		result, err = fxTransfer(ctx, q, arg.FXTransferTxParams)
+		return err
+	}, withIsolation(store.transferIsolation))
+
+	return result, replayed, err
+}
+
+// fxTransfer moves money between accounts in different currencies using the queries of an open transaction
+func fxTransfer(ctx context.Context, q *Queries, arg FXTransferTxParams) (TransferTxResult, error) {
+	var result TransferTxResult
+
+	fromAccount, err := q.GetAccount(ctx, arg.FromAccountID)
+	if err != nil {
+		return result, err
+	}
+
+	toAccount, err := q.GetAccount(ctx, arg.ToAccountID)
+	if err != nil {
+		return result, err
+	}
+
+	quote, err := q.UseFXQuote(ctx, UseFXQuoteParams{
+		ID:           arg.QuoteID,
+		Username:     arg.Username,
+		FromCurrency: fromAccount.Currency,
+		ToCurrency:   toAccount.Currency,
+		Amount:       arg.Amount,
+	})
+	if err != nil {
+		if errors.Is(err, ErrRecordNotFound) {
+			return result, ErrInvalidFXQuote
+		}
+		return result, err
+	}
+
+	if quote.ToAmount <= 0 {
+		return result, ErrFXAmountTooSmall
+	}
+
+	fromClearing, err := q.GetFXClearingAccount(ctx, fromAccount.Currency)
+	if err != nil {
+		return result, fmt.Errorf("cannot get %s fx clearing account: %w", fromAccount.Currency, err)
+	}
+
+	toClearing, err := q.GetFXClearingAccount(ctx, toAccount.Currency)
+	if err != nil {
+		return result, fmt.Errorf("cannot get %s fx clearing account: %w", toAccount.Currency, err)
+	}
+
+	result.Transfer, err = q.CreateFXTransfer(ctx, CreateFXTransferParams{
+		FromAccountID: pgtype.Int8{Int64: arg.FromAccountID, Valid: true},
+		ToAccountID:   pgtype.Int8{Int64: arg.ToAccountID, Valid: true},
+		Amount:        arg.Amount,
+		ToAmount:      pgtype.Int8{Int64: quote.ToAmount, Valid: true},
+		FxRate:        quote.Rate,
+		QuoteID:       uuid.NullUUID{UUID: quote.ID, Valid: true},
+	})
+	if err != nil {
+		return result, err
+	}
+
+	accounts, err := addBalances(ctx, q,
+		balanceChange{accountID: arg.FromAccountID, amount: -arg.Amount},
+		balanceChange{accountID: fromClearing.ID, amount: arg.Amount},
+		balanceChange{accountID: toClearing.ID, amount: -quote.ToAmount},
+		balanceChange{accountID: arg.ToAccountID, amount: quote.ToAmount},
+	)
+	if err != nil {
+		if isCheckViolation(err, accountBalanceConstraint) {
+			return result, fmt.Errorf("account [%d]: %w", arg.FromAccountID, ErrInsufficientFunds)
+		}
+		return result, err
+	}
+
+	result.FromEntry, err = q.CreateEntry(ctx, CreateEntryParams{
+		AccountID:    arg.FromAccountID,
+		Amount:       -arg.Amount,
+		BalanceAfter: accounts[arg.FromAccountID].Balance,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	_, err = q.CreateEntry(ctx, CreateEntryParams{
+		AccountID:    fromClearing.ID,
+		Amount:       arg.Amount,
+		BalanceAfter: accounts[fromClearing.ID].Balance,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	_, err = q.CreateEntry(ctx, CreateEntryParams{
+		AccountID:    toClearing.ID,
+		Amount:       -quote.ToAmount,
+		BalanceAfter: accounts[toClearing.ID].Balance,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	result.ToEntry, err = q.CreateEntry(ctx, CreateEntryParams{
+		AccountID:    arg.ToAccountID,
+		Amount:       quote.ToAmount,
+		BalanceAfter: accounts[arg.ToAccountID].Balance,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	result.Transfer, err = q.SetTransferBalancesAfter(ctx, SetTransferBalancesAfterParams{
+		ID:               result.Transfer.ID,
+		FromBalanceAfter: pgtype.Int8{Int64: accounts[arg.FromAccountID].Balance, Valid: true},
+		ToBalanceAfter:   pgtype.Int8{Int64: accounts[arg.ToAccountID].Balance, Valid: true},
+	})
+	if err != nil {
+		return result, err
+	}
+
+	result.FromAccount = accounts[arg.FromAccountID]
+	result.ToAccount = accounts[arg.ToAccountID]
+	return result, nil
+}
+
+// balanceChange is an amount to add to the balance of an account
+type balanceChange struct {
+	accountID int64
+	amount    int64
+}
+
+// addBalances applies the changes in account ID order, so concurrent transactions
+// lock the accounts in the same order and cannot deadlock
+func addBalances(ctx context.Context, q *Queries, changes ...balanceChange) (map[int64]Account, error) {
+	slices.SortFunc(changes, func(a, b balanceChange) int {
+		return cmp.Compare(a.accountID, b.accountID)
+	})
+
+	accounts := make(map[int64]Account, len(changes))
+	for _, change := range changes {
+		account, err := q.AddAccountBalance(ctx, AddAccountBalanceParams{
+			ID:     change.accountID,
+			Amount: change.amount,
+		})
+		if err != nil {
+			return nil, err
+		}
+		accounts[change.accountID] = account
+	}
+
+	return accounts, nil
+}
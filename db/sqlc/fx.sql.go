@@ -0,0 +1,163 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: fx.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createFXQuote = `-- name: CreateFXQuote :one
+INSERT INTO fx_quotes (id, username, from_currency, to_currency, rate, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, username, from_currency, to_currency, rate, expires_at, used_at, created_at
+`
+
+type CreateFXQuoteParams struct {
+	ID           uuid.UUID          `json:"id"`
+	Username     string             `json:"username"`
+	FromCurrency string             `json:"from_currency"`
+	ToCurrency   string             `json:"to_currency"`
+	Rate         pgtype.Numeric     `json:"rate"`
+	ExpiresAt    pgtype.Timestamptz `json:"expires_at"`
+}
+
+func (q *Queries) CreateFXQuote(ctx context.Context, arg CreateFXQuoteParams) (FxQuote, error) {
+	row := q.db.QueryRow(ctx, createFXQuote,
+		arg.ID,
+		arg.Username,
+		arg.FromCurrency,
+		arg.ToCurrency,
+		arg.Rate,
+		arg.ExpiresAt,
+	)
+	var i FxQuote
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.FromCurrency,
+		&i.ToCurrency,
+		&i.Rate,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getFXQuote = `-- name: GetFXQuote :one
+SELECT id, username, from_currency, to_currency, rate, expires_at, used_at, created_at FROM fx_quotes
+WHERE id = $1
+LIMIT 1
+`
+
+func (q *Queries) GetFXQuote(ctx context.Context, id uuid.UUID) (FxQuote, error) {
+	row := q.db.QueryRow(ctx, getFXQuote, id)
+	var i FxQuote
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.FromCurrency,
+		&i.ToCurrency,
+		&i.Rate,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getFXRate = `-- name: GetFXRate :one
+SELECT from_currency, to_currency, rate, updated_at FROM fx_rates
+WHERE from_currency = $1 AND to_currency = $2
+LIMIT 1
+`
+
+type GetFXRateParams struct {
+	FromCurrency string `json:"from_currency"`
+	ToCurrency   string `json:"to_currency"`
+}
+
+func (q *Queries) GetFXRate(ctx context.Context, arg GetFXRateParams) (FxRate, error) {
+	row := q.db.QueryRow(ctx, getFXRate, arg.FromCurrency, arg.ToCurrency)
+	var i FxRate
+	err := row.Scan(
+		&i.FromCurrency,
+		&i.ToCurrency,
+		&i.Rate,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const upsertFXRate = `-- name: UpsertFXRate :one
+INSERT INTO fx_rates (from_currency, to_currency, rate)
+VALUES ($1, $2, $3)
+ON CONFLICT (from_currency, to_currency)
+DO UPDATE SET rate = EXCLUDED.rate, updated_at = now()
+RETURNING from_currency, to_currency, rate, updated_at
+`
+
+type UpsertFXRateParams struct {
+	FromCurrency string         `json:"from_currency"`
+	ToCurrency   string         `json:"to_currency"`
+	Rate         pgtype.Numeric `json:"rate"`
+}
+
+func (q *Queries) UpsertFXRate(ctx context.Context, arg UpsertFXRateParams) (FxRate, error) {
+	row := q.db.QueryRow(ctx, upsertFXRate, arg.FromCurrency, arg.ToCurrency, arg.Rate)
+	var i FxRate
+	err := row.Scan(
+		&i.FromCurrency,
+		&i.ToCurrency,
+		&i.Rate,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const useFXQuote = `-- name: UseFXQuote :one
+UPDATE fx_quotes
+SET used_at = now()
+WHERE
+  id = $1 AND
+  username = $2 AND
+  from_currency = $3 AND
+  to_currency = $4 AND
+  used_at IS NULL AND
+  expires_at > now()
+RETURNING id, rate, floor($5::bigint * rate)::bigint AS to_amount
+`
+
+type UseFXQuoteParams struct {
+	ID           uuid.UUID `json:"id"`
+	Username     string    `json:"username"`
+	FromCurrency string    `json:"from_currency"`
+	ToCurrency   string    `json:"to_currency"`
+	Amount       int64     `json:"amount"`
+}
+
+type UseFXQuoteRow struct {
+	ID       uuid.UUID      `json:"id"`
+	Rate     pgtype.Numeric `json:"rate"`
+	ToAmount int64          `json:"to_amount"`
+}
+
+// marks an unexpired quote as used and converts the amount at its rate, rounding down
+func (q *Queries) UseFXQuote(ctx context.Context, arg UseFXQuoteParams) (UseFXQuoteRow, error) {
+	row := q.db.QueryRow(ctx, useFXQuote,
+		arg.ID,
+		arg.Username,
+		arg.FromCurrency,
+		arg.ToCurrency,
+		arg.Amount,
+	)
+	var i UseFXQuoteRow
+	err := row.Scan(&i.ID, &i.Rate, &i.ToAmount)
+	return i, err
+}
@@ -0,0 +1,244 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/niloy104/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createAccountInCurrency(t *testing.T, currency string, balance int64) Account {
+	user := createRandomUser(t)
+
+	account, err := testQueries.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    user.Username,
+		Balance:  balance,
+		Currency: currency,
+	})
+	require.NoError(t, err)
+	return account
+}
+
+func createRandomFXQuote(t *testing.T, username, from, to, rate string, expiresAt time.Time) FxQuote {
+	var numeric pgtype.Numeric
+	require.NoError(t, numeric.Scan(rate))
+
+	quote, err := testQueries.CreateFXQuote(context.Background(), CreateFXQuoteParams{
+		ID:           uuid.New(),
+		Username:     username,
+		FromCurrency: from,
+		ToCurrency:   to,
+		Rate:         numeric,
+		ExpiresAt:    pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, username, quote.Username)
+	require.False(t, quote.UsedAt.Valid)
+	return quote
+}
+
+func TestUpsertFXRate(t *testing.T) {
+	var rate pgtype.Numeric
+	require.NoError(t, rate.Scan("1.25"))
+
+	arg := UpsertFXRateParams{
+		FromCurrency: util.EUR,
+		ToCurrency:   util.BDT,
+		Rate:         rate,
+	}
+	_, err := testQueries.UpsertFXRate(context.Background(), arg)
+	require.NoError(t, err)
+
+	require.NoError(t, arg.Rate.Scan("130.5"))
+	updated, err := testQueries.UpsertFXRate(context.Background(), arg)
+	require.NoError(t, err)
+
+	got, err := testQueries.GetFXRate(context.Background(), GetFXRateParams{
+		FromCurrency: util.EUR,
+		ToCurrency:   util.BDT,
+	})
+	require.NoError(t, err)
+	require.Equal(t, updated.Rate, got.Rate)
+
+	value, err := got.Rate.Value()
+	require.NoError(t, err)
+	require.Equal(t, "130.50000000", value)
+}
+
+func TestUseFXQuote(t *testing.T) {
+	user := createRandomUser(t)
+	quote := createRandomFXQuote(t, user.Username, util.USD, util.EUR, "0.92", time.Now().Add(time.Minute))
+
+	arg := UseFXQuoteParams{
+		ID:           quote.ID,
+		Username:     user.Username,
+		FromCurrency: util.USD,
+		ToCurrency:   util.EUR,
+		Amount:       105,
+	}
+
+	// a quote of another user or for other currencies cannot be used
+	_, err := testQueries.UseFXQuote(context.Background(), UseFXQuoteParams{
+		ID:           quote.ID,
+		Username:     createRandomUser(t).Username,
+		FromCurrency: util.USD,
+		ToCurrency:   util.EUR,
+		Amount:       105,
+	})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	_, err = testQueries.UseFXQuote(context.Background(), UseFXQuoteParams{
+		ID:           quote.ID,
+		Username:     user.Username,
+		FromCurrency: util.USD,
+		ToCurrency:   util.BDT,
+		Amount:       105,
+	})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	row, err := testQueries.UseFXQuote(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, quote.ID, row.ID)
+	require.Equal(t, int64(96), row.ToAmount) // floor(105 * 0.92)
+
+	// the quote can only be used once
+	_, err = testQueries.UseFXQuote(context.Background(), arg)
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	expired := createRandomFXQuote(t, user.Username, util.USD, util.EUR, "0.92", time.Now().Add(-time.Second))
+	arg.ID = expired.ID
+	_, err = testQueries.UseFXQuote(context.Background(), arg)
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+func TestFXTransferTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	fromAccount := createAccountInCurrency(t, util.USD, transferTestBalance)
+	toAccount := createAccountInCurrency(t, util.BDT, 0)
+	quote := createRandomFXQuote(t, fromAccount.Owner, util.USD, util.BDT, "117.5", time.Now().Add(time.Minute))
+
+	amount := int64(10)
+	toAmount := int64(1175)
+
+	usdClearing, err := store.GetFXClearingAccount(context.Background(), util.USD)
+	require.NoError(t, err)
+	bdtClearing, err := store.GetFXClearingAccount(context.Background(), util.BDT)
+	require.NoError(t, err)
+
+	arg := FXTransferTxParams{
+		FromAccountID: fromAccount.ID,
+		ToAccountID:   toAccount.ID,
+		Amount:        amount,
+		QuoteID:       quote.ID,
+		Username:      fromAccount.Owner,
+	}
+	result, err := store.FXTransferTx(context.Background(), arg)
+	require.NoError(t, err)
+
+	transfer := result.Transfer
+	require.Equal(t, amount, transfer.Amount)
+	require.Equal(t, toAmount, transfer.ToAmount.Int64)
+	require.Equal(t, quote.ID, transfer.QuoteID.UUID)
+	require.Equal(t, quote.Rate, transfer.FxRate)
+
+	require.Equal(t, -amount, result.FromEntry.Amount)
+	require.Equal(t, toAmount, result.ToEntry.Amount)
+	require.Equal(t, fromAccount.Balance-amount, result.FromAccount.Balance)
+	require.Equal(t, toAmount, result.ToAccount.Balance)
+
+	// every currency stays balanced through its clearing account
+	usdClearingAfter, err := store.GetAccount(context.Background(), usdClearing.ID)
+	require.NoError(t, err)
+	require.Equal(t, usdClearing.Balance+amount, usdClearingAfter.Balance)
+
+	bdtClearingAfter, err := store.GetAccount(context.Background(), bdtClearing.ID)
+	require.NoError(t, err)
+	require.Equal(t, bdtClearing.Balance-toAmount, bdtClearingAfter.Balance)
+
+	// the quote cannot be used twice
+	_, err = store.FXTransferTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrInvalidFXQuote)
+
+	account, err := store.GetAccount(context.Background(), fromAccount.ID)
+	require.NoError(t, err)
+	require.Equal(t, fromAccount.Balance-amount, account.Balance)
+}
+
+func TestFXTransferTxInvalidQuote(t *testing.T) {
+	store := NewStore(testDB)
+
+	fromAccount := createAccountInCurrency(t, util.USD, transferTestBalance)
+	toAccount := createAccountInCurrency(t, util.EUR, 0)
+
+	testCases := []struct {
+		name  string
+		quote FxQuote
+	}{
+		{
+			name:  "Expired",
+			quote: createRandomFXQuote(t, fromAccount.Owner, util.USD, util.EUR, "0.92", time.Now().Add(-time.Second)),
+		},
+		{
+			name:  "OtherUser",
+			quote: createRandomFXQuote(t, toAccount.Owner, util.USD, util.EUR, "0.92", time.Now().Add(time.Minute)),
+		},
+		{
+			name:  "OtherCurrency",
+			quote: createRandomFXQuote(t, fromAccount.Owner, util.USD, util.BDT, "117.5", time.Now().Add(time.Minute)),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := store.FXTransferTx(context.Background(), FXTransferTxParams{
+				FromAccountID: fromAccount.ID,
+				ToAccountID:   toAccount.ID,
+				Amount:        10,
+				QuoteID:       tc.quote.ID,
+				Username:      fromAccount.Owner,
+			})
+			require.ErrorIs(t, err, ErrInvalidFXQuote)
+		})
+	}
+}
+
+func TestFXTransferTxErrors(t *testing.T) {
+	store := NewStore(testDB)
+
+	fromAccount := createAccountInCurrency(t, util.USD, transferTestBalance)
+	toAccount := createAccountInCurrency(t, util.EUR, 0)
+
+	quote := createRandomFXQuote(t, fromAccount.Owner, util.USD, util.EUR, "0.5", time.Now().Add(time.Minute))
+	_, err := store.FXTransferTx(context.Background(), FXTransferTxParams{
+		FromAccountID: fromAccount.ID,
+		ToAccountID:   toAccount.ID,
+		Amount:        1,
+		QuoteID:       quote.ID,
+		Username:      fromAccount.Owner,
+	})
+	require.ErrorIs(t, err, ErrFXAmountTooSmall)
+
+	quote = createRandomFXQuote(t, fromAccount.Owner, util.USD, util.EUR, "0.5", time.Now().Add(time.Minute))
+	_, err = store.FXTransferTx(context.Background(), FXTransferTxParams{
+		FromAccountID: fromAccount.ID,
+		ToAccountID:   toAccount.ID,
+		Amount:        transferTestBalance + 1,
+		QuoteID:       quote.ID,
+		Username:      fromAccount.Owner,
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// a failed transfer does not consume the quote
+	got, err := store.GetFXQuote(context.Background(), quote.ID)
+	require.NoError(t, err)
+	require.False(t, got.UsedAt.Valid)
+
+	account, err := store.GetAccount(context.Background(), fromAccount.ID)
+	require.NoError(t, err)
+	require.Equal(t, fromAccount.Balance, account.Balance)
+}
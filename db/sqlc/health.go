@@ -0,0 +1,16 @@
+package db
+
+import "context"
+
+// Ping checks that the database accepts queries
+func (store *SQLStore) Ping(ctx context.Context) error {
+	_, err := store.db.Exec(ctx, "SELECT 1")
+	return err
+}
+
+// MigrationVersion returns the version of the last migration applied by golang-migrate,
+// and whether it failed halfway and left the schema dirty
+func (store *SQLStore) MigrationVersion(ctx context.Context) (version int64, dirty bool, err error) {
+	err = store.db.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	return version, dirty, translateError(err)
+}
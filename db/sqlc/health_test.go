@@ -0,0 +1,26 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/niloy104/simplebank/db/migration"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPing(t *testing.T) {
+	store := NewStore(testDB)
+	require.NoError(t, store.Ping(context.Background()))
+}
+
+func TestMigrationVersion(t *testing.T) {
+	store := NewStore(testDB)
+
+	version, dirty, err := store.MigrationVersion(context.Background())
+	require.NoError(t, err)
+	require.False(t, dirty)
+
+	latest, err := migration.LatestVersion()
+	require.NoError(t, err)
+	require.Equal(t, latest, version)
+}
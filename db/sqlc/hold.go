@@ -0,0 +1,257 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// statuses of a hold
+const (
+	HoldStatusPending  = "pending"
+	HoldStatusCaptured = "captured"
+	HoldStatusReleased = "released"
+	HoldStatusExpired  = "expired"
+)
+
+var (
+	// ErrHoldNotPending is returned when a hold was already captured, released or expired
+	ErrHoldNotPending = errors.New("hold is not pending")
+
+	// ErrHoldExpired is returned when capturing a hold past its expiry time
+	ErrHoldExpired = errors.New("hold has expired")
+
+	// ErrCaptureTooLarge is returned when a capture is larger than the held amount
+	ErrCaptureTooLarge = errors.New("capture amount exceeds the held amount")
+
+	// ErrInvalidCaptureAmount is returned for a negative capture amount, which would move money the wrong way
+	ErrInvalidCaptureAmount = errors.New("capture amount must not be negative")
+
+	// ErrCurrencyMismatch is returned when a hold is placed between accounts in different currencies
+	ErrCurrencyMismatch = errors.New("accounts have different currencies")
+)
+
+// PlaceHoldTxParams contains the input parameters of the place hold transaction
+type PlaceHoldTxParams struct {
+	AccountID   int64     `json:"account_id"`
+	ToAccountID int64     `json:"to_account_id"`
+	Amount      int64     `json:"amount"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// HoldTxResult is the result of the place, release and expire hold transactions
+type HoldTxResult struct {
+	Hold    Hold    `json:"hold"`
+	Account Account `json:"account"`
+}
+
+// CaptureHoldTxParams contains the input parameters of the capture hold transaction.
+// Amount is less than the held amount for a partial capture, or zero to capture all of it.
+type CaptureHoldTxParams struct {
+	HoldID int64 `json:"hold_id"`
+	Amount int64 `json:"amount"`
+}
+
+// CaptureHoldTxResult is the result of the capture hold transaction
+type CaptureHoldTxResult struct {
+	TransferTxResult
+	Hold Hold `json:"hold"`
+}
+
+// PlaceHoldTx reserves funds of an account for a later transfer to another account.
+// Held funds stay in the ledger balance but leave the available balance until the hold is settled.
+// Both accounts must be in the same currency and neither may be frozen or a system account,
+// as for a transfer between them.
+func (store *SQLStore) PlaceHoldTx(ctx context.Context, arg PlaceHoldTxParams) (HoldTxResult, error) {
+	var result HoldTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		// lock both accounts in ID order before checking them, as the transfer does
+		accounts, err := lockAccounts(ctx, q, arg.AccountID, arg.ToAccountID)
+		if err != nil {
+			return err
+		}
+
+		account, toAccount := accounts[arg.AccountID], accounts[arg.ToAccountID]
+		if account.Currency != toAccount.Currency {
+			return fmt.Errorf("account [%d] in %s and account [%d] in %s: %w",
+				account.ID, account.Currency, toAccount.ID, toAccount.Currency, ErrCurrencyMismatch)
+		}
+		for _, account := range []Account{account, toAccount} {
+			if account.IsSystem {
+				return fmt.Errorf("account [%d]: %w", account.ID, ErrSystemAccount)
+			}
+			if account.IsFrozen {
+				return fmt.Errorf("account [%d]: %w", account.ID, ErrAccountFrozen)
+			}
+		}
+
+		result.Hold, err = q.CreateHold(ctx, CreateHoldParams{
+			AccountID:   arg.AccountID,
+			ToAccountID: arg.ToAccountID,
+			Amount:      arg.Amount,
+			ExpiresAt:   pgtype.Timestamptz{Time: arg.ExpiresAt, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Account, err = q.AddAccountHeldAmount(ctx, AddAccountHeldAmountParams{
+			ID:     arg.AccountID,
+			Amount: arg.Amount,
+		})
+		if err != nil {
+			if isCheckViolation(err, accountBalanceConstraint) {
+				return fmt.Errorf("account [%d]: %w", arg.AccountID, ErrInsufficientFunds)
+			}
+			return err
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+// CaptureHoldTx settles a pending hold with a transfer of the captured amount.
+// A partial capture releases the rest of the held amount; a hold is captured at most once.
+func (store *SQLStore) CaptureHoldTx(ctx context.Context, arg CaptureHoldTxParams) (CaptureHoldTxResult, error) {
+	var result CaptureHoldTxResult
+
+	if arg.Amount < 0 {
+		return result, fmt.Errorf("hold [%d]: %w", arg.HoldID, ErrInvalidCaptureAmount)
+	}
+
+	err := store.execTransferTx(ctx, func(q *Queries) error {
+		hold, err := q.GetHoldForUpdate(ctx, arg.HoldID)
+		if err != nil {
+			return err
+		}
+
+		if hold.Status != HoldStatusPending {
+			return fmt.Errorf("hold [%d] is %s: %w", hold.ID, hold.Status, ErrHoldNotPending)
+		}
+		if !hold.ExpiresAt.Time.After(time.Now()) {
+			return fmt.Errorf("hold [%d]: %w", hold.ID, ErrHoldExpired)
+		}
+
+		amount := arg.Amount
+		if amount == 0 {
+			amount = hold.Amount
+		}
+		if amount > hold.Amount {
+			return fmt.Errorf("hold [%d] of %d: %w", hold.ID, hold.Amount, ErrCaptureTooLarge)
+		}
+
+		// lock both accounts in ID order before touching them, as the transfer does
+		if _, err := lockAccounts(ctx, q, hold.AccountID, hold.ToAccountID); err != nil {
+			return err
+		}
+
+		_, err = q.AddAccountHeldAmount(ctx, AddAccountHeldAmountParams{
+			ID:     hold.AccountID,
+			Amount: -hold.Amount,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.TransferTxResult, err = transfer(ctx, q, TransferTxParams{
+			FromAccountID: hold.AccountID,
+			ToAccountID:   hold.ToAccountID,
+			Amount:        amount,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Hold, err = q.SetHoldStatus(ctx, SetHoldStatusParams{
+			ID:             hold.ID,
+			Status:         HoldStatusCaptured,
+			CapturedAmount: pgtype.Int8{Int64: amount, Valid: true},
+			TransferID:     pgtype.Int8{Int64: result.Transfer.ID, Valid: true},
+		})
+		return err
+	})
+
+	return result, err
+}
+
+// ReleaseHoldTx cancels a pending hold, making its funds available again
+func (store *SQLStore) ReleaseHoldTx(ctx context.Context, holdID int64) (HoldTxResult, error) {
+	var result HoldTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		hold, err := q.GetHoldForUpdate(ctx, holdID)
+		if err != nil {
+			return err
+		}
+
+		result, err = settleHold(ctx, q, hold, HoldStatusReleased)
+		return err
+	})
+
+	return result, err
+}
+
+// ExpireHoldTx releases the earliest pending hold that expired at now.
+// Holds being expired by other replicas are skipped, so each hold is expired once.
+// It returns ErrRecordNotFound when no hold has expired.
+func (store *SQLStore) ExpireHoldTx(ctx context.Context, now time.Time) (HoldTxResult, error) {
+	var result HoldTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		hold, err := q.ClaimExpiredHold(ctx, pgtype.Timestamptz{Time: now, Valid: true})
+		if err != nil {
+			return err
+		}
+
+		result, err = settleHold(ctx, q, hold, HoldStatusExpired)
+		return err
+	})
+
+	return result, err
+}
+
+// settleHold ends a locked pending hold without a transfer and gives its funds back to the account
+func settleHold(ctx context.Context, q *Queries, hold Hold, status string) (HoldTxResult, error) {
+	var result HoldTxResult
+
+	if hold.Status != HoldStatusPending {
+		return result, fmt.Errorf("hold [%d] is %s: %w", hold.ID, hold.Status, ErrHoldNotPending)
+	}
+
+	var err error
+	result.Hold, err = q.SetHoldStatus(ctx, SetHoldStatusParams{
+		ID:     hold.ID,
+		Status: status,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	result.Account, err = q.AddAccountHeldAmount(ctx, AddAccountHeldAmountParams{
+		ID:     hold.AccountID,
+		Amount: -hold.Amount,
+	})
+	return result, err
+}
+
+// lockAccounts locks the accounts in ID order, so concurrent transactions cannot deadlock on them.
+// It returns the locked accounts by ID.
+func lockAccounts(ctx context.Context, q *Queries, accountIDs ...int64) (map[int64]Account, error) {
+	slices.Sort(accountIDs)
+	accounts := make(map[int64]Account, len(accountIDs))
+	for _, accountID := range slices.Compact(accountIDs) {
+		account, err := q.GetAccountForUpdate(ctx, accountID)
+		if err != nil {
+			return nil, err
+		}
+		accounts[accountID] = account
+	}
+	return accounts, nil
+}
@@ -0,0 +1,259 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/niloy104/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func placeRandomHold(t *testing.T, store Store, account, toAccount Account, amount int64, expiresAt time.Time) HoldTxResult {
+	result, err := store.PlaceHoldTx(context.Background(), PlaceHoldTxParams{
+		AccountID:   account.ID,
+		ToAccountID: toAccount.ID,
+		Amount:      amount,
+		ExpiresAt:   expiresAt,
+	})
+	require.NoError(t, err)
+
+	require.NotZero(t, result.Hold.ID)
+	require.Equal(t, account.ID, result.Hold.AccountID)
+	require.Equal(t, toAccount.ID, result.Hold.ToAccountID)
+	require.Equal(t, amount, result.Hold.Amount)
+	require.Equal(t, HoldStatusPending, result.Hold.Status)
+	require.WithinDuration(t, expiresAt, result.Hold.ExpiresAt.Time, time.Millisecond)
+
+	return result
+}
+
+func TestPlaceHoldTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account := createRandomAccountWithBalance(t, transferTestBalance)
+	toAccount := createAccountInCurrency(t, account.Currency, 0)
+	amount := int64(600)
+
+	result := placeRandomHold(t, store, account, toAccount, amount, time.Now().Add(time.Hour))
+
+	// held funds stay in the ledger balance but are no longer available
+	require.Equal(t, account.Balance, result.Account.Balance)
+	require.Equal(t, amount, result.Account.HeldAmount)
+	require.Equal(t, account.Balance-amount, result.Account.AvailableBalance)
+
+	_, err := store.PlaceHoldTx(context.Background(), PlaceHoldTxParams{
+		AccountID:   account.ID,
+		ToAccountID: toAccount.ID,
+		Amount:      account.Balance - amount + 1,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	_, err = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account.ID,
+		ToAccountID:   toAccount.ID,
+		Amount:        account.Balance - amount + 1,
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+}
+
+func TestPlaceHoldTxChecksAccounts(t *testing.T) {
+	store := NewStore(testDB)
+
+	account := createAccountInCurrency(t, util.USD, transferTestBalance)
+	frozenAccount := createAccountInCurrency(t, util.USD, 0)
+	_, err := store.SetAccountFrozen(context.Background(), SetAccountFrozenParams{
+		ID:       frozenAccount.ID,
+		IsFrozen: true,
+	})
+	require.NoError(t, err)
+
+	cashAccount, err := store.GetCashAccount(context.Background(), util.USD)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		toAccountID int64
+		err         error
+	}{
+		{
+			name:        "CurrencyMismatch",
+			toAccountID: createAccountInCurrency(t, util.EUR, 0).ID,
+			err:         ErrCurrencyMismatch,
+		},
+		{
+			name:        "FrozenAccount",
+			toAccountID: frozenAccount.ID,
+			err:         ErrAccountFrozen,
+		},
+		{
+			name:        "SystemAccount",
+			toAccountID: cashAccount.ID,
+			err:         ErrSystemAccount,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := store.PlaceHoldTx(context.Background(), PlaceHoldTxParams{
+				AccountID:   account.ID,
+				ToAccountID: tc.toAccountID,
+				Amount:      10,
+				ExpiresAt:   time.Now().Add(time.Hour),
+			})
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+
+	// no funds were reserved by the rejected holds
+	updated, err := store.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Zero(t, updated.HeldAmount)
+}
+
+func TestCaptureHoldTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account := createRandomAccountWithBalance(t, transferTestBalance)
+	toAccount := createAccountInCurrency(t, account.Currency, 0)
+	amount := int64(300)
+
+	placed := placeRandomHold(t, store, account, toAccount, amount, time.Now().Add(time.Hour))
+
+	result, err := store.CaptureHoldTx(context.Background(), CaptureHoldTxParams{
+		HoldID: placed.Hold.ID,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, HoldStatusCaptured, result.Hold.Status)
+	require.Equal(t, amount, result.Hold.CapturedAmount)
+	require.Equal(t, result.Transfer.ID, result.Hold.TransferID.Int64)
+
+	require.Equal(t, account.ID, result.Transfer.FromAccountID.Int64)
+	require.Equal(t, toAccount.ID, result.Transfer.ToAccountID.Int64)
+	require.Equal(t, amount, result.Transfer.Amount)
+
+	require.Equal(t, account.Balance-amount, result.FromAccount.Balance)
+	require.Zero(t, result.FromAccount.HeldAmount)
+	require.Equal(t, account.Balance-amount, result.FromAccount.AvailableBalance)
+	require.Equal(t, amount, result.ToAccount.Balance)
+
+	// a hold is captured once
+	_, err = store.CaptureHoldTx(context.Background(), CaptureHoldTxParams{
+		HoldID: placed.Hold.ID,
+	})
+	require.ErrorIs(t, err, ErrHoldNotPending)
+}
+
+func TestCaptureHoldTxPartial(t *testing.T) {
+	store := NewStore(testDB)
+
+	account := createRandomAccountWithBalance(t, transferTestBalance)
+	toAccount := createAccountInCurrency(t, account.Currency, 0)
+
+	placed := placeRandomHold(t, store, account, toAccount, 300, time.Now().Add(time.Hour))
+
+	_, err := store.CaptureHoldTx(context.Background(), CaptureHoldTxParams{
+		HoldID: placed.Hold.ID,
+		Amount: 301,
+	})
+	require.ErrorIs(t, err, ErrCaptureTooLarge)
+
+	// the rest of the held amount is released
+	result, err := store.CaptureHoldTx(context.Background(), CaptureHoldTxParams{
+		HoldID: placed.Hold.ID,
+		Amount: 120,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(120), result.Hold.CapturedAmount)
+	require.Equal(t, int64(120), result.Transfer.Amount)
+	require.Equal(t, account.Balance-120, result.FromAccount.Balance)
+	require.Zero(t, result.FromAccount.HeldAmount)
+	require.Equal(t, int64(120), result.ToAccount.Balance)
+}
+
+func TestCaptureHoldTxNegativeAmount(t *testing.T) {
+	store := NewStore(testDB)
+
+	account := createRandomAccountWithBalance(t, transferTestBalance)
+	toAccount := createAccountInCurrency(t, account.Currency, 0)
+
+	placed := placeRandomHold(t, store, account, toAccount, 300, time.Now().Add(time.Hour))
+
+	_, err := store.CaptureHoldTx(context.Background(), CaptureHoldTxParams{
+		HoldID: placed.Hold.ID,
+		Amount: -100,
+	})
+	require.ErrorIs(t, err, ErrInvalidCaptureAmount)
+
+	// nothing moved and the hold is still pending
+	hold, err := store.GetHold(context.Background(), placed.Hold.ID)
+	require.NoError(t, err)
+	require.Equal(t, HoldStatusPending, hold.Status)
+
+	updated, err := store.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, account.Balance, updated.Balance)
+	require.Equal(t, int64(300), updated.HeldAmount)
+
+	updated, err = store.GetAccount(context.Background(), toAccount.ID)
+	require.NoError(t, err)
+	require.Zero(t, updated.Balance)
+}
+
+func TestReleaseHoldTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account := createRandomAccountWithBalance(t, transferTestBalance)
+	toAccount := createAccountInCurrency(t, account.Currency, 0)
+
+	placed := placeRandomHold(t, store, account, toAccount, 300, time.Now().Add(time.Hour))
+
+	result, err := store.ReleaseHoldTx(context.Background(), placed.Hold.ID)
+	require.NoError(t, err)
+	require.Equal(t, HoldStatusReleased, result.Hold.Status)
+	require.Equal(t, account.Balance, result.Account.Balance)
+	require.Zero(t, result.Account.HeldAmount)
+	require.Equal(t, account.Balance, result.Account.AvailableBalance)
+
+	_, err = store.ReleaseHoldTx(context.Background(), placed.Hold.ID)
+	require.ErrorIs(t, err, ErrHoldNotPending)
+
+	_, err = store.CaptureHoldTx(context.Background(), CaptureHoldTxParams{
+		HoldID: placed.Hold.ID,
+	})
+	require.ErrorIs(t, err, ErrHoldNotPending)
+}
+
+func TestExpireHoldTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account := createRandomAccountWithBalance(t, transferTestBalance)
+	toAccount := createAccountInCurrency(t, account.Currency, 0)
+
+	placed := placeRandomHold(t, store, account, toAccount, 300, time.Now().Add(-time.Second))
+
+	_, err := store.CaptureHoldTx(context.Background(), CaptureHoldTxParams{
+		HoldID: placed.Hold.ID,
+	})
+	require.ErrorIs(t, err, ErrHoldExpired)
+
+	// holds left expired by other tests may be released first
+	for {
+		result, err := store.ExpireHoldTx(context.Background(), time.Now())
+		require.NoError(t, err)
+		if result.Hold.ID != placed.Hold.ID {
+			continue
+		}
+
+		require.Equal(t, HoldStatusExpired, result.Hold.Status)
+		require.Zero(t, result.Account.HeldAmount)
+		require.Equal(t, account.Balance, result.Account.AvailableBalance)
+		break
+	}
+
+	hold, err := store.GetHold(context.Background(), placed.Hold.ID)
+	require.NoError(t, err)
+	require.Equal(t, HoldStatusExpired, hold.Status)
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// ErrIdempotencyKeyReused is returned when an idempotency key is sent again with a different request
+var ErrIdempotencyKeyReused = errors.New("idempotency key already used for a different request")
+
+// IdempotencyParams identifies a request that must be executed at most once
+type IdempotencyParams struct {
+	Username    string `json:"username"`
+	Key         string `json:"key"`
+	RequestHash string `json:"request_hash"`
+}
+
+// IdempotentTransferTxParams contains the input parameters of the idempotent transfer transaction
+type IdempotentTransferTxParams struct {
+	TransferTxParams
+	Idempotency IdempotencyParams `json:"idempotency"`
+}
+
+// IdempotentCreateAccountTxParams contains the input parameters of the idempotent create account transaction
+type IdempotentCreateAccountTxParams struct {
+	CreateAccountParams
+	Idempotency IdempotencyParams `json:"idempotency"`
+}
+
+// IdempotentTransferTx performs a money transfer at most once per idempotency key.
+// It returns true if the result is a replay of an earlier transfer with the same key.
+func (store *SQLStore) IdempotentTransferTx(ctx context.Context, arg IdempotentTransferTxParams) (TransferTxResult, bool, error) {
+	var result TransferTxResult
+
+	replayed, err := store.idempotentTx(ctx, arg.Idempotency, &result, func(q *Queries) error {
+		var err error
+		result, err = transfer(ctx, q, arg.TransferTxParams)
+		return err
+	}, withIsolation(store.transferIsolation))
+
+	return result, replayed, err
+}
+
+// IdempotentCreateAccountTx creates an account at most once per idempotency key.
+// It returns true if the account is a replay of an earlier request with the same key.
+func (store *SQLStore) IdempotentCreateAccountTx(ctx context.Context, arg IdempotentCreateAccountTxParams) (Account, bool, error) {
+	var account Account
+
+	replayed, err := store.idempotentTx(ctx, arg.Idempotency, &account, func(q *Queries) error {
+		var err error
+		account, err = q.CreateAccount(ctx, arg.CreateAccountParams)
+		return err
+	})
+
+	return account, replayed, err
+}
+
+// idempotentTx runs fn and stores the JSON encoded response in the same transaction as the key.
+// A concurrent request with the same key blocks on the key insert until the first one commits,
+// then replays the stored response into response instead of running fn again.
+// If fn fails the key is rolled back with it, so the request can be retried.
+func (store *SQLStore) idempotentTx(ctx context.Context, arg IdempotencyParams, response any, fn func(*Queries) error, options ...txOption) (bool, error) {
+	replayed := false
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		_, err := q.CreateIdempotencyKey(ctx, CreateIdempotencyKeyParams{
+			Username:    arg.Username,
+			Key:         arg.Key,
+			RequestHash: arg.RequestHash,
+		})
+		if err == nil {
+			if err := fn(q); err != nil {
+				return err
+			}
+
+			body, err := json.Marshal(response)
+			if err != nil {
+				return err
+			}
+
+			return q.SetIdempotencyKeyResponse(ctx, SetIdempotencyKeyResponseParams{
+				Username:     arg.Username,
+				Key:          arg.Key,
+				ResponseBody: body,
+			})
+		}
+		if !errors.Is(err, ErrRecordNotFound) {
+			return err
+		}
+
+		// the key already exists: replay the stored response
+		key, err := q.GetIdempotencyKey(ctx, GetIdempotencyKeyParams{
+			Username: arg.Username,
+			Key:      arg.Key,
+		})
+		if err != nil {
+			return err
+		}
+
+		if key.RequestHash != arg.RequestHash {
+			return ErrIdempotencyKeyReused
+		}
+
+		replayed = true
+		return json.Unmarshal(key.ResponseBody, response)
+	}, options...)
+
+	return replayed, err
+}
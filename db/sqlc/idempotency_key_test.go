@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/niloy104/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomIdempotencyKey(t *testing.T, user User) IdempotencyKey {
+	arg := CreateIdempotencyKeyParams{
+		Username:    user.Username,
+		Key:         util.RandomString(16),
+		RequestHash: util.RandomString(64),
+	}
+
+	key, err := testQueries.CreateIdempotencyKey(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotEmpty(t, key)
+
+	require.Equal(t, arg.Username, key.Username)
+	require.Equal(t, arg.Key, key.Key)
+	require.Equal(t, arg.RequestHash, key.RequestHash)
+	require.Nil(t, key.ResponseBody)
+	require.NotZero(t, key.CreatedAt)
+
+	return key
+}
+
+func TestCreateIdempotencyKey(t *testing.T) {
+	user := createRandomUser(t)
+	key := createRandomIdempotencyKey(t, user)
+
+	// inserting the same key again returns no row
+	_, err := testQueries.CreateIdempotencyKey(context.Background(), CreateIdempotencyKeyParams{
+		Username:    key.Username,
+		Key:         key.Key,
+		RequestHash: util.RandomString(64),
+	})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	// keys are scoped per user
+	otherUser := createRandomUser(t)
+	_, err = testQueries.CreateIdempotencyKey(context.Background(), CreateIdempotencyKeyParams{
+		Username:    otherUser.Username,
+		Key:         key.Key,
+		RequestHash: key.RequestHash,
+	})
+	require.NoError(t, err)
+}
+
+func TestSetIdempotencyKeyResponse(t *testing.T) {
+	user := createRandomUser(t)
+	key := createRandomIdempotencyKey(t, user)
+
+	err := testQueries.SetIdempotencyKeyResponse(context.Background(), SetIdempotencyKeyResponseParams{
+		Username:     key.Username,
+		Key:          key.Key,
+		ResponseBody: []byte(`{"id":1}`),
+	})
+	require.NoError(t, err)
+
+	key2, err := testQueries.GetIdempotencyKey(context.Background(), GetIdempotencyKeyParams{
+		Username: key.Username,
+		Key:      key.Key,
+	})
+	require.NoError(t, err)
+	require.Equal(t, key.RequestHash, key2.RequestHash)
+	require.JSONEq(t, `{"id":1}`, string(key2.ResponseBody))
+}
+
+func TestDeleteIdempotencyKeysBefore(t *testing.T) {
+	user := createRandomUser(t)
+	key := createRandomIdempotencyKey(t, user)
+
+	err := testQueries.DeleteIdempotencyKeysBefore(context.Background(), pgtype.Timestamptz{
+		Time:  time.Now().Add(time.Minute),
+		Valid: true,
+	})
+	require.NoError(t, err)
+
+	_, err = testQueries.GetIdempotencyKey(context.Background(), GetIdempotencyKeyParams{
+		Username: key.Username,
+		Key:      key.Key,
+	})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
@@ -6,7 +6,6 @@ import (
 	"os"
 	"testing"
 
-	_ "github.com/lib/pq"
 	"github.com/niloy104/simplebank/util"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -28,7 +27,7 @@ func TestMain(m *testing.M) {
 	}
 
 	testDB = pool
-	testQueries = New(testDB)
+	testQueries = New(translateErrors(testDB))
 
 	code := m.Run()
 
@@ -5,6 +5,7 @@
 package db
 
 import (
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
@@ -14,6 +15,11 @@ type Account struct {
 	Balance   int64              `json:"balance"`
 	Currency  string             `json:"currency"`
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	IsFrozen  bool               `json:"is_frozen"`
+	IsSystem  bool               `json:"is_system"`
+	// total of the pending holds on the account
+	HeldAmount       int64 `json:"held_amount"`
+	AvailableBalance int64 `json:"available_balance"`
 }
 
 type Entry struct {
@@ -22,6 +28,100 @@ type Entry struct {
 	// can be negative or positive
 	Amount    int64              `json:"amount"`
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	// balance of the account right after the entry
+	BalanceAfter int64 `json:"balance_after"`
+}
+
+type FxQuote struct {
+	ID           uuid.UUID          `json:"id"`
+	Username     string             `json:"username"`
+	FromCurrency string             `json:"from_currency"`
+	ToCurrency   string             `json:"to_currency"`
+	Rate         pgtype.Numeric     `json:"rate"`
+	ExpiresAt    pgtype.Timestamptz `json:"expires_at"`
+	UsedAt       pgtype.Timestamptz `json:"used_at"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+}
+
+type FxRate struct {
+	FromCurrency string             `json:"from_currency"`
+	ToCurrency   string             `json:"to_currency"`
+	Rate         pgtype.Numeric     `json:"rate"`
+	UpdatedAt    pgtype.Timestamptz `json:"updated_at"`
+}
+
+type Hold struct {
+	ID             int64 `json:"id"`
+	AccountID      int64 `json:"account_id"`
+	ToAccountID    int64 `json:"to_account_id"`
+	Amount         int64 `json:"amount"`
+	CapturedAmount int64 `json:"captured_amount"`
+	// pending, captured, released or expired
+	Status string `json:"status"`
+	// the transfer made when the hold was captured
+	TransferID pgtype.Int8        `json:"transfer_id"`
+	ExpiresAt  pgtype.Timestamptz `json:"expires_at"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+}
+
+type IdempotencyKey struct {
+	Username string `json:"username"`
+	Key      string `json:"key"`
+	// sha256 of method, path and request body
+	RequestHash  string             `json:"request_hash"`
+	ResponseBody []byte             `json:"response_body"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+}
+
+// token buckets of the rate limiter shared by all replicas
+type RateLimitBucket struct {
+	Key       string             `json:"key"`
+	Tokens    float64            `json:"tokens"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+}
+
+type RevokedToken struct {
+	ID        uuid.UUID          `json:"id"`
+	Username  string             `json:"username"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	RevokedAt pgtype.Timestamptz `json:"revoked_at"`
+}
+
+type ScheduledTransfer struct {
+	ID            int64  `json:"id"`
+	Owner         string `json:"owner"`
+	FromAccountID int64  `json:"from_account_id"`
+	ToAccountID   int64  `json:"to_account_id"`
+	Amount        int64  `json:"amount"`
+	// standard 5-field cron expression, evaluated in UTC
+	CronExpression  pgtype.Text `json:"cron_expression"`
+	IntervalSeconds pgtype.Int8 `json:"interval_seconds"`
+	// null once a one-off transfer has run
+	NextRunAt pgtype.Timestamptz `json:"next_run_at"`
+	IsActive  bool               `json:"is_active"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type ScheduledTransferRun struct {
+	ID                  int64              `json:"id"`
+	ScheduledTransferID int64              `json:"scheduled_transfer_id"`
+	ScheduledAt         pgtype.Timestamptz `json:"scheduled_at"`
+	// succeeded or failed
+	Status     string             `json:"status"`
+	TransferID pgtype.Int8        `json:"transfer_id"`
+	Error      pgtype.Text        `json:"error"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+}
+
+type Session struct {
+	ID           uuid.UUID          `json:"id"`
+	Username     string             `json:"username"`
+	RefreshToken string             `json:"refresh_token"`
+	UserAgent    string             `json:"user_agent"`
+	ClientIp     string             `json:"client_ip"`
+	IsBlocked    bool               `json:"is_blocked"`
+	ExpiresAt    pgtype.Timestamptz `json:"expires_at"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
 }
 
 type Transfer struct {
@@ -31,6 +131,16 @@ type Transfer struct {
 	// must be positive
 	Amount    int64              `json:"amount"`
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	// amount credited in the destination currency of a cross-currency transfer
+	ToAmount pgtype.Int8    `json:"to_amount"`
+	FxRate   pgtype.Numeric `json:"fx_rate"`
+	QuoteID  uuid.NullUUID  `json:"quote_id"`
+	// the transfer this one fully or partially reverses
+	ReversalOf pgtype.Int8 `json:"reversal_of"`
+	// balance of the source account right after the transfer
+	FromBalanceAfter pgtype.Int8 `json:"from_balance_after"`
+	// balance of the destination account right after the transfer
+	ToBalanceAfter pgtype.Int8 `json:"to_balance_after"`
 }
 
 type User struct {
@@ -40,4 +150,11 @@ type User struct {
 	Email             string             `json:"email"`
 	PasswordChangedAt pgtype.Timestamptz `json:"password_changed_at"`
 	CreatedAt         pgtype.Timestamptz `json:"created_at"`
+	// tokens issued before this time are revoked
+	TokensRevokedAt pgtype.Timestamptz `json:"tokens_revoked_at"`
+	Role            string             `json:"role"`
+	// failed logins since the last successful one or the last lockout
+	FailedLoginAttempts int32 `json:"failed_login_attempts"`
+	// logins are refused until this time
+	LockedUntil pgtype.Timestamptz `json:"locked_until"`
 }
@@ -6,24 +6,90 @@ package db
 
 import (
 	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
 	AddAccountBalance(ctx context.Context, arg AddAccountBalanceParams) (Account, error)
+	AddAccountHeldAmount(ctx context.Context, arg AddAccountHeldAmountParams) (Account, error)
+	BlockSession(ctx context.Context, id uuid.UUID) error
+	BlockUserSessions(ctx context.Context, username string) error
+	// locks the earliest due scheduled transfer, skipping rows another replica is already running
+	ClaimDueScheduledTransfer(ctx context.Context, now pgtype.Timestamptz) (ScheduledTransfer, error)
+	// locks the earliest expired pending hold, skipping holds another replica is already expiring
+	ClaimExpiredHold(ctx context.Context, now pgtype.Timestamptz) (Hold, error)
 	CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error)
 	CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry, error)
+	CreateFXQuote(ctx context.Context, arg CreateFXQuoteParams) (FxQuote, error)
+	CreateFXTransfer(ctx context.Context, arg CreateFXTransferParams) (Transfer, error)
+	CreateHold(ctx context.Context, arg CreateHoldParams) (Hold, error)
+	CreateIdempotencyKey(ctx context.Context, arg CreateIdempotencyKeyParams) (IdempotencyKey, error)
+	CreateReversalTransfer(ctx context.Context, arg CreateReversalTransferParams) (Transfer, error)
+	CreateScheduledTransfer(ctx context.Context, arg CreateScheduledTransferParams) (ScheduledTransfer, error)
+	CreateScheduledTransferRun(ctx context.Context, arg CreateScheduledTransferRunParams) (ScheduledTransferRun, error)
+	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
 	CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
 	DeleteAccount(ctx context.Context, id int64) error
+	DeleteExpiredRevokedTokens(ctx context.Context) error
+	DeleteIdempotencyKeysBefore(ctx context.Context, createdAt pgtype.Timestamptz) error
+	DeleteRateLimitBuckets(ctx context.Context, before pgtype.Timestamptz) error
+	DeleteScheduledTransfer(ctx context.Context, id int64) error
 	GetAccount(ctx context.Context, id int64) (Account, error)
 	GetAccountForUpdate(ctx context.Context, id int64) (Account, error)
+	GetCashAccount(ctx context.Context, currency string) (Account, error)
 	GetEntry(ctx context.Context, id int64) (Entry, error)
+	GetFXClearingAccount(ctx context.Context, currency string) (Account, error)
+	GetFXQuote(ctx context.Context, id uuid.UUID) (FxQuote, error)
+	GetFXRate(ctx context.Context, arg GetFXRateParams) (FxRate, error)
+	GetHold(ctx context.Context, id int64) (Hold, error)
+	GetHoldForUpdate(ctx context.Context, id int64) (Hold, error)
+	GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error)
+	GetRateLimitBucket(ctx context.Context, key string) (RateLimitBucket, error)
+	// the total amount already moved back by reversals of the transfer
+	GetReversedAmount(ctx context.Context, reversalOf pgtype.Int8) (int64, error)
+	GetScheduledTransfer(ctx context.Context, id int64) (ScheduledTransfer, error)
+	GetSession(ctx context.Context, id uuid.UUID) (Session, error)
 	GetTransfer(ctx context.Context, id int64) (Transfer, error)
+	GetTransferForUpdate(ctx context.Context, id int64) (Transfer, error)
 	GetUser(ctx context.Context, username string) (User, error)
+	// running_balance is the account balance right after the entry
+	// rows come after the (after_created_at, after_id) keyset position, or from the start if it is null
+	ListAccountEntries(ctx context.Context, arg ListAccountEntriesParams) ([]ListAccountEntriesRow, error)
+	ListAccountHolds(ctx context.Context, arg ListAccountHoldsParams) ([]Hold, error)
+	// running_balance is the account balance right after the transfer
+	// rows come after the (after_created_at, after_id) keyset position, or from the start if it is null
+	// account_amount is the amount in the currency of the account, which differs on the credit side of a cross-currency transfer
+	ListAccountTransfers(ctx context.Context, arg ListAccountTransfersParams) ([]ListAccountTransfersRow, error)
+	// rows come after the (after_created_at, after_id) keyset position, or from the start if it is null
 	ListAccounts(ctx context.Context, arg ListAccountsParams) ([]Account, error)
 	ListEntries(ctx context.Context, arg ListEntriesParams) ([]Entry, error)
+	ListRevokedTokens(ctx context.Context) ([]RevokedToken, error)
+	ListScheduledTransferRuns(ctx context.Context, arg ListScheduledTransferRunsParams) ([]ScheduledTransferRun, error)
+	ListScheduledTransfers(ctx context.Context, arg ListScheduledTransfersParams) ([]ScheduledTransfer, error)
 	ListTransfers(ctx context.Context, arg ListTransfersParams) ([]Transfer, error)
+	ListUserTokenRevocations(ctx context.Context, since pgtype.Timestamptz) ([]ListUserTokenRevocationsRow, error)
+	// The attempt reaching max_attempts locks the user until locked_until and starts the count again.
+	RecordFailedLogin(ctx context.Context, arg RecordFailedLoginParams) (User, error)
+	ResetFailedLogins(ctx context.Context, username string) error
+	RevokeToken(ctx context.Context, arg RevokeTokenParams) error
+	RevokeUserTokens(ctx context.Context, arg RevokeUserTokensParams) (User, error)
+	SetAccountFrozen(ctx context.Context, arg SetAccountFrozenParams) (Account, error)
+	SetHoldStatus(ctx context.Context, arg SetHoldStatusParams) (Hold, error)
+	SetIdempotencyKeyResponse(ctx context.Context, arg SetIdempotencyKeyResponseParams) error
+	SetScheduledTransferNextRun(ctx context.Context, arg SetScheduledTransferNextRunParams) (ScheduledTransfer, error)
+	// the balances are known only once the accounts of the transfer are updated
+	SetTransferBalancesAfter(ctx context.Context, arg SetTransferBalancesAfterParams) (Transfer, error)
+	// It takes a token from the bucket after refilling it at refill_rate tokens per second.
+	// An empty bucket is left untouched and no row is returned.
+	TakeRateLimitToken(ctx context.Context, arg TakeRateLimitTokenParams) (RateLimitBucket, error)
 	UpdateAccount(ctx context.Context, arg UpdateAccountParams) (Account, error)
+	UpdateScheduledTransfer(ctx context.Context, arg UpdateScheduledTransferParams) (ScheduledTransfer, error)
+	UpsertFXRate(ctx context.Context, arg UpsertFXRateParams) (FxRate, error)
+	// marks an unexpired quote as used and converts the amount at its rate, rounding down
+	UseFXQuote(ctx context.Context, arg UseFXQuoteParams) (UseFXQuoteRow, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -0,0 +1,73 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: rate_limit.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const deleteRateLimitBuckets = `-- name: DeleteRateLimitBuckets :exec
+DELETE FROM rate_limit_buckets
+WHERE updated_at < $1
+`
+
+func (q *Queries) DeleteRateLimitBuckets(ctx context.Context, before pgtype.Timestamptz) error {
+	_, err := q.db.Exec(ctx, deleteRateLimitBuckets, before)
+	return err
+}
+
+const getRateLimitBucket = `-- name: GetRateLimitBucket :one
+SELECT key, tokens, updated_at FROM rate_limit_buckets
+WHERE key = $1
+LIMIT 1
+`
+
+func (q *Queries) GetRateLimitBucket(ctx context.Context, key string) (RateLimitBucket, error) {
+	row := q.db.QueryRow(ctx, getRateLimitBucket, key)
+	var i RateLimitBucket
+	err := row.Scan(&i.Key, &i.Tokens, &i.UpdatedAt)
+	return i, err
+}
+
+const takeRateLimitToken = `-- name: TakeRateLimitToken :one
+INSERT INTO rate_limit_buckets (key, tokens, updated_at)
+VALUES ($1, $2::float8 - 1, $3)
+ON CONFLICT (key) DO UPDATE
+SET
+  tokens = LEAST(
+    $2::float8,
+    rate_limit_buckets.tokens + GREATEST(EXTRACT(EPOCH FROM EXCLUDED.updated_at - rate_limit_buckets.updated_at)::float8, 0) * $4::float8
+  ) - 1,
+  updated_at = EXCLUDED.updated_at
+WHERE LEAST(
+  $2::float8,
+  rate_limit_buckets.tokens + GREATEST(EXTRACT(EPOCH FROM EXCLUDED.updated_at - rate_limit_buckets.updated_at)::float8, 0) * $4::float8
+) >= 1
+RETURNING key, tokens, updated_at
+`
+
+type TakeRateLimitTokenParams struct {
+	Key        string             `json:"key"`
+	Burst      float64            `json:"burst"`
+	Now        pgtype.Timestamptz `json:"now"`
+	RefillRate float64            `json:"refill_rate"`
+}
+
+// It takes a token from the bucket after refilling it at refill_rate tokens per second.
+// An empty bucket is left untouched and no row is returned.
+func (q *Queries) TakeRateLimitToken(ctx context.Context, arg TakeRateLimitTokenParams) (RateLimitBucket, error) {
+	row := q.db.QueryRow(ctx, takeRateLimitToken,
+		arg.Key,
+		arg.Burst,
+		arg.Now,
+		arg.RefillRate,
+	)
+	var i RateLimitBucket
+	err := row.Scan(&i.Key, &i.Tokens, &i.UpdatedAt)
+	return i, err
+}
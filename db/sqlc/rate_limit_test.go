@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/niloy104/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTakeRateLimitToken(t *testing.T) {
+	key := "test:" + util.RandomString(12)
+	now := time.Now().Truncate(time.Microsecond)
+
+	take := func(at time.Time) (RateLimitBucket, error) {
+		return testQueries.TakeRateLimitToken(context.Background(), TakeRateLimitTokenParams{
+			Key:        key,
+			Burst:      2,
+			Now:        pgtype.Timestamptz{Time: at, Valid: true},
+			RefillRate: 1,
+		})
+	}
+
+	bucket, err := take(now)
+	require.NoError(t, err)
+	require.Equal(t, float64(1), bucket.Tokens)
+
+	bucket, err = take(now)
+	require.NoError(t, err)
+	require.Equal(t, float64(0), bucket.Tokens)
+
+	// an empty bucket is left untouched
+	_, err = take(now.Add(500 * time.Millisecond))
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	bucket, err = testQueries.GetRateLimitBucket(context.Background(), key)
+	require.NoError(t, err)
+	require.Equal(t, float64(0), bucket.Tokens)
+	require.WithinDuration(t, now, bucket.UpdatedAt.Time, time.Millisecond)
+
+	// the bucket refills at the rate, up to the burst
+	bucket, err = take(now.Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, float64(1), bucket.Tokens)
+
+	err = testQueries.DeleteRateLimitBuckets(context.Background(), pgtype.Timestamptz{Time: now.Add(2 * time.Minute), Valid: true})
+	require.NoError(t, err)
+
+	_, err = testQueries.GetRateLimitBucket(context.Background(), key)
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
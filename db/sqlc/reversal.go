@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	// ErrTransferAlreadyReversed is returned when the whole amount of a transfer was already reversed
+	ErrTransferAlreadyReversed = errors.New("transfer is already fully reversed")
+
+	// ErrReversalTooLarge is returned when a reversal is larger than the amount left to reverse
+	ErrReversalTooLarge = errors.New("reversal amount exceeds the amount left to reverse")
+
+	// ErrTransferNotReversible is returned for reversals themselves and cross-currency transfers
+	ErrTransferNotReversible = errors.New("transfer cannot be reversed")
+
+	// ErrInvalidReversalAmount is returned for a negative reversal amount, which would move money the wrong way
+	ErrInvalidReversalAmount = errors.New("reversal amount must not be negative")
+)
+
+// ReverseTransferTxParams contains the input parameters of the reversal transaction.
+// Amount is less than the original amount for a partial refund, or zero to reverse whatever is left.
+type ReverseTransferTxParams struct {
+	TransferID int64 `json:"transfer_id"`
+	Amount     int64 `json:"amount"`
+}
+
+// ReverseTransferTxResult is the result of the reversal transaction
+type ReverseTransferTxResult struct {
+	TransferTxResult
+	OriginalTransfer Transfer `json:"original_transfer"`
+	RemainingAmount  int64    `json:"remaining_amount"`
+}
+
+// ReverseTransferTx moves money back from the recipient to the sender of a transfer.
+// The reversal is a new transfer linked to the original by reversal_of, with its own compensating entries.
+// Several partial reversals may follow each other, but together they never exceed the original amount.
+func (store *SQLStore) ReverseTransferTx(ctx context.Context, arg ReverseTransferTxParams) (ReverseTransferTxResult, error) {
+	var result ReverseTransferTxResult
+
+	if arg.Amount < 0 {
+		return result, fmt.Errorf("transfer [%d]: %w", arg.TransferID, ErrInvalidReversalAmount)
+	}
+
+	err := store.execTransferTx(ctx, func(q *Queries) error {
+		// locking the original transfer serializes concurrent reversals of it
+		original, err := q.GetTransferForUpdate(ctx, arg.TransferID)
+		if err != nil {
+			return err
+		}
+
+		if original.ReversalOf.Valid || original.QuoteID.Valid {
+			return fmt.Errorf("transfer [%d]: %w", original.ID, ErrTransferNotReversible)
+		}
+
+		reversed, err := q.GetReversedAmount(ctx, pgtype.Int8{Int64: original.ID, Valid: true})
+		if err != nil {
+			return err
+		}
+
+		remaining := original.Amount - reversed
+		amount := arg.Amount
+		if amount == 0 {
+			amount = remaining
+		}
+
+		switch {
+		case remaining == 0:
+			return fmt.Errorf("transfer [%d]: %w", original.ID, ErrTransferAlreadyReversed)
+		case amount > remaining:
+			return fmt.Errorf("transfer [%d] has %d left to reverse: %w", original.ID, remaining, ErrReversalTooLarge)
+		}
+
+		reversal, err := q.CreateReversalTransfer(ctx, CreateReversalTransferParams{
+			FromAccountID: original.ToAccountID,
+			ToAccountID:   original.FromAccountID,
+			Amount:        amount,
+			ReversalOf:    pgtype.Int8{Int64: original.ID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		result.TransferTxResult, err = postTransfer(ctx, q, reversal)
+		if err != nil {
+			return err
+		}
+
+		result.OriginalTransfer = original
+		result.RemainingAmount = remaining - amount
+		return nil
+	})
+
+	return result, err
+}
@@ -0,0 +1,210 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReverseTransferTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createRandomAccountWithBalance(t, transferTestBalance)
+	account2 := createRandomAccountWithBalance(t, transferTestBalance)
+	amount := int64(100)
+
+	original, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        amount,
+	})
+	require.NoError(t, err)
+
+	result, err := store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: original.Transfer.ID,
+	})
+	require.NoError(t, err)
+
+	// the reversal is a transfer back to the sender, linked to the original
+	reversal := result.Transfer
+	require.Equal(t, account2.ID, reversal.FromAccountID.Int64)
+	require.Equal(t, account1.ID, reversal.ToAccountID.Int64)
+	require.Equal(t, amount, reversal.Amount)
+	require.Equal(t, original.Transfer.ID, reversal.ReversalOf.Int64)
+	require.Equal(t, original.Transfer.ID, result.OriginalTransfer.ID)
+	require.Zero(t, result.RemainingAmount)
+
+	require.Equal(t, account2.ID, result.FromEntry.AccountID)
+	require.Equal(t, -amount, result.FromEntry.Amount)
+	require.Equal(t, account1.ID, result.ToEntry.AccountID)
+	require.Equal(t, amount, result.ToEntry.Amount)
+
+	require.Equal(t, account1.Balance, result.ToAccount.Balance)
+	require.Equal(t, account2.Balance, result.FromAccount.Balance)
+
+	_, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: original.Transfer.ID,
+	})
+	require.ErrorIs(t, err, ErrTransferAlreadyReversed)
+
+	// a reversal cannot be reversed itself
+	_, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: reversal.ID,
+	})
+	require.ErrorIs(t, err, ErrTransferNotReversible)
+}
+
+func TestReverseTransferTxPartialRefunds(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createRandomAccountWithBalance(t, transferTestBalance)
+	account2 := createRandomAccountWithBalance(t, transferTestBalance)
+
+	original, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        100,
+	})
+	require.NoError(t, err)
+
+	result, err := store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: original.Transfer.ID,
+		Amount:     30,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(30), result.Transfer.Amount)
+	require.Equal(t, int64(70), result.RemainingAmount)
+
+	_, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: original.Transfer.ID,
+		Amount:     80,
+	})
+	require.ErrorIs(t, err, ErrReversalTooLarge)
+
+	// without an amount the rest of the transfer is reversed
+	result, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: original.Transfer.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(70), result.Transfer.Amount)
+	require.Zero(t, result.RemainingAmount)
+
+	_, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: original.Transfer.ID,
+		Amount:     1,
+	})
+	require.ErrorIs(t, err, ErrTransferAlreadyReversed)
+
+	account, err := store.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance, account.Balance)
+}
+
+func TestReverseTransferTxNegativeAmount(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createRandomAccountWithBalance(t, transferTestBalance)
+	account2 := createRandomAccountWithBalance(t, transferTestBalance)
+
+	original, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        100,
+	})
+	require.NoError(t, err)
+
+	_, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: original.Transfer.ID,
+		Amount:     -50,
+	})
+	require.ErrorIs(t, err, ErrInvalidReversalAmount)
+
+	reversed, err := store.GetReversedAmount(context.Background(), pgtype.Int8{Int64: original.Transfer.ID, Valid: true})
+	require.NoError(t, err)
+	require.Zero(t, reversed)
+
+	// the database rejects a transfer that is not positive, whichever path writes it
+	for _, amount := range []int64{0, -50} {
+		_, err = store.CreateReversalTransfer(context.Background(), CreateReversalTransferParams{
+			FromAccountID: original.Transfer.ToAccountID,
+			ToAccountID:   original.Transfer.FromAccountID,
+			Amount:        amount,
+			ReversalOf:    pgtype.Int8{Int64: original.Transfer.ID, Valid: true},
+		})
+		require.True(t, isCheckViolation(err, "transfers_amount_positive"))
+	}
+}
+
+func TestReverseTransferTxInsufficientFunds(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createRandomAccountWithBalance(t, transferTestBalance)
+	account2 := createRandomAccountWithBalance(t, 0)
+
+	original, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        100,
+	})
+	require.NoError(t, err)
+
+	// the recipient already spent the money
+	_, err = store.WithdrawTx(context.Background(), WithdrawTxParams{
+		AccountID: account2.ID,
+		Amount:    100,
+	})
+	require.NoError(t, err)
+
+	_, err = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID: original.Transfer.ID,
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	reversed, err := store.GetReversedAmount(context.Background(), pgtype.Int8{Int64: original.Transfer.ID, Valid: true})
+	require.NoError(t, err)
+	require.Zero(t, reversed)
+}
+
+func TestReverseTransferTxConcurrent(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createRandomAccountWithBalance(t, transferTestBalance)
+	account2 := createRandomAccountWithBalance(t, transferTestBalance)
+
+	original, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        100,
+	})
+	require.NoError(t, err)
+
+	n := 5
+	errs := make(chan error)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+				TransferID: original.Transfer.ID,
+			})
+			errs <- err
+		}()
+	}
+
+	// only one of the concurrent reversals goes through
+	reversals := 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		if err == nil {
+			reversals++
+			continue
+		}
+		require.True(t, errors.Is(err, ErrTransferAlreadyReversed), err)
+	}
+	require.Equal(t, 1, reversals)
+
+	account, err := store.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance, account.Balance)
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevokeToken(t *testing.T) {
+	user := createRandomUser(t)
+
+	arg := RevokeTokenParams{
+		ID:        uuid.New(),
+		Username:  user.Username,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true},
+	}
+
+	err := testQueries.RevokeToken(context.Background(), arg)
+	require.NoError(t, err)
+
+	// revoking the same token twice is a no-op
+	err = testQueries.RevokeToken(context.Background(), arg)
+	require.NoError(t, err)
+
+	tokens, err := testQueries.ListRevokedTokens(context.Background())
+	require.NoError(t, err)
+
+	var found bool
+	for _, token := range tokens {
+		require.True(t, token.ExpiresAt.Time.After(time.Now()))
+		if token.ID == arg.ID {
+			found = true
+			require.Equal(t, arg.Username, token.Username)
+			require.NotZero(t, token.RevokedAt)
+		}
+	}
+	require.True(t, found)
+}
+
+func TestDeleteExpiredRevokedTokens(t *testing.T) {
+	user := createRandomUser(t)
+
+	arg := RevokeTokenParams{
+		ID:        uuid.New(),
+		Username:  user.Username,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true},
+	}
+
+	err := testQueries.RevokeToken(context.Background(), arg)
+	require.NoError(t, err)
+
+	tokens, err := testQueries.ListRevokedTokens(context.Background())
+	require.NoError(t, err)
+	for _, token := range tokens {
+		require.NotEqual(t, arg.ID, token.ID)
+	}
+
+	err = testQueries.DeleteExpiredRevokedTokens(context.Background())
+	require.NoError(t, err)
+}
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/niloy104/simplebank/util"
+)
+
+// statuses of a scheduled transfer run
+const (
+	RunStatusSucceeded = "succeeded"
+	RunStatusFailed    = "failed"
+)
+
+// ErrAccountFrozen is returned when a scheduled transfer runs on a frozen account
+var ErrAccountFrozen = errors.New("account is frozen")
+
+// RunScheduledTransferTxResult is the result of running a due scheduled transfer.
+// TransferTxResult is empty when the run failed.
+type RunScheduledTransferTxResult struct {
+	TransferTxResult
+	ScheduledTransfer ScheduledTransfer    `json:"scheduled_transfer"`
+	Run               ScheduledTransferRun `json:"run"`
+}
+
+// Recurrence returns how often the scheduled transfer repeats
+func (scheduled ScheduledTransfer) Recurrence() util.Recurrence {
+	return util.Recurrence{
+		CronExpression: scheduled.CronExpression.String,
+		Interval:       time.Duration(scheduled.IntervalSeconds.Int64) * time.Second,
+	}
+}
+
+// RunScheduledTransferTx claims the earliest scheduled transfer due at now, executes it and records the run.
+// The claimed row stays locked until the transaction commits and other replicas skip it, so each run happens once.
+// A failed transfer is rolled back to a savepoint and recorded as a failed run, and the schedule still moves on.
+// A serialization failure or a deadlock is not a failure of the transfer: the whole transaction is retried instead.
+// It returns ErrRecordNotFound when no scheduled transfer is due.
+func (store *SQLStore) RunScheduledTransferTx(ctx context.Context, now time.Time) (RunScheduledTransferTxResult, error) {
+	return store.runScheduledTransferTx(ctx, now, runScheduledTransfer)
+}
+
+// runScheduledTransferTx runs the earliest due scheduled transfer with run
+func (store *SQLStore) runScheduledTransferTx(
+	ctx context.Context,
+	now time.Time,
+	run func(context.Context, *Queries, ScheduledTransfer) (TransferTxResult, error),
+) (RunScheduledTransferTxResult, error) {
+	var result RunScheduledTransferTxResult
+
+	err := store.execTransferTx(ctx, func(q *Queries) error {
+		// nothing is kept from an attempt that was retried
+		result = RunScheduledTransferTxResult{}
+
+		scheduled, err := q.ClaimDueScheduledTransfer(ctx, pgtype.Timestamptz{Time: now, Valid: true})
+		if err != nil {
+			return err
+		}
+
+		runArg := CreateScheduledTransferRunParams{
+			ScheduledTransferID: scheduled.ID,
+			ScheduledAt:         scheduled.NextRunAt,
+			Status:              RunStatusSucceeded,
+		}
+
+		var transferResult TransferTxResult
+		err = withSavepoint(ctx, q, func(q *Queries) error {
+			var err error
+			transferResult, err = run(ctx, q, scheduled)
+			return err
+		})
+		if retryableCode(err) != "" {
+			return err
+		}
+		if err != nil {
+			runArg.Status = RunStatusFailed
+			runArg.Error = pgtype.Text{String: err.Error(), Valid: true}
+		} else {
+			result.TransferTxResult = transferResult
+			runArg.TransferID = pgtype.Int8{Int64: transferResult.Transfer.ID, Valid: true}
+		}
+
+		result.Run, err = q.CreateScheduledTransferRun(ctx, runArg)
+		if err != nil {
+			return err
+		}
+
+		nextRunAt, repeats, err := scheduled.Recurrence().Next(scheduled.NextRunAt.Time, now)
+		if err != nil {
+			return fmt.Errorf("scheduled transfer [%d]: %w", scheduled.ID, err)
+		}
+
+		result.ScheduledTransfer, err = q.SetScheduledTransferNextRun(ctx, SetScheduledTransferNextRunParams{
+			ID:        scheduled.ID,
+			NextRunAt: pgtype.Timestamptz{Time: nextRunAt, Valid: repeats},
+		})
+		return err
+	})
+
+	return result, err
+}
+
+// runScheduledTransfer checks both accounts can still make the transfer and moves the money
+func runScheduledTransfer(ctx context.Context, q *Queries, scheduled ScheduledTransfer) (TransferTxResult, error) {
+	for _, accountID := range []int64{scheduled.FromAccountID, scheduled.ToAccountID} {
+		account, err := q.GetAccount(ctx, accountID)
+		if err != nil {
+			return TransferTxResult{}, err
+		}
+		if account.IsFrozen {
+			return TransferTxResult{}, fmt.Errorf("account [%d]: %w", accountID, ErrAccountFrozen)
+		}
+	}
+
+	return transfer(ctx, q, TransferTxParams{
+		FromAccountID: scheduled.FromAccountID,
+		ToAccountID:   scheduled.ToAccountID,
+		Amount:        scheduled.Amount,
+	})
+}
+
+// withSavepoint runs fn in a savepoint of the open transaction of q,
+// so a failed statement is rolled back without aborting the whole transaction
+func withSavepoint(ctx context.Context, q *Queries, fn func(*Queries) error) error {
+	tx, ok := underlyingDB(q.db).(pgx.Tx)
+	if !ok {
+		return errors.New("savepoint requires an open transaction")
+	}
+
+	savepoint, err := tx.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(New(translateErrors(savepoint))); err != nil {
+		if rbErr := savepoint.Rollback(ctx); rbErr != nil {
+			return fmt.Errorf("savepoint err: %v, rollback err: %v", err, rbErr)
+		}
+		return err
+	}
+
+	return translateError(savepoint.Commit(ctx))
+}
@@ -0,0 +1,347 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: scheduled_transfer.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimDueScheduledTransfer = `-- name: ClaimDueScheduledTransfer :one
+SELECT id, owner, from_account_id, to_account_id, amount, cron_expression, interval_seconds, next_run_at, is_active, created_at FROM scheduled_transfers
+WHERE is_active AND next_run_at <= $1
+ORDER BY next_run_at
+LIMIT 1
+FOR UPDATE SKIP LOCKED
+`
+
+// locks the earliest due scheduled transfer, skipping rows another replica is already running
+func (q *Queries) ClaimDueScheduledTransfer(ctx context.Context, now pgtype.Timestamptz) (ScheduledTransfer, error) {
+	row := q.db.QueryRow(ctx, claimDueScheduledTransfer, now)
+	var i ScheduledTransfer
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CronExpression,
+		&i.IntervalSeconds,
+		&i.NextRunAt,
+		&i.IsActive,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createScheduledTransfer = `-- name: CreateScheduledTransfer :one
+INSERT INTO scheduled_transfers (
+  owner,
+  from_account_id,
+  to_account_id,
+  amount,
+  cron_expression,
+  interval_seconds,
+  next_run_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7
+) RETURNING id, owner, from_account_id, to_account_id, amount, cron_expression, interval_seconds, next_run_at, is_active, created_at
+`
+
+type CreateScheduledTransferParams struct {
+	Owner           string             `json:"owner"`
+	FromAccountID   int64              `json:"from_account_id"`
+	ToAccountID     int64              `json:"to_account_id"`
+	Amount          int64              `json:"amount"`
+	CronExpression  pgtype.Text        `json:"cron_expression"`
+	IntervalSeconds pgtype.Int8        `json:"interval_seconds"`
+	NextRunAt       pgtype.Timestamptz `json:"next_run_at"`
+}
+
+func (q *Queries) CreateScheduledTransfer(ctx context.Context, arg CreateScheduledTransferParams) (ScheduledTransfer, error) {
+	row := q.db.QueryRow(ctx, createScheduledTransfer,
+		arg.Owner,
+		arg.FromAccountID,
+		arg.ToAccountID,
+		arg.Amount,
+		arg.CronExpression,
+		arg.IntervalSeconds,
+		arg.NextRunAt,
+	)
+	var i ScheduledTransfer
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CronExpression,
+		&i.IntervalSeconds,
+		&i.NextRunAt,
+		&i.IsActive,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createScheduledTransferRun = `-- name: CreateScheduledTransferRun :one
+INSERT INTO scheduled_transfer_runs (
+  scheduled_transfer_id,
+  scheduled_at,
+  status,
+  transfer_id,
+  error
+) VALUES (
+  $1, $2, $3, $4, $5
+) RETURNING id, scheduled_transfer_id, scheduled_at, status, transfer_id, error, created_at
+`
+
+type CreateScheduledTransferRunParams struct {
+	ScheduledTransferID int64              `json:"scheduled_transfer_id"`
+	ScheduledAt         pgtype.Timestamptz `json:"scheduled_at"`
+	Status              string             `json:"status"`
+	TransferID          pgtype.Int8        `json:"transfer_id"`
+	Error               pgtype.Text        `json:"error"`
+}
+
+func (q *Queries) CreateScheduledTransferRun(ctx context.Context, arg CreateScheduledTransferRunParams) (ScheduledTransferRun, error) {
+	row := q.db.QueryRow(ctx, createScheduledTransferRun,
+		arg.ScheduledTransferID,
+		arg.ScheduledAt,
+		arg.Status,
+		arg.TransferID,
+		arg.Error,
+	)
+	var i ScheduledTransferRun
+	err := row.Scan(
+		&i.ID,
+		&i.ScheduledTransferID,
+		&i.ScheduledAt,
+		&i.Status,
+		&i.TransferID,
+		&i.Error,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteScheduledTransfer = `-- name: DeleteScheduledTransfer :exec
+DELETE FROM scheduled_transfers
+WHERE id = $1
+`
+
+func (q *Queries) DeleteScheduledTransfer(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, deleteScheduledTransfer, id)
+	return err
+}
+
+const getScheduledTransfer = `-- name: GetScheduledTransfer :one
+SELECT id, owner, from_account_id, to_account_id, amount, cron_expression, interval_seconds, next_run_at, is_active, created_at FROM scheduled_transfers
+WHERE id = $1
+LIMIT 1
+`
+
+func (q *Queries) GetScheduledTransfer(ctx context.Context, id int64) (ScheduledTransfer, error) {
+	row := q.db.QueryRow(ctx, getScheduledTransfer, id)
+	var i ScheduledTransfer
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CronExpression,
+		&i.IntervalSeconds,
+		&i.NextRunAt,
+		&i.IsActive,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listScheduledTransferRuns = `-- name: ListScheduledTransferRuns :many
+SELECT id, scheduled_transfer_id, scheduled_at, status, transfer_id, error, created_at FROM scheduled_transfer_runs
+WHERE
+  scheduled_transfer_id = $1 AND
+  (created_at, id) > (
+    COALESCE($2::timestamptz, '-infinity'),
+    COALESCE($3::bigint, 0)
+  )
+ORDER BY created_at, id
+LIMIT $5
+OFFSET $4
+`
+
+type ListScheduledTransferRunsParams struct {
+	ScheduledTransferID int64              `json:"scheduled_transfer_id"`
+	AfterCreatedAt      pgtype.Timestamptz `json:"after_created_at"`
+	AfterID             pgtype.Int8        `json:"after_id"`
+	Offset              int32              `json:"offset"`
+	Limit               int32              `json:"limit"`
+}
+
+func (q *Queries) ListScheduledTransferRuns(ctx context.Context, arg ListScheduledTransferRunsParams) ([]ScheduledTransferRun, error) {
+	rows, err := q.db.Query(ctx, listScheduledTransferRuns,
+		arg.ScheduledTransferID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ScheduledTransferRun{}
+	for rows.Next() {
+		var i ScheduledTransferRun
+		if err := rows.Scan(
+			&i.ID,
+			&i.ScheduledTransferID,
+			&i.ScheduledAt,
+			&i.Status,
+			&i.TransferID,
+			&i.Error,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listScheduledTransfers = `-- name: ListScheduledTransfers :many
+SELECT id, owner, from_account_id, to_account_id, amount, cron_expression, interval_seconds, next_run_at, is_active, created_at FROM scheduled_transfers
+WHERE
+  owner = $1 AND
+  (created_at, id) > (
+    COALESCE($2::timestamptz, '-infinity'),
+    COALESCE($3::bigint, 0)
+  )
+ORDER BY created_at, id
+LIMIT $5
+OFFSET $4
+`
+
+type ListScheduledTransfersParams struct {
+	Owner          string             `json:"owner"`
+	AfterCreatedAt pgtype.Timestamptz `json:"after_created_at"`
+	AfterID        pgtype.Int8        `json:"after_id"`
+	Offset         int32              `json:"offset"`
+	Limit          int32              `json:"limit"`
+}
+
+func (q *Queries) ListScheduledTransfers(ctx context.Context, arg ListScheduledTransfersParams) ([]ScheduledTransfer, error) {
+	rows, err := q.db.Query(ctx, listScheduledTransfers,
+		arg.Owner,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ScheduledTransfer{}
+	for rows.Next() {
+		var i ScheduledTransfer
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.FromAccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.CronExpression,
+			&i.IntervalSeconds,
+			&i.NextRunAt,
+			&i.IsActive,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setScheduledTransferNextRun = `-- name: SetScheduledTransferNextRun :one
+UPDATE scheduled_transfers
+SET next_run_at = $1
+WHERE id = $2
+RETURNING id, owner, from_account_id, to_account_id, amount, cron_expression, interval_seconds, next_run_at, is_active, created_at
+`
+
+type SetScheduledTransferNextRunParams struct {
+	NextRunAt pgtype.Timestamptz `json:"next_run_at"`
+	ID        int64              `json:"id"`
+}
+
+func (q *Queries) SetScheduledTransferNextRun(ctx context.Context, arg SetScheduledTransferNextRunParams) (ScheduledTransfer, error) {
+	row := q.db.QueryRow(ctx, setScheduledTransferNextRun, arg.NextRunAt, arg.ID)
+	var i ScheduledTransfer
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CronExpression,
+		&i.IntervalSeconds,
+		&i.NextRunAt,
+		&i.IsActive,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const updateScheduledTransfer = `-- name: UpdateScheduledTransfer :one
+UPDATE scheduled_transfers
+SET
+  amount = COALESCE($1, amount),
+  is_active = COALESCE($2, is_active),
+  next_run_at = COALESCE($3, next_run_at)
+WHERE id = $4
+RETURNING id, owner, from_account_id, to_account_id, amount, cron_expression, interval_seconds, next_run_at, is_active, created_at
+`
+
+type UpdateScheduledTransferParams struct {
+	Amount    pgtype.Int8        `json:"amount"`
+	IsActive  pgtype.Bool        `json:"is_active"`
+	NextRunAt pgtype.Timestamptz `json:"next_run_at"`
+	ID        int64              `json:"id"`
+}
+
+func (q *Queries) UpdateScheduledTransfer(ctx context.Context, arg UpdateScheduledTransferParams) (ScheduledTransfer, error) {
+	row := q.db.QueryRow(ctx, updateScheduledTransfer,
+		arg.Amount,
+		arg.IsActive,
+		arg.NextRunAt,
+		arg.ID,
+	)
+	var i ScheduledTransfer
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CronExpression,
+		&i.IntervalSeconds,
+		&i.NextRunAt,
+		&i.IsActive,
+		&i.CreatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,315 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomScheduledTransfer(t *testing.T, fromAccount, toAccount Account, amount int64, nextRunAt time.Time) ScheduledTransfer {
+	arg := CreateScheduledTransferParams{
+		Owner:           fromAccount.Owner,
+		FromAccountID:   fromAccount.ID,
+		ToAccountID:     toAccount.ID,
+		Amount:          amount,
+		IntervalSeconds: pgtype.Int8{Int64: 3600, Valid: true},
+		NextRunAt:       pgtype.Timestamptz{Time: nextRunAt, Valid: true},
+	}
+
+	scheduled, err := testQueries.CreateScheduledTransfer(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, scheduled.ID)
+	require.Equal(t, arg.Owner, scheduled.Owner)
+	require.Equal(t, arg.Amount, scheduled.Amount)
+	require.Equal(t, arg.IntervalSeconds, scheduled.IntervalSeconds)
+	require.False(t, scheduled.CronExpression.Valid)
+	require.True(t, scheduled.IsActive)
+	require.WithinDuration(t, nextRunAt, scheduled.NextRunAt.Time, time.Millisecond)
+
+	return scheduled
+}
+
+// runDueScheduledTransfersUntil runs due scheduled transfers until the given one has run.
+// Transfers left due by other tests may run first.
+func runDueScheduledTransfersUntil(t *testing.T, store Store, id int64, now time.Time) RunScheduledTransferTxResult {
+	for {
+		result, err := store.RunScheduledTransferTx(context.Background(), now)
+		require.NoError(t, err)
+		if result.ScheduledTransfer.ID == id {
+			return result
+		}
+	}
+}
+
+func TestUpdateScheduledTransfer(t *testing.T) {
+	fromAccount := createRandomAccount(t)
+	toAccount := createRandomAccount(t)
+	scheduled := createRandomScheduledTransfer(t, fromAccount, toAccount, 10, time.Now().Add(time.Hour))
+
+	updated, err := testQueries.UpdateScheduledTransfer(context.Background(), UpdateScheduledTransferParams{
+		ID:       scheduled.ID,
+		Amount:   pgtype.Int8{Int64: 20, Valid: true},
+		IsActive: pgtype.Bool{Bool: false, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(20), updated.Amount)
+	require.False(t, updated.IsActive)
+	require.Equal(t, scheduled.NextRunAt, updated.NextRunAt)
+
+	err = testQueries.DeleteScheduledTransfer(context.Background(), scheduled.ID)
+	require.NoError(t, err)
+
+	_, err = testQueries.GetScheduledTransfer(context.Background(), scheduled.ID)
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+func TestListScheduledTransfers(t *testing.T) {
+	fromAccount := createRandomAccount(t)
+	toAccount := createRandomAccount(t)
+
+	for i := 0; i < 3; i++ {
+		createRandomScheduledTransfer(t, fromAccount, toAccount, 10, time.Now().Add(time.Hour))
+	}
+
+	scheduledTransfers, err := testQueries.ListScheduledTransfers(context.Background(), ListScheduledTransfersParams{
+		Owner: fromAccount.Owner,
+		Limit: 5,
+	})
+	require.NoError(t, err)
+	require.Len(t, scheduledTransfers, 3)
+	for _, scheduled := range scheduledTransfers {
+		require.Equal(t, fromAccount.Owner, scheduled.Owner)
+	}
+}
+
+func TestRunScheduledTransferTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	fromAccount := createRandomAccountWithBalance(t, transferTestBalance)
+	toAccount := createRandomAccountWithBalance(t, 0)
+	amount := int64(10)
+
+	now := time.Now()
+	dueAt := now.Add(-time.Minute)
+	scheduled := createRandomScheduledTransfer(t, fromAccount, toAccount, amount, dueAt)
+
+	result := runDueScheduledTransfersUntil(t, store, scheduled.ID, now)
+
+	run := result.Run
+	require.Equal(t, scheduled.ID, run.ScheduledTransferID)
+	require.Equal(t, RunStatusSucceeded, run.Status)
+	require.True(t, run.TransferID.Valid)
+	require.False(t, run.Error.Valid)
+	require.WithinDuration(t, dueAt, run.ScheduledAt.Time, time.Millisecond)
+
+	// the next run keeps the hourly cadence
+	require.WithinDuration(t, dueAt.Add(time.Hour), result.ScheduledTransfer.NextRunAt.Time, time.Millisecond)
+
+	transfer, err := store.GetTransfer(context.Background(), run.TransferID.Int64)
+	require.NoError(t, err)
+	require.Equal(t, fromAccount.ID, transfer.FromAccountID.Int64)
+	require.Equal(t, toAccount.ID, transfer.ToAccountID.Int64)
+	require.Equal(t, amount, transfer.Amount)
+	require.Equal(t, transfer.ID, result.Transfer.ID)
+	require.Equal(t, fromAccount.Currency, result.FromAccount.Currency)
+
+	account, err := store.GetAccount(context.Background(), toAccount.ID)
+	require.NoError(t, err)
+	require.Equal(t, amount, account.Balance)
+
+	runs, err := store.ListScheduledTransferRuns(context.Background(), ListScheduledTransferRunsParams{
+		ScheduledTransferID: scheduled.ID,
+		Limit:               5,
+	})
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	require.Equal(t, run.ID, runs[0].ID)
+}
+
+func TestRunScheduledTransferTxFailure(t *testing.T) {
+	store := NewStore(testDB)
+
+	fromAccount := createRandomAccountWithBalance(t, 5)
+	toAccount := createRandomAccountWithBalance(t, 0)
+
+	now := time.Now()
+	scheduled := createRandomScheduledTransfer(t, fromAccount, toAccount, 10, now.Add(-time.Minute))
+
+	// the failed transfer is recorded and the schedule moves on to its next run
+	result := runDueScheduledTransfersUntil(t, store, scheduled.ID, now)
+	require.Equal(t, RunStatusFailed, result.Run.Status)
+	require.False(t, result.Run.TransferID.Valid)
+	require.Contains(t, result.Run.Error.String, ErrInsufficientFunds.Error())
+	require.Empty(t, result.TransferTxResult)
+	require.True(t, result.ScheduledTransfer.NextRunAt.Time.After(now))
+
+	account, err := store.GetAccount(context.Background(), fromAccount.ID)
+	require.NoError(t, err)
+	require.Equal(t, fromAccount.Balance, account.Balance)
+
+	entries, err := store.ListEntries(context.Background(), ListEntriesParams{
+		AccountID: fromAccount.ID,
+		Limit:     5,
+	})
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestRunScheduledTransferTxRetriesDeadlock(t *testing.T) {
+	store := NewStore(testDB).(*SQLStore)
+	store.retry = txRetryPolicy{maxAttempts: 3, baseDelay: time.Millisecond, maxDelay: time.Millisecond}
+
+	fromAccount := createRandomAccountWithBalance(t, transferTestBalance)
+	toAccount := createRandomAccountWithBalance(t, 0)
+
+	now := time.Now()
+	scheduled := createRandomScheduledTransfer(t, fromAccount, toAccount, 10, now.Add(-time.Minute))
+
+	// the first attempt deadlocks, which is retried instead of being recorded as a failed run
+	attempts := 0
+	run := func(ctx context.Context, q *Queries, claimed ScheduledTransfer) (TransferTxResult, error) {
+		if claimed.ID != scheduled.ID {
+			return runScheduledTransfer(ctx, q, claimed)
+		}
+		attempts++
+		if attempts == 1 {
+			return TransferTxResult{}, &pgconn.PgError{Code: deadlockDetected}
+		}
+		return runScheduledTransfer(ctx, q, claimed)
+	}
+
+	for {
+		result, err := store.runScheduledTransferTx(context.Background(), now, run)
+		require.NoError(t, err)
+		if result.ScheduledTransfer.ID != scheduled.ID {
+			continue
+		}
+
+		require.Equal(t, 2, attempts)
+		require.Equal(t, RunStatusSucceeded, result.Run.Status)
+		require.True(t, result.Run.TransferID.Valid)
+		break
+	}
+	require.Equal(t, int64(1), store.TxStats().DeadlockRetries)
+
+	runs, err := store.ListScheduledTransferRuns(context.Background(), ListScheduledTransferRunsParams{
+		ScheduledTransferID: scheduled.ID,
+		Limit:               5,
+	})
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+}
+
+func TestRunScheduledTransferTxFrozenAccount(t *testing.T) {
+	store := NewStore(testDB)
+
+	fromAccount := createRandomAccountWithBalance(t, transferTestBalance)
+	toAccount := createRandomAccountWithBalance(t, 0)
+	_, err := store.SetAccountFrozen(context.Background(), SetAccountFrozenParams{
+		ID:       toAccount.ID,
+		IsFrozen: true,
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	scheduled := createRandomScheduledTransfer(t, fromAccount, toAccount, 10, now.Add(-time.Minute))
+
+	result := runDueScheduledTransfersUntil(t, store, scheduled.ID, now)
+	require.Equal(t, RunStatusFailed, result.Run.Status)
+	require.Contains(t, result.Run.Error.String, ErrAccountFrozen.Error())
+}
+
+func TestRunScheduledTransferTxOneOff(t *testing.T) {
+	store := NewStore(testDB)
+
+	fromAccount := createRandomAccountWithBalance(t, transferTestBalance)
+	toAccount := createRandomAccountWithBalance(t, 0)
+
+	now := time.Now()
+	scheduled, err := store.CreateScheduledTransfer(context.Background(), CreateScheduledTransferParams{
+		Owner:         fromAccount.Owner,
+		FromAccountID: fromAccount.ID,
+		ToAccountID:   toAccount.ID,
+		Amount:        10,
+		NextRunAt:     pgtype.Timestamptz{Time: now.Add(-time.Minute), Valid: true},
+	})
+	require.NoError(t, err)
+
+	result := runDueScheduledTransfersUntil(t, store, scheduled.ID, now)
+	require.Equal(t, RunStatusSucceeded, result.Run.Status)
+	require.False(t, result.ScheduledTransfer.NextRunAt.Valid)
+
+	// a finished one-off transfer is never due again
+	for {
+		result, err := store.RunScheduledTransferTx(context.Background(), now.Add(24*time.Hour))
+		if errors.Is(err, ErrRecordNotFound) {
+			break
+		}
+		require.NoError(t, err)
+		require.NotEqual(t, scheduled.ID, result.ScheduledTransfer.ID)
+	}
+}
+
+func TestRunScheduledTransferTxConcurrent(t *testing.T) {
+	store := NewStore(testDB)
+
+	fromAccount := createRandomAccountWithBalance(t, transferTestBalance)
+	toAccount := createRandomAccountWithBalance(t, 0)
+	amount := int64(10)
+
+	now := time.Now()
+	n := 5
+	scheduledIDs := make(map[int64]bool, n)
+	for i := 0; i < n; i++ {
+		scheduled := createRandomScheduledTransfer(t, fromAccount, toAccount, amount, now.Add(-time.Minute))
+		scheduledIDs[scheduled.ID] = true
+	}
+
+	// several replicas run the due transfers at the same time
+	replicas := 3
+	errs := make(chan error)
+	results := make(chan []RunScheduledTransferTxResult)
+
+	for i := 0; i < replicas; i++ {
+		go func() {
+			var runs []RunScheduledTransferTxResult
+			for {
+				result, err := store.RunScheduledTransferTx(context.Background(), now)
+				if errors.Is(err, ErrRecordNotFound) {
+					break
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+				runs = append(runs, result)
+			}
+			errs <- nil
+			results <- runs
+		}()
+	}
+
+	runCounts := make(map[int64]int)
+	for i := 0; i < replicas; i++ {
+		err := <-errs
+		require.NoError(t, err)
+
+		for _, result := range <-results {
+			runCounts[result.ScheduledTransfer.ID]++
+		}
+	}
+
+	// every scheduled transfer ran exactly once
+	for id := range scheduledIDs {
+		require.Equal(t, 1, runCounts[id], "scheduled transfer [%d]", id)
+	}
+
+	account, err := store.GetAccount(context.Background(), toAccount.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(n)*amount, account.Balance)
+}
@@ -3,10 +3,12 @@ package db
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Store provides all functon to execute db quereis and transactions
@@ -14,41 +16,62 @@ import (
 type Store interface {
 	Querier
 	TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error)
+	IdempotentTransferTx(ctx context.Context, arg IdempotentTransferTxParams) (TransferTxResult, bool, error)
+	IdempotentCreateAccountTx(ctx context.Context, arg IdempotentCreateAccountTxParams) (Account, bool, error)
+	FXTransferTx(ctx context.Context, arg FXTransferTxParams) (TransferTxResult, error)
+	IdempotentFXTransferTx(ctx context.Context, arg IdempotentFXTransferTxParams) (TransferTxResult, bool, error)
+	DepositTx(ctx context.Context, arg DepositTxParams) (CashTxResult, error)
+	WithdrawTx(ctx context.Context, arg WithdrawTxParams) (CashTxResult, error)
+	PlaceHoldTx(ctx context.Context, arg PlaceHoldTxParams) (HoldTxResult, error)
+	CaptureHoldTx(ctx context.Context, arg CaptureHoldTxParams) (CaptureHoldTxResult, error)
+	ReleaseHoldTx(ctx context.Context, holdID int64) (HoldTxResult, error)
+	ExpireHoldTx(ctx context.Context, now time.Time) (HoldTxResult, error)
+	ReverseTransferTx(ctx context.Context, arg ReverseTransferTxParams) (ReverseTransferTxResult, error)
+	RunScheduledTransferTx(ctx context.Context, now time.Time) (RunScheduledTransferTxResult, error)
+	TxStats() TxStats
+	Ping(ctx context.Context) error
+	MigrationVersion(ctx context.Context) (version int64, dirty bool, err error)
 }
 
 // SQLStore provides all functon to execute sql quereis and transactions
 
 type SQLStore struct {
 	*Queries
-	db DBTX
+	db      DBTX
+	retry   txRetryPolicy
+	txStats txStats
+	// transferIsolation is the isolation level of the transactions moving money
+	transferIsolation pgx.TxIsoLevel
 }
 
-//NewStore creates a new store
+// StoreOption changes how the store runs its transactions
+type StoreOption func(*SQLStore)
 
-func NewStore(db DBTX) Store {
-	return &SQLStore{
-		db:      db,
-		Queries: New(db),
+// WithTransferIsolation runs the transactions moving money at the isolation level
+// instead of the default of the database. Serialization failures they hit are retried.
+func WithTransferIsolation(level pgx.TxIsoLevel) StoreOption {
+	return func(store *SQLStore) {
+		store.transferIsolation = level
 	}
 }
 
-// execTx executes a funtion within a database transactions
-func (store *SQLStore) execTx(ctx context.Context, fn func(*Queries) error) error {
-	tx, err := store.db.(*pgxpool.Pool).BeginTx(ctx, pgx.TxOptions{})
-	if err != nil {
-		return err
-	}
-
-	q := New(tx)
+//NewStore creates a new store
 
-	if err := fn(q); err != nil {
-		if rbErr := tx.Rollback(ctx); rbErr != nil {
-			return fmt.Errorf("tx err: %v, rollback err: %v", err, rbErr)
-		}
-		return err
+func NewStore(db DBTX, options ...StoreOption) Store {
+	store := &SQLStore{
+		db:      db,
+		Queries: New(translateErrors(db)),
+		retry:   defaultTxRetryPolicy,
+	}
+	for _, option := range options {
+		option(store)
 	}
+	return store
+}
 
-	return tx.Commit(ctx)
+// execTransferTx runs a transaction moving money, at the transfer isolation level of the store
+func (store *SQLStore) execTransferTx(ctx context.Context, fn func(*Queries) error) error {
+	return store.execTx(ctx, fn, withIsolation(store.transferIsolation))
 }
 
 // TransferTxParams contains the input parameters of the transfer transactions
@@ -69,51 +92,109 @@ type TransferTxResult struct {
 
 // transfertx perform a money transfer from one to other account
 // It creates a transfer record, add account entries and update accounts balance within a single database transaction
-func (store *SQLStore) TransferTx(ctx context.Context, arg TransferTxParams) (TransferTxResult, error) {
-	var result TransferTxResult
-
-	err := store.execTx(ctx, func(q *Queries) error {
+func (store *SQLStore) TransferTx(ctx context.Context, arg TransferTxParams) (result TransferTxResult, err error) {
+	ctx, span := tracer.Start(ctx, "TransferTx", trace.WithAttributes(
+		attribute.Int64("transfer.from_account_id", arg.FromAccountID),
+		attribute.Int64("transfer.to_account_id", arg.ToAccountID),
+		attribute.Int64("transfer.amount", arg.Amount),
+	))
+	defer func() {
+		endSpan(span, err)
+	}()
+
+	err = store.execTransferTx(ctx, func(q *Queries) error {
 		var err error
+		result, err = transfer(ctx, q, arg)
+		return err
+	})
 
-		result.Transfer, err = q.CreateTransfer(ctx, CreateTransferParams{
-			FromAccountID: pgtype.Int8{Int64: arg.FromAccountID, Valid: true},
-			ToAccountID:   pgtype.Int8{Int64: arg.ToAccountID, Valid: true},
-			Amount:        arg.Amount,
-		})
+	return result, err
+}
 
-		if err != nil {
-			return err
-		}
+// transfer moves money between accounts using the queries of an open transaction
+func transfer(ctx context.Context, q *Queries, arg TransferTxParams) (TransferTxResult, error) {
+	createdTransfer, err := q.CreateTransfer(ctx, CreateTransferParams{
+		FromAccountID: pgtype.Int8{Int64: arg.FromAccountID, Valid: true},
+		ToAccountID:   pgtype.Int8{Int64: arg.ToAccountID, Valid: true},
+		Amount:        arg.Amount,
+	})
 
-		result.FromEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.FromAccountID,
-			Amount:    -arg.Amount,
-		})
-		if err != nil {
-			return err
-		}
+	if err != nil {
+		return TransferTxResult{}, err
+	}
 
-		result.ToEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: arg.ToAccountID,
-			Amount:    arg.Amount,
-		})
-		if err != nil {
-			return err
-		}
+	return postTransfer(ctx, q, createdTransfer)
+}
 
-		// todo: update accounts balance
+// postTransfer updates the balances of the accounts of a created transfer and adds its entries
+func postTransfer(ctx context.Context, q *Queries, createdTransfer Transfer) (TransferTxResult, error) {
+	result := TransferTxResult{Transfer: createdTransfer}
+	arg := TransferTxParams{
+		FromAccountID: createdTransfer.FromAccountID.Int64,
+		ToAccountID:   createdTransfer.ToAccountID.Int64,
+		Amount:        createdTransfer.Amount,
+	}
+	var err error
 
-		if arg.FromAccountID < arg.ToAccountID {
-			result.FromAccount, result.ToAccount, err = addMoney(ctx, q, arg.FromAccountID, -arg.Amount, arg.ToAccountID, arg.Amount)
+	// the balances are updated in the order of the account IDs, so concurrent transfers cannot deadlock.
+	// Waiting for the row locks shows up in this span.
+	balancesCtx, span := tracer.Start(ctx, "UpdateBalances")
+	if arg.FromAccountID < arg.ToAccountID {
+		result.FromAccount, result.ToAccount, err = addMoney(balancesCtx, q, arg.FromAccountID, -arg.Amount, arg.ToAccountID, arg.Amount)
 
-		} else {
-			result.ToAccount, result.FromAccount, err = addMoney(ctx, q, arg.ToAccountID, arg.Amount, arg.FromAccountID, -arg.Amount)
+	} else {
+		result.ToAccount, result.FromAccount, err = addMoney(balancesCtx, q, arg.ToAccountID, arg.Amount, arg.FromAccountID, -arg.Amount)
+	}
+	endSpan(span, err)
+	if err != nil {
+		// the balance check constraint keeps concurrent transfers from overdrawing the account
+		if isCheckViolation(err, accountBalanceConstraint) {
+			return result, fmt.Errorf("account [%d]: %w", arg.FromAccountID, ErrInsufficientFunds)
 		}
+		return result, err
+	}
+
+	fromBalance, toBalance := result.FromAccount.Balance, result.ToAccount.Balance
+	if arg.FromAccountID == arg.ToAccountID {
+		// the account is updated twice and the debit comes last, so both sides end at its balance
+		toBalance = fromBalance
+	}
 
-		return nil
+	entriesCtx, span := tracer.Start(ctx, "CreateEntries")
+	result.FromEntry, result.ToEntry, err = createEntries(entriesCtx, q, arg, fromBalance, toBalance)
+	if err == nil {
+		result.Transfer, err = q.SetTransferBalancesAfter(entriesCtx, SetTransferBalancesAfterParams{
+			ID:               createdTransfer.ID,
+			FromBalanceAfter: pgtype.Int8{Int64: fromBalance, Valid: true},
+			ToBalanceAfter:   pgtype.Int8{Int64: toBalance, Valid: true},
+		})
+	}
+	endSpan(span, err)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// createEntries adds the entries debiting and crediting the accounts of a transfer,
+// with the balances the accounts have after it
+func createEntries(ctx context.Context, q *Queries, arg TransferTxParams, fromBalance int64, toBalance int64) (fromEntry Entry, toEntry Entry, err error) {
+	fromEntry, err = q.CreateEntry(ctx, CreateEntryParams{
+		AccountID:    arg.FromAccountID,
+		Amount:       -arg.Amount,
+		BalanceAfter: fromBalance,
 	})
+	if err != nil {
+		return
+	}
 
-	return result, err
+	toEntry, err = q.CreateEntry(ctx, CreateEntryParams{
+		AccountID:    arg.ToAccountID,
+		Amount:       arg.Amount,
+		BalanceAfter: toBalance,
+	})
+	return
 }
 
 func addMoney(
@@ -5,14 +5,19 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/niloy104/simplebank/util"
 	"github.com/stretchr/testify/require"
 )
 
+// transferTestBalance is enough for every transfer made by the tests below
+const transferTestBalance = int64(1000)
+
 func TestTransferTx(t *testing.T) {
 	store := NewStore(testDB)
 
-	account1 := createRandomAccount(t)
-	account2 := createRandomAccount(t)
+	account1 := createRandomAccountWithBalance(t, transferTestBalance)
+	account2 := createRandomAccountWithBalance(t, transferTestBalance)
 	fmt.Println("===before:", account1.Balance, account2.Balance)
 
 	n := 5
@@ -99,6 +104,12 @@ func TestTransferTx(t *testing.T) {
 		require.Equal(t, diff1, diff2)
 		require.True(t, diff1%amount == 0)
 
+		// the entries and the transfer keep the balances right after it
+		require.Equal(t, fromAccount.Balance, fromEntry.BalanceAfter)
+		require.Equal(t, toAccount.Balance, toEntry.BalanceAfter)
+		require.Equal(t, fromAccount.Balance, transfer.FromBalanceAfter.Int64)
+		require.Equal(t, toAccount.Balance, transfer.ToBalanceAfter.Int64)
+
 		k := int(diff1 / amount)
 		require.True(t, k >= 1 && k <= n)
 		require.NotContains(t, existed, k)
@@ -122,8 +133,8 @@ func TestTransferTx(t *testing.T) {
 func TestTransferTxDeadlock(t *testing.T) {
 	store := NewStore(testDB)
 
-	account1 := createRandomAccount(t)
-	account2 := createRandomAccount(t)
+	account1 := createRandomAccountWithBalance(t, transferTestBalance)
+	account2 := createRandomAccountWithBalance(t, transferTestBalance)
 	fmt.Println("===before:", account1.Balance, account2.Balance)
 
 	n := 10
@@ -172,3 +183,225 @@ func TestTransferTxDeadlock(t *testing.T) {
 	require.Equal(t, account2.Balance, updatedAccount2.Balance)
 
 }
+
+func TestTransferTxInsufficientFunds(t *testing.T) {
+	store := NewStore(testDB)
+
+	n := 10
+	amount := int64(10)
+	succeeded := n / 2
+
+	// the source account can only afford half of the transfers
+	account1 := createRandomAccountWithBalance(t, int64(succeeded)*amount)
+	account2 := createRandomAccountWithBalance(t, transferTestBalance)
+
+	errs := make(chan error)
+
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := store.TransferTx(context.Background(), TransferTxParams{
+				FromAccountID: account1.ID,
+				ToAccountID:   account2.ID,
+				Amount:        amount,
+			})
+
+			errs <- err
+		}()
+	}
+
+	failed := 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		if err != nil {
+			require.ErrorIs(t, err, ErrInsufficientFunds)
+			failed++
+		}
+	}
+	require.Equal(t, n-succeeded, failed)
+
+	// the account is drained but never overdrawn
+	updatedAccount1, err := store.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Zero(t, updatedAccount1.Balance)
+
+	updatedAccount2, err := store.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+	require.Equal(t, account2.Balance+int64(succeeded)*amount, updatedAccount2.Balance)
+
+	// failed transfers leave no transfer or entry behind
+	transfers, err := store.ListTransfers(context.Background(), ListTransfersParams{
+		FromAccountID: pgtype.Int8{Int64: account1.ID, Valid: true},
+		ToAccountID:   pgtype.Int8{Int64: account1.ID, Valid: true},
+		Limit:         int32(n),
+		Offset:        0,
+	})
+	require.NoError(t, err)
+	require.Len(t, transfers, succeeded)
+
+	entries, err := store.ListEntries(context.Background(), ListEntriesParams{
+		AccountID: account1.ID,
+		Limit:     int32(n),
+		Offset:    0,
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, succeeded)
+}
+
+func TestTransferTxOverdraftBothWays(t *testing.T) {
+	store := NewStore(testDB)
+
+	// every transfer costs the whole balance, so at most one per direction can succeed at a time
+	amount := int64(10)
+	account1 := createRandomAccountWithBalance(t, amount)
+	account2 := createRandomAccountWithBalance(t, amount)
+
+	n := 10
+	errs := make(chan error)
+
+	for i := 0; i < n; i++ {
+		fromAccountID := account1.ID
+		toAccountID := account2.ID
+
+		if i%2 == 1 {
+			fromAccountID = account2.ID
+			toAccountID = account1.ID
+		}
+
+		go func() {
+			_, err := store.TransferTx(context.Background(), TransferTxParams{
+				FromAccountID: fromAccountID,
+				ToAccountID:   toAccountID,
+				Amount:        amount,
+			})
+
+			errs <- err
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		err := <-errs
+		if err != nil {
+			require.ErrorIs(t, err, ErrInsufficientFunds)
+		}
+	}
+
+	updatedAccount1, err := store.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, updatedAccount1.Balance, int64(0))
+
+	updatedAccount2, err := store.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, updatedAccount2.Balance, int64(0))
+
+	// money is neither created nor destroyed
+	require.Equal(t, account1.Balance+account2.Balance, updatedAccount1.Balance+updatedAccount2.Balance)
+}
+
+func TestIdempotentTransferTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createRandomAccountWithBalance(t, transferTestBalance)
+	account2 := createRandomAccountWithBalance(t, transferTestBalance)
+
+	n := 5
+	amount := int64(10)
+	idempotency := IdempotencyParams{
+		Username:    account1.Owner,
+		Key:         util.RandomString(16),
+		RequestHash: util.RandomString(64),
+	}
+
+	errs := make(chan error)
+	results := make(chan TransferTxResult)
+	replays := make(chan bool)
+
+	// run n concurrent transfers with the same idempotency key
+	for i := 0; i < n; i++ {
+		go func() {
+			result, replayed, err := store.IdempotentTransferTx(context.Background(), IdempotentTransferTxParams{
+				TransferTxParams: TransferTxParams{
+					FromAccountID: account1.ID,
+					ToAccountID:   account2.ID,
+					Amount:        amount,
+				},
+				Idempotency: idempotency,
+			})
+
+			errs <- err
+			results <- result
+			replays <- replayed
+		}()
+	}
+
+	// only one transfer is made, the others replay it
+	var transferID int64
+	replayCount := 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		require.NoError(t, err)
+
+		result := <-results
+		require.NotZero(t, result.Transfer.ID)
+		if transferID == 0 {
+			transferID = result.Transfer.ID
+		}
+		require.Equal(t, transferID, result.Transfer.ID)
+
+		if <-replays {
+			replayCount++
+		}
+	}
+	require.Equal(t, n-1, replayCount)
+
+	updatedAccount1, err := store.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance-amount, updatedAccount1.Balance)
+
+	updatedAccount2, err := store.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+	require.Equal(t, account2.Balance+amount, updatedAccount2.Balance)
+
+	// the same key with a different request is rejected
+	_, _, err = store.IdempotentTransferTx(context.Background(), IdempotentTransferTxParams{
+		TransferTxParams: TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        amount * 2,
+		},
+		Idempotency: IdempotencyParams{
+			Username:    idempotency.Username,
+			Key:         idempotency.Key,
+			RequestHash: util.RandomString(64),
+		},
+	})
+	require.ErrorIs(t, err, ErrIdempotencyKeyReused)
+}
+
+func TestIdempotentCreateAccountTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+
+	arg := IdempotentCreateAccountTxParams{
+		CreateAccountParams: CreateAccountParams{
+			Owner:    user.Username,
+			Balance:  0,
+			Currency: util.RandomCurrency(),
+		},
+		Idempotency: IdempotencyParams{
+			Username:    user.Username,
+			Key:         util.RandomString(16),
+			RequestHash: util.RandomString(64),
+		},
+	}
+
+	account1, replayed, err := store.IdempotentCreateAccountTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, replayed)
+	require.NotZero(t, account1.ID)
+
+	account2, replayed, err := store.IdempotentCreateAccountTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, replayed)
+	require.Equal(t, account1.ID, account2.ID)
+	require.Equal(t, account1.Currency, account2.Currency)
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/niloy104/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestQueryName(t *testing.T) {
+	require.Equal(t, "GetAccount", queryName(getAccount))
+	require.Equal(t, "AddAccountBalance", queryName(addAccountBalance))
+	require.Equal(t, "BEGIN", queryName("begin isolation level serializable"))
+	require.Equal(t, "SAVEPOINT", queryName("savepoint sp_1"))
+	require.Equal(t, "query", queryName(""))
+}
+
+// endedSpans returns the ended spans with the name
+func endedSpans(spans *tracetest.SpanRecorder, name string) []sdktrace.ReadOnlySpan {
+	var named []sdktrace.ReadOnlySpan
+	for _, span := range spans.Ended() {
+		if span.Name() == name {
+			named = append(named, span)
+		}
+	}
+	return named
+}
+
+func TestTracing(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	defaultProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	defer otel.SetTracerProvider(defaultProvider)
+
+	t.Run("Queries", func(t *testing.T) {
+		config, err := util.LoadConfig("../..")
+		require.NoError(t, err)
+
+		poolConfig, err := pgxpool.ParseConfig(config.DBSource)
+		require.NoError(t, err)
+		poolConfig.ConnConfig.Tracer = NewQueryTracer()
+
+		pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+		require.NoError(t, err)
+		defer pool.Close()
+
+		account := createRandomAccount(t)
+		_, err = New(pool).GetAccount(context.Background(), account.ID)
+		require.NoError(t, err)
+
+		_, err = New(pool).GetAccount(context.Background(), 0)
+		require.ErrorIs(t, translateError(err), ErrRecordNotFound)
+
+		queries := endedSpans(spans, "GetAccount")
+		require.Len(t, queries, 2)
+		for _, query := range queries {
+			require.Contains(t, query.Attributes(), attribute.String("db.statement", getAccount))
+			require.Contains(t, query.Attributes(), attribute.String("db.system", "postgresql"))
+		}
+	})
+
+	t.Run("TransferTx", func(t *testing.T) {
+		store := NewStore(testDB)
+		account1 := createRandomAccountWithBalance(t, 100)
+		account2 := createRandomAccountWithBalance(t, 100)
+
+		_, err := store.TransferTx(context.Background(), TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        10,
+		})
+		require.NoError(t, err)
+
+		transfers := endedSpans(spans, "TransferTx")
+		require.Len(t, transfers, 1)
+		transfer := transfers[0]
+		require.Contains(t, transfer.Attributes(), attribute.Int64("transfer.amount", 10))
+
+		// every step of the transfer is a child of its span
+		for _, name := range []string{"CreateEntries", "UpdateBalances"} {
+			steps := endedSpans(spans, name)
+			require.Len(t, steps, 1)
+			require.Equal(t, transfer.SpanContext().SpanID(), steps[0].Parent().SpanID())
+		}
+	})
+}
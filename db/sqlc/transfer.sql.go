@@ -8,9 +8,100 @@ package db
 import (
 	"context"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const createFXTransfer = `-- name: CreateFXTransfer :one
+INSERT INTO transfers (
+  from_account_id,
+  to_account_id,
+  amount,
+  to_amount,
+  fx_rate,
+  quote_id
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+) RETURNING id, from_account_id, to_account_id, amount, created_at, to_amount, fx_rate, quote_id, reversal_of, from_balance_after, to_balance_after
+`
+
+type CreateFXTransferParams struct {
+	FromAccountID pgtype.Int8    `json:"from_account_id"`
+	ToAccountID   pgtype.Int8    `json:"to_account_id"`
+	Amount        int64          `json:"amount"`
+	ToAmount      pgtype.Int8    `json:"to_amount"`
+	FxRate        pgtype.Numeric `json:"fx_rate"`
+	QuoteID       uuid.NullUUID  `json:"quote_id"`
+}
+
+func (q *Queries) CreateFXTransfer(ctx context.Context, arg CreateFXTransferParams) (Transfer, error) {
+	row := q.db.QueryRow(ctx, createFXTransfer,
+		arg.FromAccountID,
+		arg.ToAccountID,
+		arg.Amount,
+		arg.ToAmount,
+		arg.FxRate,
+		arg.QuoteID,
+	)
+	var i Transfer
+	err := row.Scan(
+		&i.ID,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.ToAmount,
+		&i.FxRate,
+		&i.QuoteID,
+		&i.ReversalOf,
+		&i.FromBalanceAfter,
+		&i.ToBalanceAfter,
+	)
+	return i, err
+}
+
+const createReversalTransfer = `-- name: CreateReversalTransfer :one
+INSERT INTO transfers (
+  from_account_id,
+  to_account_id,
+  amount,
+  reversal_of
+) VALUES (
+  $1, $2, $3, $4
+) RETURNING id, from_account_id, to_account_id, amount, created_at, to_amount, fx_rate, quote_id, reversal_of, from_balance_after, to_balance_after
+`
+
+type CreateReversalTransferParams struct {
+	FromAccountID pgtype.Int8 `json:"from_account_id"`
+	ToAccountID   pgtype.Int8 `json:"to_account_id"`
+	Amount        int64       `json:"amount"`
+	ReversalOf    pgtype.Int8 `json:"reversal_of"`
+}
+
+func (q *Queries) CreateReversalTransfer(ctx context.Context, arg CreateReversalTransferParams) (Transfer, error) {
+	row := q.db.QueryRow(ctx, createReversalTransfer,
+		arg.FromAccountID,
+		arg.ToAccountID,
+		arg.Amount,
+		arg.ReversalOf,
+	)
+	var i Transfer
+	err := row.Scan(
+		&i.ID,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.ToAmount,
+		&i.FxRate,
+		&i.QuoteID,
+		&i.ReversalOf,
+		&i.FromBalanceAfter,
+		&i.ToBalanceAfter,
+	)
+	return i, err
+}
+
 const createTransfer = `-- name: CreateTransfer :one
 INSERT INTO transfers (
   from_account_id,
@@ -18,7 +109,7 @@ INSERT INTO transfers (
   amount
 ) VALUES (
   $1, $2, $3
-) RETURNING id, from_account_id, to_account_id, amount, created_at
+) RETURNING id, from_account_id, to_account_id, amount, created_at, to_amount, fx_rate, quote_id, reversal_of, from_balance_after, to_balance_after
 `
 
 type CreateTransferParams struct {
@@ -36,12 +127,31 @@ func (q *Queries) CreateTransfer(ctx context.Context, arg CreateTransferParams)
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.ToAmount,
+		&i.FxRate,
+		&i.QuoteID,
+		&i.ReversalOf,
+		&i.FromBalanceAfter,
+		&i.ToBalanceAfter,
 	)
 	return i, err
 }
 
+const getReversedAmount = `-- name: GetReversedAmount :one
+SELECT COALESCE(SUM(amount), 0)::bigint FROM transfers
+WHERE reversal_of = $1
+`
+
+// the total amount already moved back by reversals of the transfer
+func (q *Queries) GetReversedAmount(ctx context.Context, reversalOf pgtype.Int8) (int64, error) {
+	row := q.db.QueryRow(ctx, getReversedAmount, reversalOf)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
 const getTransfer = `-- name: GetTransfer :one
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers
+SELECT id, from_account_id, to_account_id, amount, created_at, to_amount, fx_rate, quote_id, reversal_of, from_balance_after, to_balance_after FROM transfers
 WHERE id = $1 LIMIT 1
 `
 
@@ -54,12 +164,161 @@ func (q *Queries) GetTransfer(ctx context.Context, id int64) (Transfer, error) {
 		&i.ToAccountID,
 		&i.Amount,
 		&i.CreatedAt,
+		&i.ToAmount,
+		&i.FxRate,
+		&i.QuoteID,
+		&i.ReversalOf,
+		&i.FromBalanceAfter,
+		&i.ToBalanceAfter,
+	)
+	return i, err
+}
+
+const getTransferForUpdate = `-- name: GetTransferForUpdate :one
+SELECT id, from_account_id, to_account_id, amount, created_at, to_amount, fx_rate, quote_id, reversal_of, from_balance_after, to_balance_after FROM transfers
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) GetTransferForUpdate(ctx context.Context, id int64) (Transfer, error) {
+	row := q.db.QueryRow(ctx, getTransferForUpdate, id)
+	var i Transfer
+	err := row.Scan(
+		&i.ID,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.ToAmount,
+		&i.FxRate,
+		&i.QuoteID,
+		&i.ReversalOf,
+		&i.FromBalanceAfter,
+		&i.ToBalanceAfter,
 	)
 	return i, err
 }
 
+const listAccountTransfers = `-- name: ListAccountTransfers :many
+WITH account_transfers AS (
+  SELECT
+    transfers.id, transfers.from_account_id, transfers.to_account_id, transfers.amount, transfers.created_at, transfers.to_amount, transfers.fx_rate, transfers.quote_id, transfers.reversal_of, transfers.from_balance_after, transfers.to_balance_after,
+    CASE
+      WHEN from_account_id = $8::bigint THEN amount
+      ELSE COALESCE(to_amount, amount)
+    END AS account_amount,
+    CASE
+      WHEN to_account_id = $8::bigint THEN to_balance_after
+      ELSE from_balance_after
+    END AS running_balance
+  FROM transfers
+  WHERE from_account_id = $8::bigint OR to_account_id = $8::bigint
+)
+SELECT
+  id,
+  from_account_id,
+  to_account_id,
+  amount,
+  to_amount,
+  fx_rate,
+  quote_id,
+  reversal_of,
+  created_at,
+  account_amount::bigint AS account_amount,
+  running_balance::bigint AS running_balance
+FROM account_transfers
+WHERE
+  (created_at, id) > (
+    COALESCE($1::timestamptz, '-infinity'),
+    COALESCE($2::bigint, 0)
+  ) AND
+  ($3::timestamptz IS NULL OR created_at >= $3) AND
+  ($4::timestamptz IS NULL OR created_at < $4) AND
+  ($5::bigint IS NULL OR account_amount >= $5) AND
+  ($6::bigint IS NULL OR account_amount <= $6) AND
+  ($7::text IS NULL OR
+    ($7 = 'credit' AND to_account_id = $8::bigint) OR
+    ($7 = 'debit' AND from_account_id = $8::bigint))
+ORDER BY created_at, id
+LIMIT $10
+OFFSET $9
+`
+
+type ListAccountTransfersParams struct {
+	AfterCreatedAt pgtype.Timestamptz `json:"after_created_at"`
+	AfterID        pgtype.Int8        `json:"after_id"`
+	StartTime      pgtype.Timestamptz `json:"start_time"`
+	EndTime        pgtype.Timestamptz `json:"end_time"`
+	MinAmount      pgtype.Int8        `json:"min_amount"`
+	MaxAmount      pgtype.Int8        `json:"max_amount"`
+	Direction      pgtype.Text        `json:"direction"`
+	AccountID      int64              `json:"account_id"`
+	Offset         int32              `json:"offset"`
+	Limit          int32              `json:"limit"`
+}
+
+type ListAccountTransfersRow struct {
+	ID             int64              `json:"id"`
+	FromAccountID  pgtype.Int8        `json:"from_account_id"`
+	ToAccountID    pgtype.Int8        `json:"to_account_id"`
+	Amount         int64              `json:"amount"`
+	ToAmount       pgtype.Int8        `json:"to_amount"`
+	FxRate         pgtype.Numeric     `json:"fx_rate"`
+	QuoteID        uuid.NullUUID      `json:"quote_id"`
+	ReversalOf     pgtype.Int8        `json:"reversal_of"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+	AccountAmount  int64              `json:"account_amount"`
+	RunningBalance int64              `json:"running_balance"`
+}
+
+// running_balance is the account balance right after the transfer
+// rows come after the (after_created_at, after_id) keyset position, or from the start if it is null
+// account_amount is the amount in the currency of the account, which differs on the credit side of a cross-currency transfer
+func (q *Queries) ListAccountTransfers(ctx context.Context, arg ListAccountTransfersParams) ([]ListAccountTransfersRow, error) {
+	rows, err := q.db.Query(ctx, listAccountTransfers,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.StartTime,
+		arg.EndTime,
+		arg.MinAmount,
+		arg.MaxAmount,
+		arg.Direction,
+		arg.AccountID,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListAccountTransfersRow{}
+	for rows.Next() {
+		var i ListAccountTransfersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.FromAccountID,
+			&i.ToAccountID,
+			&i.Amount,
+			&i.ToAmount,
+			&i.FxRate,
+			&i.QuoteID,
+			&i.ReversalOf,
+			&i.CreatedAt,
+			&i.AccountAmount,
+			&i.RunningBalance,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listTransfers = `-- name: ListTransfers :many
-SELECT id, from_account_id, to_account_id, amount, created_at FROM transfers
+SELECT id, from_account_id, to_account_id, amount, created_at, to_amount, fx_rate, quote_id, reversal_of, from_balance_after, to_balance_after FROM transfers
 WHERE 
     from_account_id = $1 OR
     to_account_id = $2
@@ -95,6 +354,12 @@ func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([
 			&i.ToAccountID,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.ToAmount,
+			&i.FxRate,
+			&i.QuoteID,
+			&i.ReversalOf,
+			&i.FromBalanceAfter,
+			&i.ToBalanceAfter,
 		); err != nil {
 			return nil, err
 		}
@@ -105,3 +370,38 @@ func (q *Queries) ListTransfers(ctx context.Context, arg ListTransfersParams) ([
 	}
 	return items, nil
 }
+
+const setTransferBalancesAfter = `-- name: SetTransferBalancesAfter :one
+UPDATE transfers
+SET
+  from_balance_after = $1,
+  to_balance_after = $2
+WHERE id = $3
+RETURNING id, from_account_id, to_account_id, amount, created_at, to_amount, fx_rate, quote_id, reversal_of, from_balance_after, to_balance_after
+`
+
+type SetTransferBalancesAfterParams struct {
+	FromBalanceAfter pgtype.Int8 `json:"from_balance_after"`
+	ToBalanceAfter   pgtype.Int8 `json:"to_balance_after"`
+	ID               int64       `json:"id"`
+}
+
+// the balances are known only once the accounts of the transfer are updated
+func (q *Queries) SetTransferBalancesAfter(ctx context.Context, arg SetTransferBalancesAfterParams) (Transfer, error) {
+	row := q.db.QueryRow(ctx, setTransferBalancesAfter, arg.FromBalanceAfter, arg.ToBalanceAfter, arg.ID)
+	var i Transfer
+	err := row.Scan(
+		&i.ID,
+		&i.FromAccountID,
+		&i.ToAccountID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.ToAmount,
+		&i.FxRate,
+		&i.QuoteID,
+		&i.ReversalOf,
+		&i.FromBalanceAfter,
+		&i.ToBalanceAfter,
+	)
+	return i, err
+}
@@ -84,3 +84,77 @@ func TestListTransfer(t *testing.T) {
 	}
 
 }
+
+func TestListAccountTransfers(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := createRandomAccountWithBalance(t, transferTestBalance)
+	account2 := createRandomAccountWithBalance(t, transferTestBalance)
+	account3 := createRandomAccountWithBalance(t, transferTestBalance)
+
+	amounts := []int64{10, 20, 30, 40}
+	for i, amount := range amounts {
+		arg := TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        amount,
+		}
+		if i%2 == 1 {
+			arg.FromAccountID, arg.ToAccountID = arg.ToAccountID, arg.FromAccountID
+		}
+
+		_, err := store.TransferTx(context.Background(), arg)
+		require.NoError(t, err)
+	}
+
+	// a transfer not involving account1 is left out
+	_, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account2.ID,
+		ToAccountID:   account3.ID,
+		Amount:        5,
+	})
+	require.NoError(t, err)
+
+	transfers, err := testQueries.ListAccountTransfers(context.Background(), ListAccountTransfersParams{
+		AccountID: account1.ID,
+		Limit:     10,
+		Offset:    0,
+	})
+	require.NoError(t, err)
+	require.Len(t, transfers, len(amounts))
+
+	balance := account1.Balance
+	for _, transfer := range transfers {
+		if transfer.FromAccountID.Int64 == account1.ID {
+			balance -= transfer.Amount
+		} else {
+			require.Equal(t, account1.ID, transfer.ToAccountID.Int64)
+			balance += transfer.Amount
+		}
+		require.Equal(t, balance, transfer.RunningBalance)
+	}
+
+	debits, err := testQueries.ListAccountTransfers(context.Background(), ListAccountTransfersParams{
+		AccountID: account1.ID,
+		Direction: pgtype.Text{String: "debit", Valid: true},
+		MinAmount: pgtype.Int8{Int64: 20, Valid: true},
+		Limit:     10,
+		Offset:    0,
+	})
+	require.NoError(t, err)
+	require.Len(t, debits, 1)
+	require.Equal(t, account1.ID, debits[0].FromAccountID.Int64)
+	require.Equal(t, int64(30), debits[0].Amount)
+
+	page, err := testQueries.ListAccountTransfers(context.Background(), ListAccountTransfersParams{
+		AccountID: account1.ID,
+		StartTime: pgtype.Timestamptz{Time: time.Now().Add(-time.Hour), Valid: true},
+		EndTime:   pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+		Limit:     2,
+		Offset:    2,
+	})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	require.Equal(t, transfers[2].ID, page[0].ID)
+	require.Equal(t, transfers[2].RunningBalance, page[0].RunningBalance)
+}
@@ -0,0 +1,252 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Postgres error codes of transactions that failed only because of concurrent transactions,
+// so running them again may succeed
+const (
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+// txRetryPolicy bounds the retries of a transaction after a serialization failure or a deadlock
+type txRetryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+var defaultTxRetryPolicy = txRetryPolicy{
+	maxAttempts: 5,
+	baseDelay:   10 * time.Millisecond,
+	maxDelay:    500 * time.Millisecond,
+}
+
+// backoff returns the delay before the given retry, starting at 1.
+// It doubles with every retry up to maxDelay, and half of it is random
+// so transactions that conflicted do not collide again.
+func (policy txRetryPolicy) backoff(retry int) time.Duration {
+	delay := policy.maxDelay
+	if retry < 32 && policy.baseDelay<<(retry-1) < policy.maxDelay {
+		delay = policy.baseDelay << (retry - 1)
+	}
+
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+// TxStats counts the outcomes of the transactions run by the store
+type TxStats struct {
+	Committed  int64
+	RolledBack int64
+	// SerializationRetries and DeadlockRetries count the attempts run again
+	// after a serialization failure or a deadlock
+	SerializationRetries int64
+	DeadlockRetries      int64
+	// RetriesExhausted counts the transactions that still failed after their last attempt
+	RetriesExhausted int64
+	// Durations counts the transactions by how long they took, retries included
+	Durations TxDurations
+}
+
+// TxDurationBuckets are the upper bounds in seconds of the buckets counting transaction durations
+var TxDurationBuckets = [...]float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// TxDurations is a histogram of transaction durations
+type TxDurations struct {
+	// Buckets counts the transactions that took at most the bound of TxDurationBuckets with the same index
+	Buckets [len(TxDurationBuckets)]int64
+	Count   int64
+	Sum     time.Duration
+}
+
+type txStats struct {
+	committed            atomic.Int64
+	rolledBack           atomic.Int64
+	serializationRetries atomic.Int64
+	deadlockRetries      atomic.Int64
+	retriesExhausted     atomic.Int64
+	durationBuckets      [len(TxDurationBuckets)]atomic.Int64
+	durationCount        atomic.Int64
+	durationSum          atomic.Int64
+}
+
+// observeDuration counts a transaction in the buckets its duration fits in
+func (stats *txStats) observeDuration(duration time.Duration) {
+	for i, bound := range TxDurationBuckets {
+		if duration.Seconds() <= bound {
+			stats.durationBuckets[i].Add(1)
+		}
+	}
+	stats.durationCount.Add(1)
+	stats.durationSum.Add(int64(duration))
+}
+
+// TxStats returns the counts of the transactions run by the store since it was created
+func (store *SQLStore) TxStats() TxStats {
+	return TxStats{
+		Committed:            store.txStats.committed.Load(),
+		RolledBack:           store.txStats.rolledBack.Load(),
+		SerializationRetries: store.txStats.serializationRetries.Load(),
+		DeadlockRetries:      store.txStats.deadlockRetries.Load(),
+		RetriesExhausted:     store.txStats.retriesExhausted.Load(),
+		Durations:            store.txStats.durations(),
+	}
+}
+
+func (stats *txStats) durations() TxDurations {
+	durations := TxDurations{
+		Count: stats.durationCount.Load(),
+		Sum:   time.Duration(stats.durationSum.Load()),
+	}
+	for i := range stats.durationBuckets {
+		durations.Buckets[i] = stats.durationBuckets[i].Load()
+	}
+	return durations
+}
+
+// txOption changes the options of a single transaction
+type txOption func(*pgx.TxOptions)
+
+// withIsolation runs the transaction at the isolation level instead of the default of the database
+func withIsolation(level pgx.TxIsoLevel) txOption {
+	return func(options *pgx.TxOptions) {
+		options.IsoLevel = level
+	}
+}
+
+// isolationLevels are the isolation levels transactions can be configured with, by name.
+// An empty name keeps the default of the database.
+var isolationLevels = map[string]pgx.TxIsoLevel{
+	"":                "",
+	"read_committed":  pgx.ReadCommitted,
+	"repeatable_read": pgx.RepeatableRead,
+	"serializable":    pgx.Serializable,
+}
+
+// ParseIsolationLevel returns the isolation level with the name:
+// read_committed, repeatable_read, serializable, or empty for the default of the database
+func ParseIsolationLevel(name string) (pgx.TxIsoLevel, error) {
+	level, ok := isolationLevels[name]
+	if !ok {
+		return "", fmt.Errorf("unsupported isolation level %q", name)
+	}
+	return level, nil
+}
+
+// txBeginner begins transactions, like *pgxpool.Pool and *pgx.Conn
+type txBeginner interface {
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
+// savepointBeginner begins savepoints in an open transaction, like pgx.Tx
+type savepointBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// execTx executes a function within a database transaction.
+// When the transaction fails with a serialization failure or a deadlock it is retried
+// with a backoff, so fn may run more than once and must not keep state from an earlier attempt.
+// When the store runs in an outer transaction, fn runs in a savepoint of it:
+// the options do not apply and failures are not retried, since they abort the outer transaction.
+func (store *SQLStore) execTx(ctx context.Context, fn func(*Queries) error, options ...txOption) error {
+	var txOptions pgx.TxOptions
+	for _, option := range options {
+		option(&txOptions)
+	}
+
+	start := time.Now()
+	defer func() {
+		store.txStats.observeDuration(time.Since(start))
+	}()
+
+	for attempt := 1; ; attempt++ {
+		err := store.runTx(ctx, txOptions, fn)
+
+		code := retryableCode(err)
+		if code == "" {
+			return err
+		}
+		if _, ok := store.db.(txBeginner); !ok {
+			return err
+		}
+		if attempt >= store.retry.maxAttempts {
+			store.txStats.retriesExhausted.Add(1)
+			return fmt.Errorf("transaction failed after %d attempts: %w", attempt, err)
+		}
+
+		switch code {
+		case serializationFailure:
+			store.txStats.serializationRetries.Add(1)
+		case deadlockDetected:
+			store.txStats.deadlockRetries.Add(1)
+		}
+		trace.SpanFromContext(ctx).AddEvent("retry transaction", trace.WithAttributes(
+			attribute.Int("db.tx_attempt", attempt),
+			attribute.String("db.error_code", code),
+		))
+
+		timer := time.NewTimer(store.retry.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// runTx runs a single attempt of a transaction
+func (store *SQLStore) runTx(ctx context.Context, txOptions pgx.TxOptions, fn func(*Queries) error) error {
+	var tx pgx.Tx
+	var err error
+	switch db := store.db.(type) {
+	case txBeginner:
+		tx, err = db.BeginTx(ctx, txOptions)
+	case savepointBeginner:
+		tx, err = db.Begin(ctx)
+	default:
+		return fmt.Errorf("cannot begin a transaction on %T", store.db)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := fn(New(translateErrors(tx))); err != nil {
+		store.txStats.rolledBack.Add(1)
+		// roll back even when ctx is canceled, so the connection goes back to the pool clean
+		if rbErr := tx.Rollback(context.WithoutCancel(ctx)); rbErr != nil {
+			return fmt.Errorf("tx err: %w, rollback err: %v", err, rbErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		store.txStats.rolledBack.Add(1)
+		return translateError(err)
+	}
+
+	store.txStats.committed.Add(1)
+	return nil
+}
+
+// retryableCode returns the Postgres error code of err if running its transaction again may succeed
+func retryableCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && (pgErr.Code == serializationFailure || pgErr.Code == deadlockDetected) {
+		return pgErr.Code
+	}
+	return ""
+}
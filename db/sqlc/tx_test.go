@@ -0,0 +1,221 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecTxRetriesSerializationFailure(t *testing.T) {
+	store := NewStore(testDB).(*SQLStore)
+	account := createRandomAccountWithBalance(t, 100)
+
+	n := 2
+	var read sync.WaitGroup
+	read.Add(n)
+
+	errs := make(chan error)
+	for i := 0; i < n; i++ {
+		go func() {
+			attempt := 0
+			errs <- store.execTx(context.Background(), func(q *Queries) error {
+				attempt++
+
+				current, err := q.GetAccount(context.Background(), account.ID)
+				if err != nil {
+					return err
+				}
+
+				// both transactions read the balance before either writes it,
+				// so the second write fails and only succeeds when retried
+				if attempt == 1 {
+					read.Done()
+					read.Wait()
+				}
+
+				_, err = q.UpdateAccount(context.Background(), UpdateAccountParams{
+					ID:      account.ID,
+					Balance: current.Balance + 10,
+				})
+				return err
+			}, withIsolation(pgx.Serializable))
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	updated, err := testQueries.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, account.Balance+int64(n)*10, updated.Balance)
+	require.GreaterOrEqual(t, store.TxStats().SerializationRetries, int64(1))
+}
+
+func TestExecTxRetriesAreBounded(t *testing.T) {
+	store := NewStore(testDB).(*SQLStore)
+	store.retry = txRetryPolicy{maxAttempts: 3, baseDelay: time.Millisecond, maxDelay: time.Millisecond}
+
+	attempts := 0
+	err := store.execTx(context.Background(), func(q *Queries) error {
+		attempts++
+		return &pgconn.PgError{Code: deadlockDetected}
+	})
+	require.Error(t, err)
+	require.Equal(t, 3, attempts)
+
+	stats := store.TxStats()
+	require.Equal(t, int64(2), stats.DeadlockRetries)
+	require.Equal(t, int64(1), stats.RetriesExhausted)
+	require.Equal(t, int64(3), stats.RolledBack)
+	require.Equal(t, int64(1), stats.Durations.Count)
+}
+
+func TestExecTxStopsRetryingWhenCanceled(t *testing.T) {
+	store := NewStore(testDB).(*SQLStore)
+	store.retry = txRetryPolicy{maxAttempts: 5, baseDelay: time.Minute, maxDelay: time.Minute}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	attempts := 0
+	err := store.execTx(ctx, func(q *Queries) error {
+		attempts++
+		return &pgconn.PgError{Code: serializationFailure}
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, 1, attempts)
+}
+
+func TestExecTxOnConn(t *testing.T) {
+	conn, err := testDB.(*pgxpool.Pool).Acquire(context.Background())
+	require.NoError(t, err)
+	defer conn.Release()
+
+	store := NewStore(conn.Conn())
+	account := createRandomAccountWithBalance(t, 100)
+
+	result, err := store.DepositTx(context.Background(), DepositTxParams{AccountID: account.ID, Amount: 10})
+	require.NoError(t, err)
+	require.Equal(t, account.Balance+10, result.Account.Balance)
+}
+
+func TestExecTxInOuterTx(t *testing.T) {
+	account := createRandomAccountWithBalance(t, 100)
+
+	tx, err := testDB.(*pgxpool.Pool).Begin(context.Background())
+	require.NoError(t, err)
+
+	store := NewStore(tx).(*SQLStore)
+	result, err := store.DepositTx(context.Background(), DepositTxParams{AccountID: account.ID, Amount: 10})
+	require.NoError(t, err)
+	require.Equal(t, account.Balance+10, result.Account.Balance)
+
+	// a failure rolls back the savepoint only
+	failure := errors.New("failure")
+	err = store.execTx(context.Background(), func(q *Queries) error {
+		if _, err := q.AddAccountBalance(context.Background(), AddAccountBalanceParams{ID: account.ID, Amount: 10}); err != nil {
+			return err
+		}
+		return failure
+	})
+	require.ErrorIs(t, err, failure)
+
+	inTx, err := store.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, account.Balance+10, inTx.Balance)
+
+	// rolling back the outer transaction discards the deposit
+	require.NoError(t, tx.Rollback(context.Background()))
+
+	outside, err := testQueries.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, account.Balance, outside.Balance)
+}
+
+func TestTxRetryBackoff(t *testing.T) {
+	policy := txRetryPolicy{maxAttempts: 10, baseDelay: 10 * time.Millisecond, maxDelay: 100 * time.Millisecond}
+
+	for retry := 1; retry < 40; retry++ {
+		delay := policy.backoff(retry)
+
+		expected := policy.maxDelay
+		if retry <= 4 {
+			expected = policy.baseDelay << (retry - 1)
+		}
+		require.GreaterOrEqual(t, delay, expected/2)
+		require.LessOrEqual(t, delay, expected)
+	}
+}
+
+func TestTxStatsDurations(t *testing.T) {
+	var stats txStats
+	stats.observeDuration(time.Millisecond)
+	stats.observeDuration(30 * time.Millisecond)
+	stats.observeDuration(10 * time.Second)
+
+	durations := stats.durations()
+	require.Equal(t, int64(3), durations.Count)
+	require.Equal(t, 10*time.Second+31*time.Millisecond, durations.Sum)
+	for i, bound := range TxDurationBuckets {
+		switch {
+		case bound < 0.03:
+			require.Equalf(t, int64(1), durations.Buckets[i], "bucket %v", bound)
+		default:
+			require.Equalf(t, int64(2), durations.Buckets[i], "bucket %v", bound)
+		}
+	}
+}
+
+func TestParseIsolationLevel(t *testing.T) {
+	for name, level := range map[string]pgx.TxIsoLevel{
+		"":                "",
+		"read_committed":  pgx.ReadCommitted,
+		"repeatable_read": pgx.RepeatableRead,
+		"serializable":    pgx.Serializable,
+	} {
+		parsed, err := ParseIsolationLevel(name)
+		require.NoError(t, err)
+		require.Equal(t, level, parsed)
+	}
+
+	_, err := ParseIsolationLevel("read committed")
+	require.Error(t, err)
+}
+
+func TestTransferTxWithIsolation(t *testing.T) {
+	store := NewStore(testDB, WithTransferIsolation(pgx.Serializable)).(*SQLStore)
+	require.Equal(t, pgx.Serializable, store.transferIsolation)
+
+	account1 := createRandomAccountWithBalance(t, transferTestBalance)
+	account2 := createAccountInCurrency(t, account1.Currency, 0)
+
+	// concurrent serializable transfers between the same accounts conflict, and are retried until they all commit
+	n := 3
+	errs := make(chan error)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := store.TransferTx(context.Background(), TransferTxParams{
+				FromAccountID: account1.ID,
+				ToAccountID:   account2.ID,
+				Amount:        10,
+			})
+			errs <- err
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	updated, err := store.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(n)*10, updated.Balance)
+}
@@ -7,12 +7,14 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (username, hashed_password, full_name, email)
 VALUES ($1, $2, $3, $4)
-RETURNING username, hashed_password, full_name, email, password_changed_at, created_at
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, tokens_revoked_at, role, failed_login_attempts, locked_until
 `
 
 type CreateUserParams struct {
@@ -37,12 +39,16 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
+		&i.TokensRevokedAt,
+		&i.Role,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
 	)
 	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT username, hashed_password, full_name, email, password_changed_at, created_at FROM users
+SELECT username, hashed_password, full_name, email, password_changed_at, created_at, tokens_revoked_at, role, failed_login_attempts, locked_until FROM users
 WHERE username = $1
 LIMIT 1
 `
@@ -57,6 +63,121 @@ func (q *Queries) GetUser(ctx context.Context, username string) (User, error) {
 		&i.Email,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
+		&i.TokensRevokedAt,
+		&i.Role,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+	)
+	return i, err
+}
+
+const listUserTokenRevocations = `-- name: ListUserTokenRevocations :many
+SELECT username, tokens_revoked_at FROM users
+WHERE tokens_revoked_at > $1
+`
+
+type ListUserTokenRevocationsRow struct {
+	Username        string             `json:"username"`
+	TokensRevokedAt pgtype.Timestamptz `json:"tokens_revoked_at"`
+}
+
+func (q *Queries) ListUserTokenRevocations(ctx context.Context, since pgtype.Timestamptz) ([]ListUserTokenRevocationsRow, error) {
+	rows, err := q.db.Query(ctx, listUserTokenRevocations, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListUserTokenRevocationsRow{}
+	for rows.Next() {
+		var i ListUserTokenRevocationsRow
+		if err := rows.Scan(&i.Username, &i.TokensRevokedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recordFailedLogin = `-- name: RecordFailedLogin :one
+UPDATE users
+SET
+  failed_login_attempts = CASE
+    WHEN failed_login_attempts + 1 >= $1::int THEN 0
+    ELSE failed_login_attempts + 1
+  END,
+  locked_until = CASE
+    WHEN failed_login_attempts + 1 >= $1::int THEN $2::timestamptz
+    ELSE locked_until
+  END
+WHERE username = $3
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, tokens_revoked_at, role, failed_login_attempts, locked_until
+`
+
+type RecordFailedLoginParams struct {
+	MaxAttempts int32              `json:"max_attempts"`
+	LockedUntil pgtype.Timestamptz `json:"locked_until"`
+	Username    string             `json:"username"`
+}
+
+// The attempt reaching max_attempts locks the user until locked_until and starts the count again.
+func (q *Queries) RecordFailedLogin(ctx context.Context, arg RecordFailedLoginParams) (User, error) {
+	row := q.db.QueryRow(ctx, recordFailedLogin, arg.MaxAttempts, arg.LockedUntil, arg.Username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.TokensRevokedAt,
+		&i.Role,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+	)
+	return i, err
+}
+
+const resetFailedLogins = `-- name: ResetFailedLogins :exec
+UPDATE users
+SET failed_login_attempts = 0
+WHERE username = $1 AND failed_login_attempts > 0
+`
+
+func (q *Queries) ResetFailedLogins(ctx context.Context, username string) error {
+	_, err := q.db.Exec(ctx, resetFailedLogins, username)
+	return err
+}
+
+const revokeUserTokens = `-- name: RevokeUserTokens :one
+UPDATE users
+SET tokens_revoked_at = $1
+WHERE username = $2
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, tokens_revoked_at, role, failed_login_attempts, locked_until
+`
+
+type RevokeUserTokensParams struct {
+	RevokedAt pgtype.Timestamptz `json:"revoked_at"`
+	Username  string             `json:"username"`
+}
+
+func (q *Queries) RevokeUserTokens(ctx context.Context, arg RevokeUserTokensParams) (User, error) {
+	row := q.db.QueryRow(ctx, revokeUserTokens, arg.RevokedAt, arg.Username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.TokensRevokedAt,
+		&i.Role,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
 	)
 	return i, err
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/niloy104/simplebank/util"
 	"github.com/stretchr/testify/require"
 )
@@ -27,6 +28,7 @@ func createRandomUser(t *testing.T) User {
 	require.Equal(t, arg.HashedPassword, user.HashedPassword)
 	require.Equal(t, arg.FullName, user.FullName)
 	require.Equal(t, arg.Email, user.Email)
+	require.Equal(t, util.DepositorRole, user.Role)
 
 	require.True(t, user.PasswordChangedAt.Time.IsZero())
 	require.NotZero(t, user.CreatedAt)
@@ -51,3 +53,67 @@ func TestGetUser(t *testing.T) {
 	require.WithinDuration(t, user1.PasswordChangedAt.Time, user2.PasswordChangedAt.Time, time.Second)
 	require.WithinDuration(t, user1.CreatedAt.Time, user2.CreatedAt.Time, time.Second)
 }
+
+func TestRevokeUserTokens(t *testing.T) {
+	user1 := createRandomUser(t)
+	require.True(t, user1.TokensRevokedAt.Time.IsZero())
+
+	revokedAt := time.Now()
+	user2, err := testQueries.RevokeUserTokens(context.Background(), RevokeUserTokensParams{
+		Username:  user1.Username,
+		RevokedAt: pgtype.Timestamptz{Time: revokedAt, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, user1.Username, user2.Username)
+	require.WithinDuration(t, revokedAt, user2.TokensRevokedAt.Time, time.Second)
+
+	since := pgtype.Timestamptz{Time: revokedAt.Add(-time.Minute), Valid: true}
+	revocations, err := testQueries.ListUserTokenRevocations(context.Background(), since)
+	require.NoError(t, err)
+
+	var found bool
+	for _, revocation := range revocations {
+		if revocation.Username == user1.Username {
+			found = true
+			require.WithinDuration(t, revokedAt, revocation.TokensRevokedAt.Time, time.Second)
+		}
+	}
+	require.True(t, found)
+}
+
+func TestRecordFailedLogin(t *testing.T) {
+	user := createRandomUser(t)
+	require.Zero(t, user.FailedLoginAttempts)
+	require.True(t, user.LockedUntil.Time.IsZero())
+
+	lockedUntil := time.Now().Add(time.Minute)
+	arg := RecordFailedLoginParams{
+		MaxAttempts: 3,
+		LockedUntil: pgtype.Timestamptz{Time: lockedUntil, Valid: true},
+		Username:    user.Username,
+	}
+
+	for i := int32(1); i < arg.MaxAttempts; i++ {
+		updated, err := testQueries.RecordFailedLogin(context.Background(), arg)
+		require.NoError(t, err)
+		require.Equal(t, i, updated.FailedLoginAttempts)
+		require.True(t, updated.LockedUntil.Time.IsZero())
+	}
+
+	// the last attempt locks the user and starts the count again
+	locked, err := testQueries.RecordFailedLogin(context.Background(), arg)
+	require.NoError(t, err)
+	require.Zero(t, locked.FailedLoginAttempts)
+	require.WithinDuration(t, lockedUntil, locked.LockedUntil.Time, time.Second)
+
+	_, err = testQueries.RecordFailedLogin(context.Background(), arg)
+	require.NoError(t, err)
+
+	err = testQueries.ResetFailedLogins(context.Background(), user.Username)
+	require.NoError(t, err)
+
+	reset, err := testQueries.GetUser(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Zero(t, reset.FailedLoginAttempts)
+	require.WithinDuration(t, lockedUntil, reset.LockedUntil.Time, time.Second)
+}
@@ -2,35 +2,168 @@ package main
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/niloy104/simplebank/api"
 	db "github.com/niloy104/simplebank/db/sqlc"
 	"github.com/niloy104/simplebank/util"
+	"google.golang.org/grpc"
 )
 
 func main() {
 	config, err := util.LoadConfig(".")
 	if err != nil {
-		log.Fatal("cannot load config: ", err)
+		fatal("cannot load config", err)
 	}
 
-	ctx := context.Background()
-	pool, err := pgxpool.New(ctx, config.DBSource)
+	logger, err := util.NewLogger(os.Stdout, config.LogLevel)
 	if err != nil {
-		log.Fatal("cannot create db connection pool: ", err)
+		fatal("cannot create logger", err)
+	}
+	slog.SetDefault(logger)
+	gin.DebugPrintFunc = func(format string, values ...any) {
+		slog.Debug(strings.TrimSpace(fmt.Sprintf(format, values...)))
+	}
+
+	// the context is cancelled on SIGINT or SIGTERM, which starts the shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := util.SetupTracing(context.Background(), config)
+	if err != nil {
+		fatal("cannot set up tracing", err)
+	}
+
+	poolConfig, err := pgxpool.ParseConfig(config.DBSource)
+	if err != nil {
+		fatal("cannot parse db source", err)
+	}
+	poolConfig.ConnConfig.Tracer = db.NewQueryTracer()
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+	if err != nil {
+		fatal("cannot create db connection pool", err)
 	}
-	defer pool.Close()
 
-	store := db.NewStore(pool)
+	transferIsolation, err := db.ParseIsolationLevel(config.TransferIsolationLevel)
+	if err != nil {
+		fatal("cannot configure transfers", err)
+	}
+	store := db.NewStore(pool, db.WithTransferIsolation(transferIsolation))
 
 	server, err := api.NewServer(config, store)
 	if err != nil {
-		log.Fatal("cannot create server: ", err)
+		fatal("cannot create server", err)
+	}
+	server.RegisterPoolMetrics(pool)
+
+	var workers sync.WaitGroup
+	runWorker(ctx, &workers, server.SyncDenylist, config.DenylistSyncInterval)
+	runWorker(ctx, &workers, server.RunScheduledTransfers, config.SchedulerInterval)
+	runWorker(ctx, &workers, server.ExpireHolds, config.HoldExpiryInterval)
+	if config.IdempotencyKeyTTL > 0 {
+		runWorker(ctx, &workers, server.PruneIdempotencyKeys, config.IdempotencyKeyPruneInterval)
+	}
+	if config.LoginRateLimit > 0 {
+		runWorker(ctx, &workers, server.PruneRateLimits, config.LoginRateLimitPeriod)
 	}
 
-	if err := server.Start(config.ServerAddress); err != nil {
-		log.Fatal("cannot start server: ", err)
+	serverErrs := make(chan error, 4)
+	go runGRPCServer(server, config.GRPCServerAddress, serverErrs)
+	go runGatewayServer(ctx, server, config.GatewayAddress, serverErrs)
+	go runHTTPServer(server, config.ServerAddress, serverErrs)
+	go runMetricsServer(server, config.MetricsAddress, serverErrs)
+
+	select {
+	case <-ctx.Done():
+		slog.Info("shutting down")
+	case err := <-serverErrs:
+		slog.Error("shutting down after a server failed", slog.Any("error", err))
+	}
+	stop()
+
+	shutdown(server, &workers, pool, shutdownTracing, config.ShutdownTimeout)
+}
+
+// fatal logs the error the server cannot start without and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, slog.Any("error", err))
+	os.Exit(1)
+}
+
+// runWorker runs a background worker until ctx is cancelled
+func runWorker(ctx context.Context, workers *sync.WaitGroup, worker func(context.Context, time.Duration), interval time.Duration) {
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		worker(ctx, interval)
+	}()
+}
+
+// shutdown stops accepting requests, waits for the in-flight requests and the workers,
+// then closes the pool and flushes the spans. Whatever is still running after the timeout is cut off.
+func shutdown(server *api.Server, workers *sync.WaitGroup, pool *pgxpool.Pool, shutdownTracing func(context.Context) error, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("cannot shut down servers gracefully", slog.Any("error", err))
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		slog.Error("background workers did not stop in time")
+	}
+
+	pool.Close()
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("cannot flush spans", slog.Any("error", err))
+	}
+	slog.Info("shut down")
+}
+
+func runHTTPServer(server *api.Server, address string, errs chan<- error) {
+	slog.Info("start HTTP server", slog.String("address", address))
+	if err := server.Start(address); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errs <- err
+	}
+}
+
+func runGRPCServer(server *api.Server, address string, errs chan<- error) {
+	slog.Info("start gRPC server", slog.String("address", address))
+	if err := server.StartGRPC(address); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		errs <- err
+	}
+}
+
+func runGatewayServer(ctx context.Context, server *api.Server, address string, errs chan<- error) {
+	slog.Info("start gateway server", slog.String("address", address))
+	if err := server.StartGateway(ctx, address); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errs <- err
+	}
+}
+
+func runMetricsServer(server *api.Server, address string, errs chan<- error) {
+	slog.Info("start metrics server", slog.String("address", address))
+	if err := server.StartMetrics(address); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errs <- err
 	}
 }
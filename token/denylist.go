@@ -0,0 +1,81 @@
+package token
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Denylist keeps track of revoked tokens in memory
+type Denylist struct {
+	mu     sync.RWMutex
+	tokens map[uuid.UUID]time.Time
+	users  map[string]time.Time
+}
+
+// NewDenylist creates a new empty Denylist
+func NewDenylist() *Denylist {
+	return &Denylist{
+		tokens: make(map[uuid.UUID]time.Time),
+		users:  make(map[string]time.Time),
+	}
+}
+
+// Revoke marks a single token as revoked until it expires.
+// Revoking a session ID revokes every token issued for that session.
+func (denylist *Denylist) Revoke(tokenID uuid.UUID, expiredAt time.Time) {
+	denylist.mu.Lock()
+	defer denylist.mu.Unlock()
+
+	denylist.tokens[tokenID] = expiredAt
+}
+
+// RevokeUser marks every token of the user issued before revokedAt as revoked
+func (denylist *Denylist) RevokeUser(username string, revokedAt time.Time) {
+	denylist.mu.Lock()
+	defer denylist.mu.Unlock()
+
+	if current, ok := denylist.users[username]; ok && current.After(revokedAt) {
+		return
+	}
+	denylist.users[username] = revokedAt
+}
+
+// IsRevoked checks if the token payload has been revoked
+func (denylist *Denylist) IsRevoked(payload *Payload) bool {
+	denylist.mu.RLock()
+	defer denylist.mu.RUnlock()
+
+	if _, ok := denylist.tokens[payload.ID]; ok {
+		return true
+	}
+	if payload.SessionID != uuid.Nil {
+		if _, ok := denylist.tokens[payload.SessionID]; ok {
+			return true
+		}
+	}
+
+	revokedAt, ok := denylist.users[payload.Username]
+	return ok && !payload.IssuedAt.After(revokedAt)
+}
+
+// Prune removes entries that can no longer match a valid token.
+// Tokens are dropped once expired, users once every token issued before
+// their revocation time must have expired given the max token lifetime.
+func (denylist *Denylist) Prune(now time.Time, maxTokenDuration time.Duration) {
+	denylist.mu.Lock()
+	defer denylist.mu.Unlock()
+
+	for tokenID, expiredAt := range denylist.tokens {
+		if now.After(expiredAt) {
+			delete(denylist.tokens, tokenID)
+		}
+	}
+
+	for username, revokedAt := range denylist.users {
+		if now.After(revokedAt.Add(maxTokenDuration)) {
+			delete(denylist.users, username)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/niloy104/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDenylistRevoke(t *testing.T) {
+	denylist := NewDenylist()
+
+	payload1, err := NewPayload(util.RandomOwner(), util.DepositorRole, uuid.New(), time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	payload2, err := NewPayload(payload1.Username, util.DepositorRole, uuid.New(), time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	require.False(t, denylist.IsRevoked(payload1))
+
+	denylist.Revoke(payload1.ID, payload1.ExpiredAt)
+	require.True(t, denylist.IsRevoked(payload1))
+	require.False(t, denylist.IsRevoked(payload2))
+}
+
+func TestDenylistRevokeSession(t *testing.T) {
+	denylist := NewDenylist()
+	sessionID := uuid.New()
+
+	accessPayload, err := NewPayload(util.RandomOwner(), util.DepositorRole, sessionID, time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	refreshPayload, err := NewPayload(accessPayload.Username, util.DepositorRole, sessionID, time.Hour, TokenTypeRefreshToken)
+	require.NoError(t, err)
+
+	otherPayload, err := NewPayload(accessPayload.Username, util.DepositorRole, uuid.New(), time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	denylist.Revoke(sessionID, refreshPayload.ExpiredAt)
+	require.True(t, denylist.IsRevoked(accessPayload))
+	require.True(t, denylist.IsRevoked(refreshPayload))
+	require.False(t, denylist.IsRevoked(otherPayload))
+}
+
+func TestDenylistRevokeUser(t *testing.T) {
+	denylist := NewDenylist()
+	username := util.RandomOwner()
+
+	oldPayload, err := NewPayload(username, util.DepositorRole, uuid.New(), time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	otherPayload, err := NewPayload(util.RandomOwner(), util.DepositorRole, uuid.New(), time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	denylist.RevokeUser(username, time.Now())
+	require.True(t, denylist.IsRevoked(oldPayload))
+	require.False(t, denylist.IsRevoked(otherPayload))
+
+	time.Sleep(time.Millisecond)
+	newPayload, err := NewPayload(username, util.DepositorRole, uuid.New(), time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+	require.False(t, denylist.IsRevoked(newPayload))
+
+	// an older revocation must not override a newer one
+	denylist.RevokeUser(username, oldPayload.IssuedAt.Add(-time.Minute))
+	require.True(t, denylist.IsRevoked(oldPayload))
+}
+
+func TestDenylistPrune(t *testing.T) {
+	denylist := NewDenylist()
+
+	payload, err := NewPayload(util.RandomOwner(), util.DepositorRole, uuid.New(), time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	denylist.Revoke(payload.ID, payload.ExpiredAt)
+	denylist.RevokeUser(payload.Username, payload.IssuedAt)
+
+	denylist.Prune(time.Now(), time.Minute)
+	require.True(t, denylist.IsRevoked(payload))
+
+	denylist.Prune(time.Now().Add(2*time.Minute), time.Minute)
+	require.False(t, denylist.IsRevoked(payload))
+}
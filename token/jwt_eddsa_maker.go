@@ -0,0 +1,107 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// EdDSAJWTMaker is a JSON Web Token maker signing with Ed25519
+type EdDSAJWTMaker struct {
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+	keyID      string
+}
+
+// NewEdDSAJWTMaker creates a new EdDSAJWTMaker that can create and verify tokens
+func NewEdDSAJWTMaker(privateKey ed25519.PrivateKey) (Maker, error) {
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid private key size: must be exactly %d bytes", ed25519.PrivateKeySize)
+	}
+
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	maker := &EdDSAJWTMaker{
+		privateKey: privateKey,
+		publicKey:  publicKey,
+		keyID:      Ed25519KeyID(publicKey),
+	}
+	return maker, nil
+}
+
+// NewEdDSAJWTVerifier creates a new EdDSAJWTMaker that can only verify tokens
+func NewEdDSAJWTVerifier(publicKey ed25519.PublicKey) (Maker, error) {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key size: must be exactly %d bytes", ed25519.PublicKeySize)
+	}
+
+	maker := &EdDSAJWTMaker{
+		publicKey: publicKey,
+		keyID:     Ed25519KeyID(publicKey),
+	}
+	return maker, nil
+}
+
+// CreateToken creates a new token for a specific username, role, session, duration and type
+func (maker *EdDSAJWTMaker) CreateToken(username string, role string, sessionID uuid.UUID, duration time.Duration, tokenType TokenType) (string, *Payload, error) {
+	if maker.privateKey == nil {
+		return "", nil, ErrVerifyOnly
+	}
+
+	payload, err := NewPayload(username, role, sessionID, duration, tokenType)
+	if err != nil {
+		return "", payload, err
+	}
+
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodEdDSA, payload)
+	jwtToken.Header["kid"] = maker.keyID
+
+	token, err := jwtToken.SignedString(maker.privateKey)
+	return token, payload, err
+}
+
+// VerifyToken checks if the token is valid or not
+func (maker *EdDSAJWTMaker) VerifyToken(token string, tokenType TokenType) (*Payload, error) {
+	jwtToken, err := jwt.ParseWithClaims(
+		token,
+		&Payload{},
+		func(token *jwt.Token) (interface{}, error) {
+			if token.Method != jwt.SigningMethodEdDSA {
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			}
+			return maker.publicKey, nil
+		},
+	)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrExpiredToken
+		}
+		return nil, ErrInvalidToken
+	}
+
+	payload, ok := jwtToken.Claims.(*Payload)
+	if !ok || !jwtToken.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	err = payload.Valid(tokenType)
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// PublicKeys returns the key accepted when verifying tokens
+func (maker *EdDSAJWTMaker) PublicKeys() []PublicKey {
+	return []PublicKey{
+		{
+			KeyID:     maker.keyID,
+			Algorithm: jwt.SigningMethodEdDSA.Alg(),
+			Key:       maker.publicKey,
+		},
+	}
+}
@@ -0,0 +1,145 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+var (
+	ErrKeyNotFound     = errors.New("key not found in key ring")
+	ErrActiveKeyRetire = errors.New("cannot retire the active signing key")
+)
+
+// KeyRing holds the symmetric keys used to create and verify tokens.
+// Exactly one key is active and used to sign new tokens, while every key
+// in the ring is accepted when verifying tokens until it is retired.
+type KeyRing struct {
+	mu          sync.RWMutex
+	keys        map[string][]byte
+	activeKeyID string
+}
+
+// NewKeyRing creates a new key ring with a single active key
+func NewKeyRing(keyID string, symmetricKey string) (*KeyRing, error) {
+	ring := &KeyRing{
+		keys: make(map[string][]byte),
+	}
+
+	err := ring.AddKey(keyID, symmetricKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ring.activeKeyID = keyID
+	return ring, nil
+}
+
+// ParseKeyRing creates a key ring from a comma separated list of keyID:key pairs
+func ParseKeyRing(keys string, activeKeyID string) (*KeyRing, error) {
+	ring := &KeyRing{
+		keys: make(map[string][]byte),
+	}
+
+	for _, pair := range strings.Split(keys, ",") {
+		keyID, key, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid key ring entry %q: must be keyID:key", pair)
+		}
+
+		err := ring.AddKey(keyID, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := ring.Rotate(activeKeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ring, nil
+}
+
+// AddKey adds a key that is accepted for verification
+func (ring *KeyRing) AddKey(keyID string, symmetricKey string) error {
+	if keyID == "" {
+		return fmt.Errorf("key ID must not be empty")
+	}
+	if len(symmetricKey) != chacha20poly1305.KeySize {
+		return fmt.Errorf("invalid key size for %q: must be exactly %d characters", keyID, chacha20poly1305.KeySize)
+	}
+
+	ring.mu.Lock()
+	defer ring.mu.Unlock()
+
+	if _, ok := ring.keys[keyID]; ok {
+		return fmt.Errorf("duplicate key ID %q", keyID)
+	}
+
+	ring.keys[keyID] = []byte(symmetricKey)
+	return nil
+}
+
+// Rotate makes an existing key the active signing key
+func (ring *KeyRing) Rotate(keyID string) error {
+	ring.mu.Lock()
+	defer ring.mu.Unlock()
+
+	if _, ok := ring.keys[keyID]; !ok {
+		return fmt.Errorf("%w: %q", ErrKeyNotFound, keyID)
+	}
+
+	ring.activeKeyID = keyID
+	return nil
+}
+
+// Retire removes a key so tokens signed with it are no longer accepted
+func (ring *KeyRing) Retire(keyID string) error {
+	ring.mu.Lock()
+	defer ring.mu.Unlock()
+
+	if keyID == ring.activeKeyID {
+		return ErrActiveKeyRetire
+	}
+	if _, ok := ring.keys[keyID]; !ok {
+		return fmt.Errorf("%w: %q", ErrKeyNotFound, keyID)
+	}
+
+	delete(ring.keys, keyID)
+	return nil
+}
+
+// ActiveKey returns the ID and value of the key used to sign new tokens
+func (ring *KeyRing) ActiveKey() (string, []byte) {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+
+	return ring.activeKeyID, ring.keys[ring.activeKeyID]
+}
+
+// Key returns the key with the given ID
+func (ring *KeyRing) Key(keyID string) ([]byte, bool) {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+
+	key, ok := ring.keys[keyID]
+	return key, ok
+}
+
+// KeyIDs returns the IDs of every key accepted for verification
+func (ring *KeyRing) KeyIDs() []string {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+
+	keyIDs := make([]string, 0, len(ring.keys))
+	for keyID := range ring.keys {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Strings(keyIDs)
+	return keyIDs
+}
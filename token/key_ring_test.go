@@ -0,0 +1,164 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/niloy104/simplebank/util"
+	"github.com/o1egl/paseto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyRingRotation(t *testing.T) {
+	keyRing, err := NewKeyRing("a", util.RandomString(32))
+	require.NoError(t, err)
+
+	maker, err := NewPasetoKeyRingMaker(keyRing)
+	require.NoError(t, err)
+
+	tokenA, _, err := maker.CreateToken(util.RandomOwner(), util.DepositorRole, uuid.New(), time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	// rotate to key B: new tokens use B, tokens issued with A still verify
+	err = keyRing.AddKey("b", util.RandomString(32))
+	require.NoError(t, err)
+
+	err = keyRing.Rotate("b")
+	require.NoError(t, err)
+
+	tokenB, _, err := maker.CreateToken(util.RandomOwner(), util.DepositorRole, uuid.New(), time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	var footer pasetoFooter
+	err = paseto.ParseFooter(tokenB, &footer)
+	require.NoError(t, err)
+	require.Equal(t, "b", footer.KeyID)
+
+	_, err = maker.VerifyToken(tokenA, TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	_, err = maker.VerifyToken(tokenB, TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	// retire key A: tokens issued with A are rejected
+	err = keyRing.Retire("a")
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(tokenA, TokenTypeAccessToken)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+	require.Nil(t, payload)
+
+	_, err = maker.VerifyToken(tokenB, TokenTypeAccessToken)
+	require.NoError(t, err)
+}
+
+func TestKeyRingRetireActiveKey(t *testing.T) {
+	keyRing, err := NewKeyRing("a", util.RandomString(32))
+	require.NoError(t, err)
+
+	err = keyRing.Retire("a")
+	require.ErrorIs(t, err, ErrActiveKeyRetire)
+
+	err = keyRing.Retire("missing")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	err = keyRing.Rotate("missing")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestParseKeyRing(t *testing.T) {
+	keyA := util.RandomString(32)
+	keyB := util.RandomString(32)
+
+	keyRing, err := ParseKeyRing("a:"+keyA+", b:"+keyB, "b")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, keyRing.KeyIDs())
+
+	activeKeyID, activeKey := keyRing.ActiveKey()
+	require.Equal(t, "b", activeKeyID)
+	require.Equal(t, []byte(keyB), activeKey)
+
+	_, err = ParseKeyRing("a:"+keyA, "b")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	_, err = ParseKeyRing("a:"+keyA+",a:"+keyB, "a")
+	require.Error(t, err)
+
+	_, err = ParseKeyRing("a:short", "a")
+	require.Error(t, err)
+
+	_, err = ParseKeyRing(keyA, "a")
+	require.Error(t, err)
+}
+
+func TestPasetoMakerUnknownKeyID(t *testing.T) {
+	maker1, err := NewPasetoMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	keyRing, err := NewKeyRing("other", util.RandomString(32))
+	require.NoError(t, err)
+
+	maker2, err := NewPasetoKeyRingMaker(keyRing)
+	require.NoError(t, err)
+
+	token, _, err := maker1.CreateToken(util.RandomOwner(), util.DepositorRole, uuid.New(), time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	payload, err := maker2.VerifyToken(token, TokenTypeAccessToken)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+	require.Nil(t, payload)
+}
+
+func TestPasetoMakerLegacyToken(t *testing.T) {
+	symmetricKey := util.RandomString(32)
+
+	maker, err := NewPasetoMaker(symmetricKey)
+	require.NoError(t, err)
+
+	payload, err := NewPayload(util.RandomOwner(), util.DepositorRole, uuid.New(), time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	// tokens issued before key IDs were introduced carry no key ID footer
+	token, err := paseto.NewV2().Encrypt([]byte(symmetricKey), payload, nil)
+	require.NoError(t, err)
+
+	verified, err := maker.VerifyToken(token, TokenTypeAccessToken)
+	require.NoError(t, err)
+	require.Equal(t, payload.ID, verified.ID)
+}
+
+func TestPasetoMakerLegacyTokenAfterRotation(t *testing.T) {
+	legacyKey := util.RandomString(32)
+
+	keyRing, err := NewKeyRing(defaultKeyID, legacyKey)
+	require.NoError(t, err)
+
+	maker, err := NewPasetoKeyRingMaker(keyRing)
+	require.NoError(t, err)
+
+	payload, err := NewPayload(util.RandomOwner(), util.DepositorRole, uuid.New(), time.Minute, TokenTypeAccessToken)
+	require.NoError(t, err)
+
+	token, err := paseto.NewV2().Encrypt([]byte(legacyKey), payload, nil)
+	require.NoError(t, err)
+
+	// a token without a key ID footer is checked against every key, not only the active one
+	err = keyRing.AddKey("b", util.RandomString(32))
+	require.NoError(t, err)
+
+	err = keyRing.Rotate("b")
+	require.NoError(t, err)
+
+	verified, err := maker.VerifyToken(token, TokenTypeAccessToken)
+	require.NoError(t, err)
+	require.Equal(t, payload.ID, verified.ID)
+
+	// once the legacy key is retired, the token is rejected
+	err = keyRing.Retire(defaultKeyID)
+	require.NoError(t, err)
+
+	verified, err = maker.VerifyToken(token, TokenTypeAccessToken)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+	require.Nil(t, verified)
+}
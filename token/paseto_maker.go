@@ -1,53 +1,100 @@
 package token
 
 import (
-	"fmt"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/o1egl/paseto"
-	"golang.org/x/crypto/chacha20poly1305"
 )
 
+// defaultKeyID identifies the key of a maker created from a single symmetric key
+const defaultKeyID = "default"
+
 // PasetoMaker is a PASETO token maker
 type PasetoMaker struct {
-	paseto       *paseto.V2
-	symmetricKey []byte
+	paseto  *paseto.V2
+	keyRing *KeyRing
+}
+
+// pasetoFooter is the unencrypted footer identifying the key that encrypted the token
+type pasetoFooter struct {
+	KeyID string `json:"kid"`
 }
 
 // NewPasetoMaker creates a new PASETO maker
 func NewPasetoMaker(symmetricKey string) (Maker, error) {
-	if len(symmetricKey) != chacha20poly1305.KeySize {
-		return nil, fmt.Errorf("invalid key size: must be exactly 32 characters")
+	keyRing, err := NewKeyRing(defaultKeyID, symmetricKey)
+	if err != nil {
+		return nil, err
 	}
 
+	return NewPasetoKeyRingMaker(keyRing)
+}
+
+// NewPasetoKeyRingMaker creates a new PASETO maker that encrypts with the
+// active key of the ring and decrypts with any key still in the ring
+func NewPasetoKeyRingMaker(keyRing *KeyRing) (Maker, error) {
 	maker := &PasetoMaker{
-		paseto:       paseto.NewV2(),
-		symmetricKey: []byte(symmetricKey),
+		paseto:  paseto.NewV2(),
+		keyRing: keyRing,
 	}
 	return maker, nil
 }
 
-// CreateToken creates a new token for a specific username and duration
-func (maker *PasetoMaker) CreateToken(username string, duration time.Duration) (string, error) {
-	payload, err := NewPayload(username, duration)
+// CreateToken creates a new token for a specific username, role, session, duration and type
+func (maker *PasetoMaker) CreateToken(username string, role string, sessionID uuid.UUID, duration time.Duration, tokenType TokenType) (string, *Payload, error) {
+	payload, err := NewPayload(username, role, sessionID, duration, tokenType)
 	if err != nil {
-		return "", err
+		return "", payload, err
 	}
-	return maker.paseto.Encrypt(maker.symmetricKey, payload, nil)
+
+	keyID, key := maker.keyRing.ActiveKey()
+	token, err := maker.paseto.Encrypt(key, payload, pasetoFooter{KeyID: keyID})
+	return token, payload, err
 }
 
 // VerifyToken checks if the token is valid or not
-func (maker *PasetoMaker) VerifyToken(token string) (*Payload, error) {
-	payload := &Payload{}
-	err := maker.paseto.Decrypt(token, maker.symmetricKey, payload, nil)
+func (maker *PasetoMaker) VerifyToken(token string, tokenType TokenType) (*Payload, error) {
+	var footer pasetoFooter
+	err := paseto.ParseFooter(token, &footer)
 	if err != nil {
 		return nil, ErrInvalidToken
 	}
 
-	err = payload.Valid()
+	// tokens issued before key IDs were introduced have no footer,
+	// and may have been encrypted with any key still in the ring
+	keyIDs := []string{footer.KeyID}
+	if footer.KeyID == "" {
+		keyIDs = maker.keyRing.KeyIDs()
+	}
+
+	payload, err := maker.decrypt(token, keyIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = payload.Valid(tokenType)
 	if err != nil {
 		return nil, err
 	}
 
 	return payload, nil
 }
+
+// decrypt decrypts the token with the first of the keys that can
+func (maker *PasetoMaker) decrypt(token string, keyIDs []string) (*Payload, error) {
+	for _, keyID := range keyIDs {
+		key, ok := maker.keyRing.Key(keyID)
+		if !ok {
+			continue
+		}
+
+		payload := &Payload{}
+		err := maker.paseto.Decrypt(token, key, payload, nil)
+		if err == nil {
+			return payload, nil
+		}
+	}
+
+	return nil, ErrInvalidToken
+}
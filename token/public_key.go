@@ -0,0 +1,99 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// PublicKey is a public key that can be used to verify tokens
+type PublicKey struct {
+	KeyID     string
+	Algorithm string
+	Key       ed25519.PublicKey
+}
+
+// PublicKeyProvider is implemented by makers whose tokens can be verified
+// by third parties holding only the public keys
+type PublicKeyProvider interface {
+	// PublicKeys returns the keys accepted when verifying tokens
+	PublicKeys() []PublicKey
+}
+
+// JSONWebKey is the JWK representation of an Ed25519 public key (RFC 8037)
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+}
+
+// JWK converts the public key into its JSON Web Key representation
+func (key PublicKey) JWK() JSONWebKey {
+	return JSONWebKey{
+		KeyType:   "OKP",
+		Curve:     "Ed25519",
+		X:         base64.RawURLEncoding.EncodeToString(key.Key),
+		KeyID:     key.KeyID,
+		Algorithm: key.Algorithm,
+		Use:       "sig",
+	}
+}
+
+// Ed25519KeyID returns the RFC 7638 JWK thumbprint of an Ed25519 public key
+func Ed25519KeyID(publicKey ed25519.PublicKey) string {
+	// members must be in lexicographic order with no whitespace
+	thumbprint, _ := json.Marshal(struct {
+		Curve   string `json:"crv"`
+		KeyType string `json:"kty"`
+		X       string `json:"x"`
+	}{
+		Curve:   "Ed25519",
+		KeyType: "OKP",
+		X:       base64.RawURLEncoding.EncodeToString(publicKey),
+	})
+
+	sum := sha256.Sum256(thumbprint)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// ParseEd25519PrivateKey decodes a hex encoded Ed25519 private key or 32 byte seed
+func ParseEd25519PrivateKey(hexKey string) (ed25519.PrivateKey, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key encoding: %w", err)
+	}
+
+	switch len(key) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(key), nil
+	case ed25519.PrivateKeySize:
+		privateKey := ed25519.PrivateKey(key)
+		seeded := ed25519.NewKeyFromSeed(privateKey.Seed())
+		if !privateKey.Equal(seeded) {
+			return nil, fmt.Errorf("invalid private key: public part does not match seed")
+		}
+		return privateKey, nil
+	}
+
+	return nil, fmt.Errorf("invalid private key size: must be %d or %d bytes", ed25519.SeedSize, ed25519.PrivateKeySize)
+}
+
+// ParseEd25519PublicKey decodes a hex encoded Ed25519 public key
+func ParseEd25519PublicKey(hexKey string) (ed25519.PublicKey, error) {
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key encoding: %w", err)
+	}
+
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key size: must be exactly %d bytes", ed25519.PublicKeySize)
+	}
+
+	return ed25519.PublicKey(key), nil
+}
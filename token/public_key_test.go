@@ -0,0 +1,46 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEd25519KeyID(t *testing.T) {
+	// test vector from RFC 8037 appendix A.3
+	x, err := base64.RawURLEncoding.DecodeString("11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo")
+	require.NoError(t, err)
+
+	keyID := Ed25519KeyID(ed25519.PublicKey(x))
+	require.Equal(t, "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k", keyID)
+}
+
+func TestParseEd25519Keys(t *testing.T) {
+	// test vector from RFC 8037 appendix A.1
+	seed := "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60"
+	public := "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a"
+
+	privateKey, err := ParseEd25519PrivateKey(seed)
+	require.NoError(t, err)
+	require.Equal(t, public, hex.EncodeToString(privateKey.Public().(ed25519.PublicKey)))
+
+	fullKey, err := ParseEd25519PrivateKey(hex.EncodeToString(privateKey))
+	require.NoError(t, err)
+	require.Equal(t, privateKey, fullKey)
+
+	publicKey, err := ParseEd25519PublicKey(public)
+	require.NoError(t, err)
+	require.True(t, publicKey.Equal(privateKey.Public()))
+
+	_, err = ParseEd25519PrivateKey("not-hex")
+	require.Error(t, err)
+
+	_, err = ParseEd25519PrivateKey(seed[:10])
+	require.Error(t, err)
+
+	_, err = ParseEd25519PublicKey(seed + seed)
+	require.Error(t, err)
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/spf13/viper"
@@ -9,11 +10,44 @@ import (
 // Config sotres all configuration of the applications
 // the  values are read by viper from a config file or environment variables
 type Config struct {
-	DBDriver            string        `mapstructure:"DB_DRIVER"`
-	DBSource            string        `mapstructure:"DB_SOURCE"`
-	ServerAddress       string        `mapstructure:"SERVER_ADDRESS"`
-	TokenSymmetricKey   string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
-	AccessTokenDuration time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	DBDriver                    string        `mapstructure:"DB_DRIVER"`
+	DBSource                    string        `mapstructure:"DB_SOURCE"`
+	ServerAddress               string        `mapstructure:"SERVER_ADDRESS"`
+	GRPCServerAddress           string        `mapstructure:"GRPC_SERVER_ADDRESS"`
+	GatewayAddress              string        `mapstructure:"GATEWAY_ADDRESS"`
+	MetricsAddress              string        `mapstructure:"METRICS_ADDRESS"`
+	TrustedProxies              string        `mapstructure:"TRUSTED_PROXIES"`
+	TokenSymmetricKey           string        `mapstructure:"TOKEN_SYMMETRIC_KEY"`
+	TokenSymmetricKeys          string        `mapstructure:"TOKEN_SYMMETRIC_KEYS"`
+	TokenActiveKeyID            string        `mapstructure:"TOKEN_ACTIVE_KEY_ID"`
+	TokenPrivateKey             string        `mapstructure:"TOKEN_PRIVATE_KEY"`
+	AccessTokenDuration         time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	RefreshTokenDuration        time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
+	DenylistSyncInterval        time.Duration `mapstructure:"DENYLIST_SYNC_INTERVAL"`
+	IdempotencyKeyTTL           time.Duration `mapstructure:"IDEMPOTENCY_KEY_TTL"`
+	IdempotencyKeyPruneInterval time.Duration `mapstructure:"IDEMPOTENCY_KEY_PRUNE_INTERVAL"`
+	CursorSigningKey            string        `mapstructure:"CURSOR_SIGNING_KEY"`
+	FXRatesFile                 string        `mapstructure:"FX_RATES_FILE"`
+	FXQuoteDuration             time.Duration `mapstructure:"FX_QUOTE_DURATION"`
+	SchedulerInterval           time.Duration `mapstructure:"SCHEDULER_INTERVAL"`
+	HoldExpiryInterval          time.Duration `mapstructure:"HOLD_EXPIRY_INTERVAL"`
+	TransferIsolationLevel      string        `mapstructure:"TRANSFER_ISOLATION_LEVEL"`
+	RateLimitStore              string        `mapstructure:"RATE_LIMIT_STORE"`
+	LoginRateLimit              int           `mapstructure:"LOGIN_RATE_LIMIT"`
+	LoginRateLimitPeriod        time.Duration `mapstructure:"LOGIN_RATE_LIMIT_PERIOD"`
+	MaxFailedLogins             int32         `mapstructure:"MAX_FAILED_LOGINS"`
+	LoginLockoutDuration        time.Duration `mapstructure:"LOGIN_LOCKOUT_DURATION"`
+	HTTPReadTimeout             time.Duration `mapstructure:"HTTP_READ_TIMEOUT"`
+	HTTPWriteTimeout            time.Duration `mapstructure:"HTTP_WRITE_TIMEOUT"`
+	HTTPIdleTimeout             time.Duration `mapstructure:"HTTP_IDLE_TIMEOUT"`
+	HTTPMaxHeaderBytes          int           `mapstructure:"HTTP_MAX_HEADER_BYTES"`
+	ShutdownTimeout             time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
+	ShutdownDrainDelay          time.Duration `mapstructure:"SHUTDOWN_DRAIN_DELAY"`
+	TracingExporter             string        `mapstructure:"TRACING_EXPORTER"`
+	TracingSampleRatio          float64       `mapstructure:"TRACING_SAMPLE_RATIO"`
+	OTLPEndpoint                string        `mapstructure:"OTLP_ENDPOINT"`
+	OTLPInsecure                bool          `mapstructure:"OTLP_INSECURE"`
+	LogLevel                    string        `mapstructure:"LOG_LEVEL"`
 }
 
 // LoadConfig reads configuration from file or environment variables
@@ -30,5 +64,33 @@ func LoadConfig(path string) (config Config, err error) {
 	}
 
 	err = viper.Unmarshal(&config)
+	if err != nil {
+		return
+	}
+
+	err = config.validate()
 	return
 }
+
+// validate checks the intervals of the background workers, since a ticker panics on an interval that is not positive.
+// The workers of disabled features are not started, so their intervals may be left unset.
+func (config Config) validate() error {
+	intervals := []struct {
+		name     string
+		interval time.Duration
+		enabled  bool
+	}{
+		{"DENYLIST_SYNC_INTERVAL", config.DenylistSyncInterval, true},
+		{"SCHEDULER_INTERVAL", config.SchedulerInterval, true},
+		{"HOLD_EXPIRY_INTERVAL", config.HoldExpiryInterval, true},
+		{"IDEMPOTENCY_KEY_PRUNE_INTERVAL", config.IdempotencyKeyPruneInterval, config.IdempotencyKeyTTL > 0},
+		{"LOGIN_RATE_LIMIT_PERIOD", config.LoginRateLimitPeriod, config.LoginRateLimit > 0},
+	}
+
+	for _, worker := range intervals {
+		if worker.enabled && worker.interval <= 0 {
+			return fmt.Errorf("%s must be positive, got %s", worker.name, worker.interval)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	validConfig := func() Config {
+		return Config{
+			DenylistSyncInterval:        30 * time.Second,
+			SchedulerInterval:           10 * time.Second,
+			HoldExpiryInterval:          time.Minute,
+			IdempotencyKeyTTL:           24 * time.Hour,
+			IdempotencyKeyPruneInterval: time.Hour,
+			LoginRateLimit:              10,
+			LoginRateLimitPeriod:        time.Minute,
+		}
+	}
+
+	testCases := []struct {
+		name   string
+		update func(config *Config)
+		errMsg string
+	}{
+		{
+			name:   "OK",
+			update: func(config *Config) {},
+		},
+		{
+			name: "UnsetSchedulerInterval",
+			update: func(config *Config) {
+				config.SchedulerInterval = 0
+			},
+			errMsg: "SCHEDULER_INTERVAL must be positive",
+		},
+		{
+			name: "NegativeDenylistSyncInterval",
+			update: func(config *Config) {
+				config.DenylistSyncInterval = -time.Second
+			},
+			errMsg: "DENYLIST_SYNC_INTERVAL must be positive",
+		},
+		{
+			name: "UnsetHoldExpiryInterval",
+			update: func(config *Config) {
+				config.HoldExpiryInterval = 0
+			},
+			errMsg: "HOLD_EXPIRY_INTERVAL must be positive",
+		},
+		{
+			name: "UnsetPruneInterval",
+			update: func(config *Config) {
+				config.IdempotencyKeyPruneInterval = 0
+			},
+			errMsg: "IDEMPOTENCY_KEY_PRUNE_INTERVAL must be positive",
+		},
+		{
+			name: "IdempotencyKeysNeverExpire",
+			update: func(config *Config) {
+				config.IdempotencyKeyTTL = 0
+				config.IdempotencyKeyPruneInterval = 0
+			},
+		},
+		{
+			name: "UnsetRateLimitPeriod",
+			update: func(config *Config) {
+				config.LoginRateLimitPeriod = 0
+			},
+			errMsg: "LOGIN_RATE_LIMIT_PERIOD must be positive",
+		},
+		{
+			name: "RateLimitDisabled",
+			update: func(config *Config) {
+				config.LoginRateLimit = 0
+				config.LoginRateLimitPeriod = 0
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			config := validConfig()
+			tc.update(&config)
+
+			err := config.validate()
+			if tc.errMsg == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tc.errMsg)
+		})
+	}
+}
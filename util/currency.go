@@ -1,15 +1,16 @@
 package util
 
+import "slices"
+
 const (
 	USD = "USD"
 	EUR = "EUR"
 	BDT = "BDT"
 )
 
+// SupportedCurrencies lists the currencies accounts can hold
+var SupportedCurrencies = []string{USD, EUR, BDT}
+
 func IsSupportedCurrency(currency string) bool {
-	switch currency {
-	case USD, EUR, BDT:
-		return true
-	}
-	return false
+	return slices.Contains(SupportedCurrencies, currency)
 }
@@ -0,0 +1,78 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// Redacted replaces the value of sensitive fields in the logs
+const Redacted = "[REDACTED]"
+
+// sensitiveLogKeys are the fields whose value never reaches the logs, in whatever group they are
+var sensitiveLogKeys = map[string]bool{
+	"password":        true,
+	"hashed_password": true,
+	"token":           true,
+	"access_token":    true,
+	"refresh_token":   true,
+	"authorization":   true,
+	"private_key":     true,
+}
+
+// NewLogger creates a logger writing JSON records at the level or above.
+// Records carry the attributes added to their context with WithLogAttrs,
+// and the values of sensitive fields are redacted.
+func NewLogger(w io.Writer, level string) (*slog.Logger, error) {
+	var minLevel slog.Level
+	if level != "" {
+		if err := minLevel.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+		}
+	}
+
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       minLevel,
+		ReplaceAttr: redactLogAttr,
+	})
+	return slog.New(contextLogHandler{handler}), nil
+}
+
+func redactLogAttr(groups []string, attr slog.Attr) slog.Attr {
+	if sensitiveLogKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, Redacted)
+	}
+	return attr
+}
+
+type logAttrsKey struct{}
+
+// WithLogAttrs returns a copy of ctx whose log records carry the attributes,
+// after those it carries already
+func WithLogAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(logAttrsKey{}).([]slog.Attr)
+	return context.WithValue(ctx, logAttrsKey{}, append(slices.Clip(existing), attrs...))
+}
+
+// contextLogHandler adds the attributes of the context to the records
+type contextLogHandler struct {
+	slog.Handler
+}
+
+func (handler contextLogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs, ok := ctx.Value(logAttrsKey{}).([]slog.Attr); ok {
+		record.AddAttrs(attrs...)
+	}
+	return handler.Handler.Handle(ctx, record)
+}
+
+func (handler contextLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextLogHandler{handler.Handler.WithAttrs(attrs)}
+}
+
+func (handler contextLogHandler) WithGroup(name string) slog.Handler {
+	return contextLogHandler{handler.Handler.WithGroup(name)}
+}
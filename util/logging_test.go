@@ -0,0 +1,64 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func decodeLogRecord(t *testing.T, buffer *bytes.Buffer) map[string]any {
+	var record map[string]any
+	err := json.Unmarshal(buffer.Bytes(), &record)
+	require.NoError(t, err)
+	buffer.Reset()
+	return record
+}
+
+func TestNewLogger(t *testing.T) {
+	var buffer bytes.Buffer
+	logger, err := NewLogger(&buffer, "warn")
+	require.NoError(t, err)
+
+	logger.Info("ignored")
+	require.Zero(t, buffer.Len())
+
+	ctx := WithLogAttrs(context.Background(), slog.String("request_id", "abc"))
+	ctx = WithLogAttrs(ctx, slog.String("username", "alice"))
+	logger.WarnContext(ctx, "message", slog.Int("attempt", 2))
+
+	record := decodeLogRecord(t, &buffer)
+	require.Equal(t, "WARN", record["level"])
+	require.Equal(t, "message", record["msg"])
+	require.Equal(t, "abc", record["request_id"])
+	require.Equal(t, "alice", record["username"])
+	require.Equal(t, 2.0, record["attempt"])
+
+	_, err = NewLogger(&buffer, "loud")
+	require.Error(t, err)
+}
+
+func TestLoggerRedactsSensitiveFields(t *testing.T) {
+	var buffer bytes.Buffer
+	logger, err := NewLogger(&buffer, "")
+	require.NoError(t, err)
+
+	password := RandomString(12)
+	token := RandomString(32)
+	logger.With(slog.String("Authorization", "Bearer "+token)).Info("login",
+		slog.String("username", "alice"),
+		slog.String("password", password),
+		slog.Group("session", slog.String("refresh_token", token)),
+	)
+	require.NotContains(t, buffer.String(), password)
+	require.NotContains(t, buffer.String(), token)
+
+	record := decodeLogRecord(t, &buffer)
+	require.Equal(t, "alice", record["username"])
+	require.Equal(t, Redacted, record["password"])
+	require.Equal(t, Redacted, record["Authorization"])
+	require.Equal(t, map[string]any{"refresh_token": Redacted}, record["session"])
+}
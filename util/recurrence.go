@@ -0,0 +1,52 @@
+package util
+
+import (
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Recurrence is how often a scheduled job repeats: on a standard 5-field cron expression,
+// every fixed interval, or never when both are empty
+type Recurrence struct {
+	CronExpression string
+	Interval       time.Duration
+}
+
+// ParseCronExpression parses a standard 5-field cron expression
+func ParseCronExpression(expression string) (cron.Schedule, error) {
+	return cron.ParseStandard(expression)
+}
+
+// First returns the first run at or after start.
+// Cron expressions are evaluated in UTC.
+func (r Recurrence) First(start time.Time) (time.Time, error) {
+	if r.CronExpression == "" {
+		return start, nil
+	}
+
+	schedule, err := ParseCronExpression(r.CronExpression)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return schedule.Next(start.UTC().Add(-time.Nanosecond)), nil
+}
+
+// Next returns the first run after now, following the previous run.
+// Runs missed while nothing was running are skipped, and interval runs keep the cadence of the previous run.
+// It returns false if the job does not repeat.
+func (r Recurrence) Next(previous time.Time, now time.Time) (time.Time, bool, error) {
+	switch {
+	case r.CronExpression != "":
+		schedule, err := ParseCronExpression(r.CronExpression)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		return schedule.Next(now.UTC()), true, nil
+	case r.Interval > 0:
+		runs := now.Sub(previous)/r.Interval + 1
+		return previous.Add(runs * r.Interval), true, nil
+	default:
+		return time.Time{}, false, nil
+	}
+}
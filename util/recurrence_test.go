@@ -0,0 +1,73 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecurrenceCron(t *testing.T) {
+	// rent on the 1st of each month at 09:00 UTC
+	recurrence := Recurrence{CronExpression: "0 9 1 * *"}
+
+	first, err := recurrence.First(time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC), first)
+
+	// a start matching the expression is the first run itself
+	first, err = recurrence.First(time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC), first)
+
+	next, ok, err := recurrence.Next(first, first.Add(time.Second))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, time.Date(2025, 5, 1, 9, 0, 0, 0, time.UTC), next)
+
+	// runs missed while the scheduler was down are skipped
+	next, ok, err = recurrence.Next(first, time.Date(2025, 7, 20, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, time.Date(2025, 8, 1, 9, 0, 0, 0, time.UTC), next)
+}
+
+func TestRecurrenceInterval(t *testing.T) {
+	recurrence := Recurrence{Interval: time.Hour}
+	start := time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC)
+
+	first, err := recurrence.First(start)
+	require.NoError(t, err)
+	require.Equal(t, start, first)
+
+	next, ok, err := recurrence.Next(first, first.Add(5*time.Second))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, start.Add(time.Hour), next)
+
+	// the cadence is kept when runs were missed
+	next, ok, err = recurrence.Next(first, first.Add(150*time.Minute))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, start.Add(3*time.Hour), next)
+}
+
+func TestRecurrenceOneOff(t *testing.T) {
+	recurrence := Recurrence{}
+	start := time.Now()
+
+	first, err := recurrence.First(start)
+	require.NoError(t, err)
+	require.Equal(t, start, first)
+
+	_, ok, err := recurrence.Next(first, first.Add(time.Second))
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestRecurrenceInvalidCron(t *testing.T) {
+	for _, expression := range []string{"", "every monday", "0 9 1 *", "61 * * * *", "0 9 1 * * *"} {
+		_, err := ParseCronExpression(expression)
+		require.Error(t, err, expression)
+	}
+}
@@ -0,0 +1,58 @@
+package util
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestSetupTracing(t *testing.T) {
+	defaultProvider := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(defaultProvider)
+
+	testCases := []struct {
+		name     string
+		config   Config
+		provider any
+	}{
+		{
+			name:     "Default",
+			config:   Config{},
+			provider: noop.TracerProvider{},
+		},
+		{
+			name:     "None",
+			config:   Config{TracingExporter: TracingExporterNone},
+			provider: noop.TracerProvider{},
+		},
+		{
+			name:     "Stdout",
+			config:   Config{TracingExporter: TracingExporterStdout, TracingSampleRatio: 1},
+			provider: &sdktrace.TracerProvider{},
+		},
+		{
+			name:     "OTLP",
+			config:   Config{TracingExporter: TracingExporterOTLP, OTLPEndpoint: "localhost:4317", OTLPInsecure: true},
+			provider: &sdktrace.TracerProvider{},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			shutdown, err := SetupTracing(context.Background(), tc.config)
+			require.NoError(t, err)
+			require.IsType(t, tc.provider, otel.GetTracerProvider())
+			require.ElementsMatch(t, []string{"traceparent", "tracestate", "baggage"}, otel.GetTextMapPropagator().Fields())
+			require.NoError(t, shutdown(context.Background()))
+		})
+	}
+
+	_, err := SetupTracing(context.Background(), Config{TracingExporter: "zipkin"})
+	require.Error(t, err)
+}